@@ -3,33 +3,83 @@ package main
 import (
 	"flag"
 	"log"
+	"log/slog"
 	"os"
 
+	"github.com/nicexiaonie/number-dispenser/internal/config"
 	"github.com/nicexiaonie/number-dispenser/internal/server"
 )
 
 func main() {
 	// Parse command line flags
-	addr := flag.String("addr", ":6380", "Server address to listen on")
-	dataDir := flag.String("data", "./data", "Directory for data persistence")
+	configFile := flag.String("config", "", "Path to config file (optional)")
+	flag.String("addr", ":6380", "Server address to listen on")
+	flag.String("data", "./data", "Directory for data persistence")
+	flag.String("metrics", "", "HTTP address for Prometheus /metrics (disabled if empty)")
 	flag.Parse()
 
+	// 只有显式指定的参数才覆盖配置文件和环境变量
+	flagKeys := map[string]string{
+		"addr":    "server.addr",
+		"data":    "storage.data_dir",
+		"metrics": "server.metrics_addr",
+	}
+	overrides := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		if key, ok := flagKeys[f.Name]; ok {
+			overrides[key] = f.Value.String()
+		}
+	})
+
+	cfg, err := config.Load(*configFile, overrides)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	setupLogging(cfg.Logging)
+
 	// Create data directory if not exists
-	if err := os.MkdirAll(*dataDir, 0755); err != nil {
+	if err := os.MkdirAll(cfg.Storage.DataDir, 0755); err != nil {
 		log.Fatalf("Failed to create data directory: %v", err)
 	}
 
 	// Create and start server
-	srv, err := server.NewServer(*addr, *dataDir)
+	srv, err := server.NewServer(cfg)
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
 	}
 
 	log.Println("Starting Number Dispenser Server...")
-	log.Printf("Address: %s", *addr)
-	log.Printf("Data Directory: %s", *dataDir)
+	if *configFile != "" {
+		log.Printf("Config File: %s", *configFile)
+	}
+	log.Printf("Address: %s", cfg.Server.Addr)
+	log.Printf("Data Directory: %s", cfg.Storage.DataDir)
+	if cfg.Server.MetricsAddr != "" {
+		log.Printf("Metrics Address: %s", cfg.Server.MetricsAddr)
+	}
+	log.Printf("Node ID: %s (cluster enabled: %v)", cfg.Cluster.NodeID, cfg.Cluster.Enabled)
 
 	if err := srv.Start(); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
 }
+
+// setupLogging 根据配置设置日志级别和格式
+// log.Printf 的输出同样经过这里设置的 handler，按 info 级别输出
+func setupLogging(cfg config.LoggingConfig) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+
+	slog.SetDefault(slog.New(handler))
+}
@@ -0,0 +1,303 @@
+// Package config loads the server configuration.
+//
+// 配置优先级（高到低）：命令行参数 > 环境变量 > 配置文件 > 默认值
+//
+// 环境变量名由配置键转换而来：加上 NUMBER_DISPENSER_ 前缀，"." 替换为 "_" 并转为大写，
+// 例如 storage.data_dir 对应 NUMBER_DISPENSER_STORAGE_DATA_DIR。
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix 环境变量前缀
+const EnvPrefix = "NUMBER_DISPENSER_"
+
+// Config represents the server configuration
+type Config struct {
+	Server  ServerConfig
+	Storage StorageConfig
+	Cluster ClusterConfig
+	Logging LoggingConfig
+}
+
+// ServerConfig 服务端配置
+type ServerConfig struct {
+	Addr            string        // 监听地址
+	ReadTimeout     time.Duration // 客户端读超时
+	PersistInterval time.Duration // 定期持久化间隔
+	MetricsAddr     string        // Prometheus /metrics 的 HTTP 监听地址，为空时不启动
+}
+
+// StorageConfig 存储配置
+type StorageConfig struct {
+	DataDir          string        // 数据目录
+	AutoSave         bool          // 是否异步自动保存
+	AutoSaveInterval time.Duration // 自动保存间隔
+}
+
+// ClusterConfig 集群配置
+type ClusterConfig struct {
+	Enabled            bool          // 是否启用集群模式
+	NodeID             string        // 节点ID
+	SegmentSize        int64         // 默认号段大小
+	CheckpointInterval time.Duration // 默认checkpoint间隔
+}
+
+// LoggingConfig 日志配置
+type LoggingConfig struct {
+	Level  string // debug, info, warn, error
+	Format string // text, json
+}
+
+// Default returns the built-in defaults
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:            ":6380",
+			ReadTimeout:     60 * time.Second,
+			PersistInterval: 10 * time.Second,
+		},
+		Storage: StorageConfig{
+			DataDir:          "./data",
+			AutoSave:         true,
+			AutoSaveInterval: 5 * time.Second,
+		},
+		Cluster: ClusterConfig{
+			Enabled:            false,
+			NodeID:             "node-1",
+			SegmentSize:        1000,
+			CheckpointInterval: 2 * time.Second,
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the config file (optional),
+// environment variables and command line overrides, in increasing precedence.
+// flags 的键与配置文件相同，如 "server.addr"
+func Load(path string, flags map[string]string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		values, err := parseFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		if err := cfg.apply(values, path); err != nil {
+			return cfg, err
+		}
+	}
+
+	env := make(map[string]string)
+	for _, key := range Keys() {
+		if value, ok := os.LookupEnv(EnvName(key)); ok {
+			env[key] = value
+		}
+	}
+	if err := cfg.apply(env, "environment"); err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.apply(flags, "flags"); err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Keys returns all supported configuration keys
+func Keys() []string {
+	keys := make([]string, 0, len(setters))
+	for key := range setters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// EnvName returns the environment variable name for a configuration key
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Validate checks the configuration values
+func (c Config) Validate() error {
+	if c.Server.Addr == "" {
+		return fmt.Errorf("server.addr must not be empty")
+	}
+	if c.Server.ReadTimeout <= 0 {
+		return fmt.Errorf("server.read_timeout must be positive")
+	}
+	if c.Server.PersistInterval <= 0 {
+		return fmt.Errorf("server.persist_interval must be positive")
+	}
+	if c.Storage.DataDir == "" {
+		return fmt.Errorf("storage.data_dir must not be empty")
+	}
+	if c.Storage.AutoSaveInterval <= 0 {
+		return fmt.Errorf("storage.auto_save_interval must be positive")
+	}
+	if c.Cluster.SegmentSize <= 0 {
+		return fmt.Errorf("cluster.segment_size must be positive")
+	}
+	if c.Cluster.CheckpointInterval < 0 {
+		return fmt.Errorf("cluster.checkpoint_interval must not be negative")
+	}
+	switch c.Logging.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("logging.level must be one of debug, info, warn, error")
+	}
+	switch c.Logging.Format {
+	case "text", "json":
+	default:
+		return fmt.Errorf("logging.format must be one of text, json")
+	}
+	return nil
+}
+
+// setters 配置键到字段的映射
+var setters = map[string]func(c *Config, value string) error{
+	"server.addr":                 func(c *Config, v string) error { c.Server.Addr = v; return nil },
+	"server.read_timeout":         func(c *Config, v string) error { return setDuration(&c.Server.ReadTimeout, v) },
+	"server.persist_interval":     func(c *Config, v string) error { return setDuration(&c.Server.PersistInterval, v) },
+	"server.metrics_addr":         func(c *Config, v string) error { c.Server.MetricsAddr = v; return nil },
+	"storage.data_dir":            func(c *Config, v string) error { c.Storage.DataDir = v; return nil },
+	"storage.auto_save":           func(c *Config, v string) error { return setBool(&c.Storage.AutoSave, v) },
+	"storage.auto_save_interval":  func(c *Config, v string) error { return setDuration(&c.Storage.AutoSaveInterval, v) },
+	"cluster.enabled":             func(c *Config, v string) error { return setBool(&c.Cluster.Enabled, v) },
+	"cluster.node_id":             func(c *Config, v string) error { c.Cluster.NodeID = v; return nil },
+	"cluster.segment_size":        func(c *Config, v string) error { return setInt(&c.Cluster.SegmentSize, v) },
+	"cluster.checkpoint_interval": func(c *Config, v string) error { return setDuration(&c.Cluster.CheckpointInterval, v) },
+	"logging.level":               func(c *Config, v string) error { c.Logging.Level = strings.ToLower(v); return nil },
+	"logging.format":              func(c *Config, v string) error { c.Logging.Format = strings.ToLower(v); return nil },
+}
+
+// apply 将键值对写入配置，source 用于错误信息
+func (c *Config) apply(values map[string]string, source string) error {
+	for key, value := range values {
+		set, ok := setters[key]
+		if !ok {
+			return fmt.Errorf("%s: unknown config key '%s'", source, key)
+		}
+		if err := set(c, value); err != nil {
+			return fmt.Errorf("%s: invalid value for '%s': %w", source, key, err)
+		}
+	}
+	return nil
+}
+
+func setDuration(dst *time.Duration, value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*dst = d
+	return nil
+}
+
+func setBool(dst *bool, value string) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*dst = b
+	return nil
+}
+
+func setInt(dst *int64, value string) error {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+	*dst = n
+	return nil
+}
+
+// parseFile 解析配置文件
+// 只支持本项目配置所需的 YAML 子集：两级 "key: value" 映射、# 注释和带引号的字符串
+func parseFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	section := ""
+	lineNo := 0
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lineNo++
+		raw := stripComment(scanner.Text())
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+
+		indented := raw[0] == ' ' || raw[0] == '\t'
+		key, value, ok := strings.Cut(strings.TrimSpace(raw), ":")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected 'key: value'", path, lineNo)
+		}
+		key = strings.TrimSpace(key)
+		value = unquote(strings.TrimSpace(value))
+
+		switch {
+		case !indented && value == "":
+			// 新的一级配置段
+			section = key
+		case !indented:
+			section = ""
+			values[key] = value
+		case section == "":
+			return nil, fmt.Errorf("%s:%d: indented key '%s' outside of a section", path, lineNo, key)
+		default:
+			values[section+"."+key] = value
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// stripComment 去掉引号之外的 # 注释
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
+
+// unquote 去掉成对的单引号或双引号
+func unquote(value string) string {
+	if len(value) >= 2 {
+		if (value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad_Precedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `# test config
+server:
+  addr: ":7000"   # inline comment
+  read_timeout: 30s
+
+storage:
+  data_dir: "/tmp/#not-a-comment"
+  auto_save: false
+
+cluster:
+  segment_size: 500
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	t.Setenv(EnvName("server.addr"), ":7001")
+	t.Setenv(EnvName("cluster.segment_size"), "800")
+
+	cfg, err := Load(path, map[string]string{"server.addr": ":7002"})
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// 命令行 > 环境变量 > 配置文件 > 默认值
+	if cfg.Server.Addr != ":7002" {
+		t.Errorf("Expected flag to win, got %s", cfg.Server.Addr)
+	}
+	if cfg.Cluster.SegmentSize != 800 {
+		t.Errorf("Expected env to override file, got %d", cfg.Cluster.SegmentSize)
+	}
+	if cfg.Server.ReadTimeout != 30*time.Second {
+		t.Errorf("Expected file value 30s, got %v", cfg.Server.ReadTimeout)
+	}
+	if cfg.Storage.DataDir != "/tmp/#not-a-comment" || cfg.Storage.AutoSave {
+		t.Errorf("Unexpected storage config: %+v", cfg.Storage)
+	}
+	if cfg.Server.PersistInterval != 10*time.Second || cfg.Cluster.CheckpointInterval != 2*time.Second {
+		t.Errorf("Expected defaults to be kept, got %+v %+v", cfg.Server, cfg.Cluster)
+	}
+}
+
+func TestLoad_RepositoryConfig(t *testing.T) {
+	cfg, err := Load("../../config/config.yaml", nil)
+	if err != nil {
+		t.Fatalf("Failed to load repository config: %v", err)
+	}
+	if cfg != Default() {
+		t.Errorf("Repository config should match defaults, got %+v", cfg)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("server:\n  unknown: 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := Load(path, nil); err == nil {
+		t.Error("Expected error for unknown key")
+	}
+
+	if _, err := Load("", map[string]string{"server.read_timeout": "soon"}); err == nil {
+		t.Error("Expected error for invalid duration")
+	}
+
+	if _, err := Load("", map[string]string{"logging.level": "verbose"}); err == nil {
+		t.Error("Expected error for invalid log level")
+	}
+}
@@ -0,0 +1,352 @@
+package dispenser
+
+import (
+	"math/bits"
+	"slices"
+	"sort"
+)
+
+// ============================================
+// Type 1 去重位图
+// ============================================
+
+// 去重集合采用 roaring 风格的压缩位图：号码按 num-base 的高位分块，每块覆盖 65536 个值
+// 块内元素不多时用有序数组（每个值2字节），超过 arrayMaxSize 后转为 8KB 的定长位图
+// 内存只与已发号码数量有关，与号码空间大小（最大 9×10^17）无关
+
+const (
+	chunkBits    = 16
+	chunkSize    = 1 << chunkBits
+	chunkWords   = chunkSize / 64
+	arrayMaxSize = 4096 // 数组块与位图块大小相同的临界点（4096×2B = 8KB）
+)
+
+// chunk 位图中的一个块，array 与 bitmap 二选一
+type chunk struct {
+	array  []uint16 // 有序数组
+	bitmap []uint64 // 定长位图
+	n      int      // 块内元素数
+}
+
+// usedSet 已发号码集合
+type usedSet struct {
+	base   int64 // 号码空间的最小值
+	chunks map[int64]*chunk
+	n      int64
+}
+
+// newUsedSet 创建以 base 为起点的号码集合
+func newUsedSet(base int64) *usedSet {
+	return &usedSet{base: base, chunks: make(map[int64]*chunk)}
+}
+
+// split 将号码拆分为块编号和块内偏移
+func (s *usedSet) split(num int64) (int64, uint16) {
+	offset := num - s.base
+	return offset >> chunkBits, uint16(offset)
+}
+
+// count 返回集合中的号码数
+func (s *usedSet) count() int64 {
+	return s.n
+}
+
+// contains 检查号码是否已在集合中
+func (s *usedSet) contains(num int64) bool {
+	key, low := s.split(num)
+	c := s.chunks[key]
+	if c == nil {
+		return false
+	}
+	if c.bitmap != nil {
+		return c.bitmap[low/64]&(1<<(low%64)) != 0
+	}
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= low })
+	return i < len(c.array) && c.array[i] == low
+}
+
+// add 加入号码，号码已存在时返回 false
+func (s *usedSet) add(num int64) bool {
+	key, low := s.split(num)
+	c := s.chunks[key]
+	if c == nil {
+		c = &chunk{}
+		s.chunks[key] = c
+	}
+
+	if c.bitmap != nil {
+		word, bit := low/64, uint64(1)<<(low%64)
+		if c.bitmap[word]&bit != 0 {
+			return false
+		}
+		c.bitmap[word] |= bit
+	} else {
+		i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= low })
+		if i < len(c.array) && c.array[i] == low {
+			return false
+		}
+		c.array = append(c.array, 0)
+		copy(c.array[i+1:], c.array[i:])
+		c.array[i] = low
+
+		// 数组超过临界点后转为位图
+		if len(c.array) > arrayMaxSize {
+			c.bitmap = make([]uint64, chunkWords)
+			for _, v := range c.array {
+				c.bitmap[v/64] |= 1 << (v % 64)
+			}
+			c.array = nil
+		}
+	}
+
+	c.n++
+	s.n++
+	return true
+}
+
+// remove 移除号码，号码不存在时返回 false
+func (s *usedSet) remove(num int64) bool {
+	key, low := s.split(num)
+	c := s.chunks[key]
+	if c == nil {
+		return false
+	}
+
+	if c.bitmap != nil {
+		word, bit := low/64, uint64(1)<<(low%64)
+		if c.bitmap[word]&bit == 0 {
+			return false
+		}
+		c.bitmap[word] &^= bit
+
+		// 元素减少到临界点后转回数组
+		if c.n-1 <= arrayMaxSize {
+			c.array = make([]uint16, 0, c.n-1)
+			for w, bitsWord := range c.bitmap {
+				for bitsWord != 0 {
+					c.array = append(c.array, uint16(w*64+bits.TrailingZeros64(bitsWord)))
+					bitsWord &= bitsWord - 1
+				}
+			}
+			c.bitmap = nil
+		}
+	} else {
+		i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= low })
+		if i >= len(c.array) || c.array[i] != low {
+			return false
+		}
+		c.array = append(c.array[:i], c.array[i+1:]...)
+	}
+
+	c.n--
+	s.n--
+	if c.n == 0 {
+		delete(s.chunks, key)
+	}
+	return true
+}
+
+// values 返回集合中的全部号码（升序）
+func (s *usedSet) values() []int64 {
+	values := make([]int64, 0, s.n)
+	for _, key := range s.sortedKeys() {
+		c := s.chunks[key]
+		offset := s.base + key<<chunkBits
+		if c.bitmap == nil {
+			for _, v := range c.array {
+				values = append(values, offset+int64(v))
+			}
+			continue
+		}
+		for w, word := range c.bitmap {
+			for word != 0 {
+				values = append(values, offset+int64(w*64+bits.TrailingZeros64(word)))
+				word &= word - 1
+			}
+		}
+	}
+	return values
+}
+
+// sortedKeys 返回已有块的编号（升序）
+func (s *usedSet) sortedKeys() []int64 {
+	keys := make([]int64, 0, len(s.chunks))
+	for key := range s.chunks {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// selectFree 返回 [base, base+size) 中第 r 个（从0开始）不在集合中的号码
+// 调用方需保证 0 <= r < size-count()
+func (s *usedSet) selectFree(r, size int64) int64 {
+	return s.selectFreeIn(s.sortedKeys(), r, size)
+}
+
+// selectFreeIn 同 selectFree，keys 为 sortedKeys 的结果
+// 只遍历已有的块，块之间的空块整体跳过，耗时与号码空间大小无关
+func (s *usedSet) selectFreeIn(keys []int64, r, size int64) int64 {
+	next := int64(0) // 尚未计入的第一个偏移
+	for _, key := range keys {
+		offset := key << chunkBits
+		if offset >= size {
+			break
+		}
+
+		// 两个已有块之间全是空闲号码
+		gap := offset - next
+		if r < gap {
+			return s.base + next + r
+		}
+		r -= gap
+
+		next = min(offset+chunkSize, size)
+		c := s.chunks[key]
+		if free := next - offset - int64(c.n); r >= free {
+			r -= free
+			continue
+		}
+
+		switch {
+		case c.bitmap != nil:
+			for w, word := range c.bitmap {
+				empty := ^word
+				if n := int64(bits.OnesCount64(empty)); r >= n {
+					r -= n
+					continue
+				}
+				for ; r > 0; r-- {
+					empty &= empty - 1
+				}
+				return s.base + offset + int64(w*64+bits.TrailingZeros64(empty))
+			}
+		default:
+			// 逐个跳过数组中已占用的值
+			prev := int64(0)
+			for _, v := range c.array {
+				gap := int64(v) - prev
+				if r < gap {
+					break
+				}
+				r -= gap
+				prev = int64(v) + 1
+			}
+			return s.base + offset + prev + r
+		}
+	}
+
+	if next+r < size {
+		return s.base + next + r
+	}
+	return -1
+}
+
+// selectFreeSkipping 同 selectFree，但同时跳过 skip 中的号码（skip 与集合不相交）
+// 调用方需保证 0 <= r < size-count()-skip中的号码数
+// 按保留区间之间的空隙逐段计数，耗时与区间数和块数有关，与区间大小无关
+func (s *usedSet) selectFreeSkipping(r, size int64, skip []ReservedRange) int64 {
+	keys, before := s.index()
+	end := s.base + size
+
+	lo := s.base // 当前空隙的起点
+	for i := 0; i <= len(skip) && lo < end; i++ {
+		hi := end // 空隙 [lo, hi)
+		if i < len(skip) {
+			hi = min(max(skip[i].Start, lo), end)
+		}
+
+		free := hi - lo - (s.rank(keys, before, hi) - s.rank(keys, before, lo))
+		if r < free {
+			// 空隙之前的空闲号码数 + r 即为不考虑 skip 时的序号
+			return s.selectFreeIn(keys, lo-s.base-s.rank(keys, before, lo)+r, size)
+		}
+		r -= free
+
+		if i < len(skip) {
+			lo = max(lo, skip[i].End+1)
+		}
+	}
+	return -1
+}
+
+// index 返回已有块的编号（升序），以及各块之前的号码数（before[i] 为 keys[:i] 中的号码数）
+func (s *usedSet) index() (keys, before []int64) {
+	keys = s.sortedKeys()
+	before = make([]int64, len(keys)+1)
+	for i, key := range keys {
+		before[i+1] = before[i] + int64(s.chunks[key].n)
+	}
+	return keys, before
+}
+
+// rank 返回集合中小于 num 的号码数（keys、before 为 index 的结果）
+func (s *usedSet) rank(keys, before []int64, num int64) int64 {
+	key, low := s.split(num)
+	i := sort.Search(len(keys), func(i int) bool { return keys[i] >= key })
+	n := before[i]
+	if i == len(keys) || keys[i] != key {
+		return n
+	}
+
+	c := s.chunks[key]
+	if c.bitmap == nil {
+		return n + int64(sort.Search(len(c.array), func(j int) bool { return c.array[j] >= low }))
+	}
+	for _, word := range c.bitmap[:low/64] {
+		n += int64(bits.OnesCount64(word))
+	}
+	return n + int64(bits.OnesCount64(c.bitmap[low/64]&(1<<(low%64)-1)))
+}
+
+// containsAny 检查 [lo, hi] 中是否有号码在集合中
+func (s *usedSet) containsAny(lo, hi int64) bool {
+	loKey, loOffset := s.split(lo)
+	hiKey, hiOffset := s.split(hi)
+
+	check := func(key int64, c *chunk) bool {
+		from, to := 0, chunkSize-1
+		if key == loKey {
+			from = int(loOffset)
+		}
+		if key == hiKey {
+			to = int(hiOffset)
+		}
+		if c.bitmap != nil {
+			for v := from; v <= to; v++ {
+				if c.bitmap[v/64]&(1<<(v%64)) != 0 {
+					return true
+				}
+			}
+			return false
+		}
+		i := sort.Search(len(c.array), func(i int) bool { return int(c.array[i]) >= from })
+		return i < len(c.array) && int(c.array[i]) <= to
+	}
+
+	// 区间跨越的块比已有的块多时，直接遍历已有的块
+	if hiKey-loKey+1 > int64(len(s.chunks)) {
+		for key, c := range s.chunks {
+			if key >= loKey && key <= hiKey && check(key, c) {
+				return true
+			}
+		}
+		return false
+	}
+	for key := loKey; key <= hiKey; key++ {
+		if c := s.chunks[key]; c != nil && check(key, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// memoryBytes 估算集合占用的内存（字节）
+func (s *usedSet) memoryBytes() int64 {
+	// 每个块的结构体与 map 项开销按 64 字节估算
+	total := int64(len(s.chunks)) * 64
+	for _, c := range s.chunks {
+		total += int64(cap(c.array))*2 + int64(cap(c.bitmap))*8
+	}
+	return total
+}
@@ -0,0 +1,176 @@
+package dispenser
+
+import (
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestUsedSet(t *testing.T) {
+	s := newUsedSet(1000)
+	size := int64(3 * chunkSize)
+	ref := make(map[int64]bool)
+
+	// 第一个块写满触发数组到位图的转换，其余块保持稀疏
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		num := 1000 + rng.Int63n(chunkSize)
+		if i%4 == 0 {
+			num = 1000 + rng.Int63n(size)
+		}
+		if s.add(num) == ref[num] {
+			t.Fatalf("add(%d) disagrees with reference", num)
+		}
+		ref[num] = true
+	}
+	if s.count() != int64(len(ref)) {
+		t.Fatalf("Expected %d values, got %d", len(ref), s.count())
+	}
+	if s.chunks[0].bitmap == nil {
+		t.Error("Expected dense chunk to be stored as bitmap")
+	}
+
+	// selectFree 按顺序枚举空闲号码
+	var free []int64
+	for num := int64(1000); num < 1000+size; num++ {
+		if !ref[num] {
+			free = append(free, num)
+		}
+	}
+	for _, r := range []int64{0, 1, 777, int64(len(free)) / 2, int64(len(free)) - 1} {
+		if got := s.selectFree(r, size); got != free[r] {
+			t.Errorf("selectFree(%d) = %d, expected %d", r, got, free[r])
+		}
+	}
+
+	// selectFreeSkipping 同时跳过保留区间（区间与集合不相交，跨越位图块和数组块）
+	var skip []ReservedRange
+	for _, rng := range []ReservedRange{{Start: 1000, End: 1010}, {Start: 40000, End: 70000}, {Start: 150000, End: 150100}} {
+		for num := rng.Start; num <= rng.End; num++ {
+			if ref[num] {
+				continue
+			}
+			if n := len(skip); n > 0 && skip[n-1].End == num-1 {
+				skip[n-1].End = num
+			} else {
+				skip = append(skip, ReservedRange{Start: num, End: num})
+			}
+		}
+	}
+	var rest []int64
+	for _, num := range free {
+		if _, ok := findRange(skip, num); !ok {
+			rest = append(rest, num)
+		}
+	}
+	for _, r := range []int64{0, 1, 777, int64(len(rest)) / 2, int64(len(rest)) - 1} {
+		if got := s.selectFreeSkipping(r, size, skip); got != rest[r] {
+			t.Errorf("selectFreeSkipping(%d) = %d, expected %d", r, got, rest[r])
+		}
+	}
+
+	// 删除后位图块转回数组
+	for num := range ref {
+		if !s.remove(num) {
+			t.Fatalf("remove(%d) failed", num)
+		}
+		if s.contains(num) {
+			t.Fatalf("contains(%d) after remove", num)
+		}
+	}
+	if s.count() != 0 || len(s.chunks) != 0 || s.memoryBytes() != 0 {
+		t.Errorf("Expected empty set, got %d values in %d chunks", s.count(), len(s.chunks))
+	}
+}
+
+func TestType1_FullSpace(t *testing.T) {
+	d, err := NewDispenser(Config{Type: TypeNumericRandom, Length: 2, RNG: RNGFast})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	// 10~99 共90个号码全部可用，之后才报告耗尽
+	seen := make(map[string]bool)
+	for i := 0; i < 90; i++ {
+		num, err := d.Next()
+		if err != nil {
+			t.Fatalf("Failed at %d: %v", i, err)
+		}
+		if seen[num] {
+			t.Fatalf("Duplicate number %s", num)
+		}
+		seen[num] = true
+	}
+	if _, err := d.Next(); err != ErrNumberExhausted {
+		t.Errorf("Expected ErrNumberExhausted, got %v", err)
+	}
+}
+
+func TestType1_Length18(t *testing.T) {
+	d, err := NewDispenser(Config{Type: TypeNumericRandom, Length: 18})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	nums, err := d.NextN(1000)
+	if err != nil {
+		t.Fatalf("Failed to generate numbers: %v", err)
+	}
+	if len(nums[0]) != 18 {
+		t.Errorf("Expected 18 digits, got %s", nums[0])
+	}
+
+	// 稀疏分布时每个号码约占一个块
+	stats := d.GetStats()
+	if stats.UniqueTracked != 1000 || stats.UniqueMemory <= 0 || stats.UniqueMemory > 1000*128 {
+		t.Errorf("Unexpected footprint: %+v", stats)
+	}
+}
+
+// 测试长号码上的大范围保留：保留后使用率超过一半，逐个取空闲号码时不能逐块遍历号码空间
+func TestType1_Length18LargeReservation(t *testing.T) {
+	d, err := NewDispenser(Config{Type: TypeNumericRandom, Length: 18, RNG: RNGFast})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	// 分散发出一些号码后，保留最后 3000 个号码之外的全部空间
+	const free = 3000
+	lo, hi := int64(1e17), int64(1e18)-1
+	nums, _ := d.NextN(100)
+	issued := []int64{hi - free + 1}
+	for _, num := range nums {
+		v, _ := strconv.ParseInt(num, 10, 64)
+		issued = append(issued, v)
+	}
+	slices.Sort(issued)
+	var ranges []ReservedRange
+	for _, v := range issued {
+		if v > hi-free+1 {
+			t.Skipf("Number %d issued in the free window", v)
+		}
+		if v > lo {
+			ranges = append(ranges, ReservedRange{Start: lo, End: v - 1})
+		}
+		lo = v + 1
+	}
+	if _, err := d.Reserve(ranges); err != nil {
+		t.Fatalf("Failed to reserve: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < free; i++ {
+		num, err := d.Next()
+		if err != nil {
+			t.Fatalf("Failed at %d: %v", i, err)
+		}
+		if v, _ := strconv.ParseInt(num, 10, 64); v <= hi-free || seen[num] {
+			t.Fatalf("Unexpected number %s", num)
+		}
+		seen[num] = true
+	}
+	if _, err := d.Next(); err != ErrNumberExhausted {
+		t.Errorf("Expected ErrNumberExhausted, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package dispenser
+
+import "errors"
+
+var (
+	ErrInvalidBounds    = errors.New("invalid min/max bounds")
+	ErrInvalidExhausted = errors.New("invalid on_exhausted policy")
+
+	errWrapSegment = errors.New("segment allocation not supported with on_exhausted wrap")
+)
+
+// ExhaustedPolicy represents how a Type 2 counter behaves once it leaves [min, max]
+type ExhaustedPolicy string
+
+const (
+	ExhaustedError  ExhaustedPolicy = "error"         // 返回 ErrNumberExhausted（默认）
+	ExhaustedWrap   ExhaustedPolicy = "wrap"          // 回到起点继续发号：正步长回到 min，负步长回到 max
+	ExhaustedExtend ExhaustedPolicy = "extend_length" // 固定位数用完后自动增加位数（如 9999 之后为 10000）
+)
+
+// maxStep 步长绝对值上限，计数器不超过18位，前进一步不会溢出
+var maxStep = pow10(18)
+
+// ============================================
+// Type 2 计数器范围
+// ============================================
+
+// fixedWidth 检查 Type 2 是否按固定位数输出（未指定模式但指定了长度时按 fixed 处理）
+func fixedWidth(cfg Config) bool {
+	return cfg.IncrMode == IncrModeFixed || cfg.IncrMode == IncrModePermuted || (cfg.IncrMode == "" && cfg.Length > 0)
+}
+
+// counterBounds 返回 Type 2 计数器的取值范围 [lo, hi]
+// 未指定 max 时，固定位数模式为位数上限（extend_length 为18位上限），其余为18位上限
+func counterBounds(cfg Config) (int64, int64) {
+	hi := cfg.Max
+	if hi == 0 {
+		hi = pow10(18) - 1
+		if fixedWidth(cfg) && cfg.OnExhausted != ExhaustedExtend {
+			hi = pow10(cfg.Length) - 1
+		}
+	}
+	return cfg.Min, hi
+}
+
+// inBounds 检查计数器是否在 [min, max] 内
+func inBounds(cfg Config, num int64) bool {
+	lo, hi := counterBounds(cfg)
+	return num >= lo && num <= hi
+}
+
+// wrapStart 返回回绕后的起点：正步长回到 min，负步长回到 max
+// 未启用 wrap 时返回 false
+func wrapStart(cfg Config) (int64, bool) {
+	if cfg.OnExhausted != ExhaustedWrap {
+		return 0, false
+	}
+	lo, hi := counterBounds(cfg)
+	if cfg.Step < 0 {
+		return hi, true
+	}
+	return lo, true
+}
+
+// startingValue 返回计数器的起点：未指定 starting 时正步长从 min 开始，负步长从 max 开始
+func startingValue(cfg Config) int64 {
+	if cfg.Starting != 0 {
+		return cfg.Starting
+	}
+	lo, hi := counterBounds(cfg)
+	if cfg.Step < 0 {
+		return hi
+	}
+	return lo
+}
+
+// reached 检查计数器是否已到达（或越过）发号方向上的位置 end
+func reached(cfg Config, num, end int64) bool {
+	if cfg.Step < 0 {
+		return num <= end
+	}
+	return num >= end
+}
+
+// between 检查 num 是否位于从 from（含）到 to（不含）的发号路径上
+func between(cfg Config, num, from, to int64) bool {
+	return reached(cfg, num, from) && !reached(cfg, num, to)
+}
+
+// countBetween 返回从 from 前进到 to 经过的号码数（to 不在 from 之后时为0）
+func countBetween(cfg Config, from, to int64) int64 {
+	return max(0, (to-from)/cfg.Step)
+}
+
+// segmentRange 计算从 start 开始、包含 size 个号码的号段，返回号段起点和END（不包含）
+// 号段不会越过 [min, max]；start 已越界时按 on_exhausted 回绕到起点或返回 ErrNumberExhausted
+func segmentRange(cfg Config, start, size int64) (int64, int64, error) {
+	if !inBounds(cfg, start) {
+		wrapped, ok := wrapStart(cfg)
+		if !ok {
+			return 0, 0, ErrNumberExhausted
+		}
+		start = wrapped
+	}
+
+	// 截断到范围边界
+	lo, hi := counterBounds(cfg)
+	room := (hi-start)/cfg.Step + 1
+	if cfg.Step < 0 {
+		room = (start-lo)/-cfg.Step + 1
+	}
+	size = min(size, room)
+
+	return start, start + size*cfg.Step, nil
+}
+
+// validateBounds 校验 Type 2 的 min、max、步长和 on_exhausted（配置中的步长未设置默认值）
+func validateBounds(cfg Config) error {
+	if cfg.Step < -maxStep || cfg.Step > maxStep {
+		return ErrInvalidStep
+	}
+
+	switch cfg.OnExhausted {
+	case "", ExhaustedError, ExhaustedWrap:
+	case ExhaustedExtend:
+		// 只有按位数截止的固定位数模式可以加长；置换的密钥与位数绑定，无法加长
+		if !fixedWidth(cfg) || cfg.IncrMode == IncrModePermuted || cfg.Max != 0 || cfg.Step < 0 {
+			return ErrInvalidExhausted
+		}
+	default:
+		return ErrInvalidExhausted
+	}
+
+	if cfg.Min < 0 || cfg.Max < 0 {
+		return ErrInvalidBounds
+	}
+	lo, hi := counterBounds(cfg)
+	if lo > hi {
+		return ErrInvalidBounds
+	}
+	if cfg.Max != 0 && fixedWidth(cfg) && cfg.Max >= pow10(cfg.Length) {
+		return ErrInvalidBounds
+	}
+
+	if start := startingValue(cfg); start < lo || start > hi {
+		return ErrInvalidStarting
+	}
+	return nil
+}
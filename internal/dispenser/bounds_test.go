@@ -0,0 +1,160 @@
+package dispenser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// boundsStrategies 计数器范围需要在所有实现中表现一致
+var boundsStrategies = []PersistenceStrategy{StrategyMemory, StrategyPreBase, StrategyPreClose}
+
+func TestBounds_Countdown(t *testing.T) {
+	for _, strategy := range boundsStrategies {
+		t.Run(string(strategy), func(t *testing.T) {
+			factory := NewDispenserFactory(nil)
+			d, err := factory.CreateDispenser("stock", Config{
+				Type:        TypeNumericIncremental,
+				IncrMode:    IncrModeSequence,
+				Step:        -2,
+				Min:         3,
+				Max:         10,
+				SegmentSize: 3,
+				AutoDisk:    strategy,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create dispenser: %v", err)
+			}
+			defer d.Shutdown()
+
+			// 未指定 starting 时从 max 开始倒数，号段边界不影响序列
+			nums, err := d.NextN(4)
+			if err != nil {
+				t.Fatalf("Failed to generate numbers: %v", err)
+			}
+			if strings.Join(nums, ",") != "10,8,6,4" {
+				t.Errorf("Expected countdown 10,8,6,4, got %v", nums)
+			}
+			if _, err := d.Next(); err != ErrNumberExhausted {
+				t.Errorf("Expected ErrNumberExhausted below min, got %v", err)
+			}
+		})
+	}
+}
+
+func TestBounds_Wrap(t *testing.T) {
+	for _, strategy := range boundsStrategies {
+		t.Run(string(strategy), func(t *testing.T) {
+			factory := NewDispenserFactory(nil)
+			d, err := factory.CreateDispenser("batch", Config{
+				Type:        TypeNumericIncremental,
+				IncrMode:    IncrModeFixed,
+				Length:      4,
+				Starting:    9997,
+				OnExhausted: ExhaustedWrap,
+				SegmentSize: 2,
+				AutoDisk:    strategy,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create dispenser: %v", err)
+			}
+			defer d.Shutdown()
+
+			nums, err := d.NextN(5)
+			if err != nil {
+				t.Fatalf("Failed to generate numbers: %v", err)
+			}
+			if strings.Join(nums, ",") != "9997,9998,9999,0000,0001" {
+				t.Errorf("Expected wrap to 0000, got %v", nums)
+			}
+
+			// 租出的号段会被回绕后的计数器再次经过
+			if _, _, err := d.AllocateSegment(10); !errors.Is(err, errWrapSegment) {
+				t.Errorf("Expected errWrapSegment, got %v", err)
+			}
+		})
+	}
+}
+
+func TestBounds_ExtendLength(t *testing.T) {
+	for _, strategy := range boundsStrategies {
+		t.Run(string(strategy), func(t *testing.T) {
+			factory := NewDispenserFactory(nil)
+			d, err := factory.CreateDispenser("ticket", Config{
+				Type:        TypeNumericIncremental,
+				IncrMode:    IncrModeFixed,
+				Length:      2,
+				Starting:    98,
+				OnExhausted: ExhaustedExtend,
+				CheckDigit:  CheckDigitLuhn,
+				SegmentSize: 2,
+				AutoDisk:    strategy,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create dispenser: %v", err)
+			}
+			defer d.Shutdown()
+
+			nums, err := d.NextN(3)
+			if err != nil {
+				t.Fatalf("Failed to generate numbers: %v", err)
+			}
+			if len(nums[1]) != 3 || len(nums[2]) != 4 || nums[2][:3] != "100" {
+				t.Errorf("Expected length to grow after 99, got %v", nums)
+			}
+			for _, num := range nums {
+				if err := ValidateID(d, num); err != nil {
+					t.Errorf("ValidateID(%s) = %v", num, err)
+				}
+			}
+		})
+	}
+}
+
+func TestBounds_Validate(t *testing.T) {
+	cfg := Config{Type: TypeNumericIncremental, IncrMode: IncrModeFixed, Length: 3, Starting: 500, Step: -5, Min: 100}
+	tests := []struct {
+		value string
+		want  error
+	}{
+		{"500", nil},
+		{"105", nil},
+		{"505", ErrOutOfRange}, // 在起点之前
+		{"095", ErrOutOfRange}, // 低于 min
+		{"499", ErrOutOfRange}, // 不在步长上
+	}
+	for _, tt := range tests {
+		if err := Validate(cfg, tt.value); err != tt.want {
+			t.Errorf("Validate(%s) = %v, want %v", tt.value, err, tt.want)
+		}
+	}
+}
+
+func TestValidateBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr error
+	}{
+		{"countdown", Config{Type: TypeNumericIncremental, Starting: 1000, Step: -1}, nil},
+		{"range", Config{Type: TypeNumericIncremental, Min: 10, Max: 20, OnExhausted: ExhaustedWrap}, nil},
+		{"fixed extend", Config{Type: TypeNumericIncremental, IncrMode: IncrModeFixed, Length: 4, OnExhausted: ExhaustedExtend}, nil},
+		{"min above max", Config{Type: TypeNumericIncremental, Min: 20, Max: 10}, ErrInvalidBounds},
+		{"max above length", Config{Type: TypeNumericIncremental, IncrMode: IncrModeFixed, Length: 2, Max: 100}, ErrInvalidBounds},
+		{"starting out of range", Config{Type: TypeNumericIncremental, Starting: 5, Min: 10, Max: 20}, ErrInvalidStarting},
+		{"huge step", Config{Type: TypeNumericIncremental, Step: -pow10(18) - 1}, ErrInvalidStep},
+		{"extend sequence", Config{Type: TypeNumericIncremental, IncrMode: IncrModeSequence, OnExhausted: ExhaustedExtend}, ErrInvalidExhausted},
+		{"extend with max", Config{Type: TypeNumericIncremental, IncrMode: IncrModeFixed, Length: 4, Max: 5000, OnExhausted: ExhaustedExtend}, ErrInvalidExhausted},
+		{"unknown policy", Config{Type: TypeNumericIncremental, OnExhausted: "stop"}, ErrInvalidExhausted},
+		{"type 1", Config{Type: TypeNumericRandom, Length: 4, Max: 5000}, ErrInvalidBounds},
+		{"wrap with recycle", Config{Type: TypeNumericIncremental, OnExhausted: ExhaustedWrap, Recycle: true}, ErrInvalidRecycle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateConfig(tt.cfg); err != tt.wantErr {
+				t.Errorf("validateConfig() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
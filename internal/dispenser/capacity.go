@@ -0,0 +1,194 @@
+package dispenser
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrInvalidWarnAt = errors.New("invalid warn_at threshold")
+
+// 消耗速度的统计参数
+const (
+	meterInterval = time.Second                // 两次采样的最小间隔
+	meterWindow   = 5 * time.Minute            // 按最近这段时间的消耗计算速度
+	maxForecast   = 100 * 365 * 24 * time.Hour // 预计耗尽时间的上限，避免换算时溢出
+)
+
+// Capacity 号码空间的使用情况（Type 1、2）
+type Capacity struct {
+	Total     int64 // 号码空间总数（Type 2 为从 starting 到范围边界的号码数）
+	Remaining int64 // 剩余可发出的号码数（不含保留的号码，含回收池中的号码）
+}
+
+// Utilization 返回已使用的比例（0~1）
+func (c Capacity) Utilization() float64 {
+	if c.Total <= 0 {
+		return 0
+	}
+	return float64(c.Total-c.Remaining) / float64(c.Total)
+}
+
+// Forecast 按最近消耗速度预测的耗尽时间
+type Forecast struct {
+	Capacity
+	Rate       float64       // 最近的消耗速度（个/秒）
+	ExhaustsIn time.Duration // 预计多久后耗尽，没有消耗时为0（无法预测）
+}
+
+// bounded 检查配置的号码空间是否有限、会被用完
+// 去重窗口会淘汰旧号码，回绕和加长位数后可以继续发号，都不会耗尽
+func bounded(cfg Config) bool {
+	switch cfg.Type {
+	case TypeNumericRandom:
+		return cfg.UniqueCacheSize == 0
+	case TypeNumericIncremental:
+		return cfg.OnExhausted != ExhaustedWrap && cfg.OnExhausted != ExhaustedExtend
+	default:
+		return false
+	}
+}
+
+// countThrough 返回从 from 出发直到范围边界（含）还会经过的计数器数，from 已越界时为0
+func countThrough(cfg Config, from int64) int64 {
+	if !inBounds(cfg, from) {
+		return 0
+	}
+	lo, hi := counterBounds(cfg)
+	if cfg.Step < 0 {
+		return (lo-from)/cfg.Step + 1
+	}
+	return (hi-from)/cfg.Step + 1
+}
+
+// counterCapacity 计算 Type 2 的容量，usable 为计数器之后仍可使用的计数器数
+// 保留中的号码和计数器前方已认领的号码会被跳过，不计入剩余
+func counterCapacity(cfg Config, r *reservations, current, usable int64) Capacity {
+	return Capacity{
+		Total:     countThrough(cfg, cfg.Starting),
+		Remaining: max(0, usable-r.pendingCount(cfg, current)),
+	}
+}
+
+// ============================================
+// 各实现的容量
+// ============================================
+
+// Capacity 返回号码空间总数和剩余数
+func (d *Dispenser) Capacity() (Capacity, bool) {
+	if !bounded(d.config) {
+		return Capacity{}, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.config.Type == TypeNumericRandom {
+		total := pow10(d.config.Length) - pow10(d.config.Length-1)
+		used := d.used.count() + d.reservations.reservedCount()
+		return Capacity{Total: total, Remaining: max(0, total-used)}, true
+	}
+	return counterCapacity(d.config, d.reservations, d.current, countThrough(d.config, d.current)), true
+}
+
+// Capacity 返回号码空间总数和剩余数
+// 剩余数包括当前号段、退回的号段和预加载号段中尚未使用的部分，已租出的号段不计入
+func (sd *SegmentDispenser) Capacity() (Capacity, bool) {
+	if !bounded(sd.config) {
+		return Capacity{}, false
+	}
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	sd.nextSegmentMu.Lock()
+	defer sd.nextSegmentMu.Unlock()
+
+	usable := countBetween(sd.config, sd.currentNumber, sd.segmentEnd) + countThrough(sd.config, sd.allocEnd)
+	for _, seg := range sd.returned {
+		usable += countBetween(sd.config, seg.start, seg.end)
+	}
+	if sd.nextSegmentReady {
+		usable += countBetween(sd.config, sd.nextSegmentStart, sd.nextSegmentEnd)
+	}
+	return counterCapacity(sd.config, sd.reservations, sd.currentNumber, usable), true
+}
+
+// Capacity 返回号码空间总数和剩余数
+// 剩余数包括当前号段、退回的号段和预加载号段中尚未使用的部分，已租出的号段不计入
+func (osd *OptimizedSegmentDispenser) Capacity() (Capacity, bool) {
+	if !bounded(osd.config) {
+		return Capacity{}, false
+	}
+
+	osd.mu.Lock()
+	defer osd.mu.Unlock()
+	osd.nextSegmentMu.Lock()
+	defer osd.nextSegmentMu.Unlock()
+
+	usable := countBetween(osd.config, osd.currentNumber, osd.segmentEnd) + countThrough(osd.config, osd.allocEnd)
+	for _, seg := range osd.returned {
+		usable += countBetween(osd.config, seg.start, seg.end)
+	}
+	if osd.nextSegmentReady {
+		usable += countBetween(osd.config, osd.nextSegmentStart, osd.nextSegmentEnd)
+	}
+	return counterCapacity(osd.config, osd.reservations, osd.currentNumber, usable), true
+}
+
+// ============================================
+// 消耗速度
+// ============================================
+
+type usageSample struct {
+	at        time.Time
+	remaining int64
+}
+
+// UsageMeter 记录剩余容量随时间的变化，按最近的消耗速度预测耗尽时间
+// 采样由调用方驱动（发号、INFO 等），同一秒内的多次调用只记录一次
+type UsageMeter struct {
+	mu      sync.Mutex
+	samples []usageSample // 按时间排列，只保留统计窗口内的样本（以及窗口前的最后一个）
+}
+
+// NewUsageMeter creates an empty usage meter
+func NewUsageMeter() *UsageMeter {
+	return &UsageMeter{}
+}
+
+// Due 检查距上次采样是否已超过采样间隔（用于发号路径上节流）
+func (m *UsageMeter) Due() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.samples) == 0 || time.Since(m.samples[len(m.samples)-1].at) >= meterInterval
+}
+
+// Observe 记录一次容量快照，返回按最近消耗速度的预测
+func (m *UsageMeter) Observe(c Capacity) Forecast {
+	return m.observe(c, time.Now())
+}
+
+func (m *UsageMeter) observe(c Capacity, now time.Time) Forecast {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if n := len(m.samples); n == 0 || now.Sub(m.samples[n-1].at) >= meterInterval {
+		m.samples = append(m.samples, usageSample{at: now, remaining: c.Remaining})
+	}
+
+	// 丢弃窗口外的样本，保留窗口前的最后一个作为起点
+	k := 0
+	for k+1 < len(m.samples) && now.Sub(m.samples[k+1].at) >= meterWindow {
+		k++
+	}
+	m.samples = m.samples[k:]
+
+	f := Forecast{Capacity: c}
+	oldest := m.samples[0]
+	// 回收、解除保留和周期重置会让剩余数增加，此时不按负速度预测
+	if elapsed := now.Sub(oldest.at).Seconds(); elapsed > 0 && oldest.remaining > c.Remaining {
+		f.Rate = float64(oldest.remaining-c.Remaining) / elapsed
+		f.ExhaustsIn = time.Duration(min(float64(c.Remaining)/f.Rate, maxForecast.Seconds()) * float64(time.Second))
+	}
+	return f
+}
@@ -0,0 +1,106 @@
+package dispenser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCapacity_Incremental(t *testing.T) {
+	for _, strategy := range []PersistenceStrategy{StrategyMemory, StrategyPreBase, StrategyPreClose} {
+		t.Run(string(strategy), func(t *testing.T) {
+			factory := NewDispenserFactory(nil)
+			d, err := factory.CreateDispenser("order_id", Config{
+				Type:        TypeNumericIncremental,
+				IncrMode:    IncrModeFixed,
+				Length:      2,
+				Starting:    10,
+				Step:        2,
+				SegmentSize: 7,
+				AutoDisk:    strategy,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create dispenser: %v", err)
+			}
+			defer d.Shutdown()
+
+			// 10, 12, ..., 98 共45个号码
+			if _, err := d.NextN(5); err != nil {
+				t.Fatalf("Failed to generate numbers: %v", err)
+			}
+			if _, err := d.(ReservableDispenser).Reserve([]ReservedRange{{Start: 50, End: 54}}); err != nil {
+				t.Fatalf("Failed to reserve: %v", err)
+			}
+
+			c, ok := d.(BoundedDispenser).Capacity()
+			if !ok {
+				t.Fatal("Expected bounded capacity")
+			}
+			if c.Total != 45 || c.Remaining != 37 {
+				t.Errorf("Expected 37 of 45 remaining, got %+v", c)
+			}
+		})
+	}
+}
+
+func TestCapacity_Random(t *testing.T) {
+	d, err := NewDispenser(Config{Type: TypeNumericRandom, Length: 2})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+	if _, err := d.NextN(30); err != nil {
+		t.Fatalf("Failed to generate numbers: %v", err)
+	}
+
+	c, ok := d.Capacity()
+	if !ok || c.Total != 90 || c.Remaining != 60 {
+		t.Errorf("Expected 60 of 90 remaining, got %+v", c)
+	}
+	if u := c.Utilization(); u < 0.33 || u > 0.34 {
+		t.Errorf("Expected utilization 1/3, got %f", u)
+	}
+}
+
+func TestCapacity_Unbounded(t *testing.T) {
+	configs := []Config{
+		{Type: TypeNumericRandom, Length: 6, UniqueCacheSize: 100},
+		{Type: TypeNumericIncremental, IncrMode: IncrModeFixed, Length: 4, OnExhausted: ExhaustedWrap},
+		{Type: TypeAlphanumericRandom, Length: 8},
+	}
+	for _, cfg := range configs {
+		d, err := NewDispenser(cfg)
+		if err != nil {
+			t.Fatalf("Failed to create dispenser: %v", err)
+		}
+		if _, ok := d.Capacity(); ok {
+			t.Errorf("Expected unbounded capacity for %+v", cfg)
+		}
+		cfg.WarnAt = 0.9
+		if err := validateConfig(cfg); err != ErrInvalidWarnAt {
+			t.Errorf("Expected ErrInvalidWarnAt for %+v, got %v", cfg, err)
+		}
+	}
+}
+
+func TestUsageMeter(t *testing.T) {
+	m := NewUsageMeter()
+	start := time.Now()
+
+	// 首次采样无法计算速度
+	f := m.observe(Capacity{Total: 1000, Remaining: 1000}, start)
+	if f.Rate != 0 || f.ExhaustsIn != 0 {
+		t.Errorf("Expected no forecast from a single sample, got %+v", f)
+	}
+
+	// 10秒消耗100个，剩余800个约80秒后耗尽
+	f = m.observe(Capacity{Total: 1000, Remaining: 900}, start.Add(10*time.Second))
+	f = m.observe(Capacity{Total: 1000, Remaining: 800}, start.Add(20*time.Second))
+	if f.Rate != 10 || f.ExhaustsIn != 80*time.Second {
+		t.Errorf("Expected 10/s exhausting in 80s, got %+v", f)
+	}
+
+	// 窗口外的样本被丢弃，只按最近的消耗计算
+	f = m.observe(Capacity{Total: 1000, Remaining: 800}, start.Add(20*time.Second+meterWindow))
+	if f.Rate != 0 {
+		t.Errorf("Expected idle rate after a quiet window, got %+v", f)
+	}
+}
@@ -0,0 +1,342 @@
+package dispenser
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCheckDigit  = errors.New("invalid check digit algorithm")
+	ErrCheckDigitMismatch = errors.New("check digit mismatch")
+	ErrOutOfRange         = errors.New("value out of range")
+)
+
+// CheckDigit represents the check digit algorithm for Types 1 and 2
+type CheckDigit string
+
+const (
+	CheckDigitLuhn     CheckDigit = "luhn"     // Luhn（模10），银行卡号常用
+	CheckDigitDamm     CheckDigit = "damm"     // Damm，可检出所有单个数字错误和相邻换位
+	CheckDigitVerhoeff CheckDigit = "verhoeff" // Verhoeff，基于二面体群 D5
+	CheckDigitMod97    CheckDigit = "mod97"    // ISO 7064 MOD 97-10，两位校验码（IBAN 使用）
+)
+
+// checkDigitLen 返回校验码位数
+func checkDigitLen(alg CheckDigit) int {
+	switch alg {
+	case "":
+		return 0
+	case CheckDigitMod97:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// validCheckDigit 检查算法名称是否有效
+func validCheckDigit(alg CheckDigit) bool {
+	switch alg {
+	case CheckDigitLuhn, CheckDigitDamm, CheckDigitVerhoeff, CheckDigitMod97:
+		return true
+	}
+	return false
+}
+
+// computeCheckDigit 计算纯数字 payload 的校验码
+func computeCheckDigit(alg CheckDigit, payload string) string {
+	switch alg {
+	case CheckDigitLuhn:
+		return luhn(payload)
+	case CheckDigitDamm:
+		return damm(payload)
+	case CheckDigitVerhoeff:
+		return verhoeff(payload)
+	case CheckDigitMod97:
+		return mod97(payload)
+	}
+	return ""
+}
+
+// appendCheckDigit 为生成的号码追加校验码，未配置算法时原样返回
+func appendCheckDigit(alg CheckDigit, num string) string {
+	if alg == "" {
+		return num
+	}
+	return num + computeCheckDigit(alg, num)
+}
+
+// withCheckDigit 为 Type 1、2 生成的号码追加校验码
+func (d *Dispenser) withCheckDigit(num string, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	return appendCheckDigit(d.config.CheckDigit, num), nil
+}
+
+// ============================================
+// 校验算法
+// ============================================
+
+// luhn 从 payload 最右一位开始隔位乘2（校验码追加在最右侧）
+func luhn(payload string) string {
+	sum := 0
+	double := true
+	for i := len(payload) - 1; i >= 0; i-- {
+		d := int(payload[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return strconv.Itoa((10 - sum%10) % 10)
+}
+
+// dammTable 10阶全反对称拟群
+var dammTable = [10][10]int{
+	{0, 3, 1, 7, 5, 9, 8, 6, 4, 2},
+	{7, 0, 9, 2, 1, 5, 4, 8, 6, 3},
+	{4, 2, 0, 6, 8, 7, 1, 3, 5, 9},
+	{1, 7, 5, 0, 9, 8, 3, 4, 2, 6},
+	{6, 1, 2, 3, 0, 4, 5, 9, 7, 8},
+	{3, 6, 7, 4, 2, 0, 9, 5, 8, 1},
+	{5, 8, 6, 9, 7, 2, 0, 1, 3, 4},
+	{8, 9, 4, 5, 3, 6, 2, 0, 1, 7},
+	{9, 4, 3, 8, 6, 1, 7, 2, 0, 5},
+	{2, 5, 8, 1, 4, 3, 6, 7, 9, 0},
+}
+
+func damm(payload string) string {
+	interim := 0
+	for i := 0; i < len(payload); i++ {
+		interim = dammTable[interim][payload[i]-'0']
+	}
+	return strconv.Itoa(interim)
+}
+
+// Verhoeff 乘法表、置换表和逆元表
+var (
+	verhoeffD = [10][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+		{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+		{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+		{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+		{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+		{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+		{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+		{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+		{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+	}
+	verhoeffP = [8][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+		{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+		{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+		{9, 4, 5, 3, 1, 2, 6, 8, 7, 0},
+		{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+		{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+		{7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+	}
+	verhoeffInv = [10]int{0, 4, 3, 2, 1, 5, 6, 7, 8, 9}
+)
+
+func verhoeff(payload string) string {
+	c := 0
+	for i := 0; i < len(payload); i++ {
+		d := payload[len(payload)-1-i] - '0'
+		c = verhoeffD[c][verhoeffP[(i+1)%8][d]]
+	}
+	return strconv.Itoa(verhoeffInv[c])
+}
+
+// mod97 ISO 7064 MOD 97-10：校验码使 payload+校验码 对97取模等于1
+func mod97(payload string) string {
+	r := 0
+	for i := 0; i < len(payload); i++ {
+		r = (r*10 + int(payload[i]-'0')) % 97
+	}
+	r = r * 100 % 97
+	check := 98 - r
+	if check < 10 {
+		return "0" + strconv.Itoa(check)
+	}
+	return strconv.Itoa(check)
+}
+
+// ============================================
+// 号码校验
+// ============================================
+
+// Validate 按发号器配置校验号码：字符集、长度、取值范围和校验码
+// 返回 nil 表示号码可能由该发号器生成
+func Validate(cfg Config, value string) error {
+	switch cfg.Type {
+	case TypeNumericRandom, TypeNumericIncremental:
+		return validateNumeric(cfg, value)
+
+	case TypeAlphanumericRandom:
+		// Crockford Base32 大小写不敏感
+		if cfg.Charset == CharsetCrockford {
+			value = strings.ToUpper(value)
+		}
+		chars := alphabet(cfg)
+		if len(value) != cfg.Length {
+			return ErrInvalidID
+		}
+		for i := 0; i < len(value); i++ {
+			if strings.IndexByte(chars, value[i]) < 0 {
+				return ErrInvalidID
+			}
+		}
+		return nil
+
+	case TypeUUID:
+		if cfg.UUIDVersion == 0 || cfg.UUIDVersion == UUIDVersion4 {
+			return validateUUIDv4(cfg, value)
+		}
+		_, err := Decode(cfg, value)
+		return err
+
+	default:
+		_, err := Decode(cfg, value)
+		return err
+	}
+}
+
+// ValidateID 校验发号器输出的号码，配置了格式模板时先核对模板的固定部分再校验原始值
+func ValidateID(d NumberDispenser, id string) error {
+	if fd, ok := d.(*FormattedDispenser); ok {
+		if !fd.matchTemplate(id) {
+			return ErrInvalidID
+		}
+		value, err := fd.ExtractValue(id)
+		if err != nil {
+			return err
+		}
+		id = value
+	}
+	return Validate(d.GetConfig(), id)
+}
+
+// numericPayload 去掉 Type 1、2 号码的校验码和日期前缀，返回计数器部分
+func numericPayload(cfg Config, value string) (string, error) {
+	if !isDigits(value) {
+		return "", ErrInvalidID
+	}
+
+	// 拆出校验码
+	n := checkDigitLen(cfg.CheckDigit)
+	if len(value) <= n {
+		return "", ErrInvalidID
+	}
+	payload := value[:len(value)-n]
+	if n > 0 && computeCheckDigit(cfg.CheckDigit, payload) != value[len(value)-n:] {
+		return "", ErrCheckDigitMismatch
+	}
+
+	// 周期重置的日期前缀
+	if cfg.ResetPeriod != "" {
+		prefix := len(formatDate(time.Now(), dateFormat(cfg)))
+		if len(payload) <= prefix {
+			return "", ErrInvalidID
+		}
+		payload = payload[prefix:]
+	}
+
+	fixed := cfg.Type == TypeNumericRandom || fixedWidth(cfg)
+	if fixed && len(payload) != cfg.Length {
+		// extend_length 加长后的号码没有前导零
+		if cfg.OnExhausted != ExhaustedExtend || len(payload) < cfg.Length || payload[0] == '0' {
+			return "", ErrInvalidID
+		}
+	}
+	return payload, nil
+}
+
+// numericCounter 解析 Type 1、2 号码对应的数值：Type 1 为号码本身，Type 2 为（置换前的）计数器
+func numericCounter(cfg Config, value string) (int64, error) {
+	payload, err := numericPayload(cfg, value)
+	if err != nil {
+		return 0, err
+	}
+
+	num, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return 0, ErrOutOfRange
+	}
+
+	if cfg.Type == TypeNumericIncremental && cfg.IncrMode == IncrModePermuted {
+		num = newFeistel(cfg).decrypt(num)
+	}
+	return num, nil
+}
+
+// validateNumeric 校验 Type 1、2 的号码
+func validateNumeric(cfg Config, value string) error {
+	num, err := numericCounter(cfg, value)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Type == TypeNumericRandom {
+		if num < pow10(cfg.Length-1) {
+			return ErrOutOfRange
+		}
+		return nil
+	}
+
+	step := cfg.Step
+	if step == 0 {
+		step = 1
+	}
+	cfg.Step = step
+	if !inBounds(cfg, num) {
+		return ErrOutOfRange
+	}
+	// 计数器从 starting 出发按步长前进，回绕后从回绕起点重新出发
+	if reached(cfg, num, cfg.Starting) && (num-cfg.Starting)%step == 0 {
+		return nil
+	}
+	if start, ok := wrapStart(cfg); ok && (num-start)%step == 0 {
+		return nil
+	}
+	return ErrOutOfRange
+}
+
+// validateUUIDv4 校验随机UUID的格式和版本位
+func validateUUIDv4(cfg Config, value string) error {
+	compact := strings.ReplaceAll(value, "-", "")
+	if cfg.UUIDFormat == UUIDFormatCompact && compact != value {
+		return ErrInvalidID
+	}
+	if cfg.UUIDFormat != UUIDFormatCompact && (len(value) != 36 ||
+		value[8] != '-' || value[13] != '-' || value[18] != '-' || value[23] != '-') {
+		return ErrInvalidID
+	}
+	if len(compact) != 32 || strings.Trim(compact, "0123456789abcdef") != "" {
+		return ErrInvalidID
+	}
+	if compact[12] != '4' {
+		return ErrInvalidID
+	}
+	return nil
+}
+
+// isDigits 检查字符串是否全为数字
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
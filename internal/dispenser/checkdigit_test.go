@@ -0,0 +1,152 @@
+package dispenser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComputeCheckDigit(t *testing.T) {
+	tests := []struct {
+		alg      CheckDigit
+		payload  string
+		expected string
+	}{
+		{CheckDigitLuhn, "7992739871", "3"},
+		{CheckDigitDamm, "572", "4"},
+		{CheckDigitVerhoeff, "236", "3"},
+		{CheckDigitMod97, "794", "44"},
+	}
+
+	for _, tt := range tests {
+		if got := computeCheckDigit(tt.alg, tt.payload); got != tt.expected {
+			t.Errorf("%s(%s) = %s, want %s", tt.alg, tt.payload, got, tt.expected)
+		}
+	}
+}
+
+func TestCheckDigit_Generate(t *testing.T) {
+	for _, alg := range []CheckDigit{CheckDigitLuhn, CheckDigitDamm, CheckDigitVerhoeff, CheckDigitMod97} {
+		cfg := Config{
+			Type:       TypeNumericIncremental,
+			IncrMode:   IncrModeFixed,
+			Length:     8,
+			Starting:   1,
+			CheckDigit: alg,
+		}
+		d, err := NewDispenser(cfg)
+		if err != nil {
+			t.Fatalf("Failed to create dispenser: %v", err)
+		}
+
+		for i := 0; i < 20; i++ {
+			num, _ := d.Next()
+			if len(num) != 8+checkDigitLen(alg) || !strings.HasPrefix(num, "000000") {
+				t.Fatalf("%s: unexpected number %s", alg, num)
+			}
+			if err := Validate(cfg, num); err != nil {
+				t.Errorf("%s: generated %s failed validation: %v", alg, num, err)
+			}
+
+			// 单个数字输入错误应被检出
+			typo := []byte(num)
+			typo[7] = '0' + (typo[7]-'0'+1)%10
+			if err := Validate(cfg, string(typo)); err != ErrCheckDigitMismatch {
+				t.Errorf("%s: typo %s not detected", alg, typo)
+			}
+		}
+	}
+}
+
+func TestCheckDigit_RandomAndSegment(t *testing.T) {
+	random := Config{Type: TypeNumericRandom, Length: 6, UniqueCheck: true, CheckDigit: CheckDigitLuhn}
+	d, err := NewDispenser(random)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+	nums, err := d.NextN(10)
+	if err != nil {
+		t.Fatalf("Failed to generate batch: %v", err)
+	}
+	for _, num := range nums {
+		if len(num) != 7 || Validate(random, num) != nil {
+			t.Errorf("Invalid random number %s", num)
+		}
+	}
+
+	segment := Config{
+		Type:       TypeNumericIncremental,
+		IncrMode:   IncrModeSequence,
+		Starting:   100,
+		Step:       1,
+		CheckDigit: CheckDigitDamm,
+		AutoDisk:   StrategyPreBase,
+	}
+	sd, err := NewDispenserFactory(nil).CreateDispenser("seg", segment)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+	defer sd.Shutdown()
+
+	if num, _ := sd.Next(); num != "1007" {
+		t.Errorf("Expected 1007, got %s", num)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	fixed := Config{Type: TypeNumericIncremental, IncrMode: IncrModeFixed, Length: 4, Starting: 10, Step: 5}
+	random := Config{Type: TypeNumericRandom, Length: 4}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		value   string
+		wantErr error
+	}{
+		{"fixed in range", fixed, "0015", nil},
+		{"fixed below starting", fixed, "0005", ErrOutOfRange},
+		{"fixed off step", fixed, "0016", ErrOutOfRange},
+		{"fixed wrong length", fixed, "015", ErrInvalidID},
+		{"not numeric", fixed, "00a5", ErrInvalidID},
+		{"random in range", random, "1234", nil},
+		{"random leading zero", random, "0123", ErrOutOfRange},
+		{"hex", Config{Type: TypeAlphanumericRandom, Length: 4, Charset: CharsetHex}, "a0f9", nil},
+		{"hex bad char", Config{Type: TypeAlphanumericRandom, Length: 4, Charset: CharsetHex}, "a0g9", ErrInvalidID},
+		{"uuid v4", Config{Type: TypeUUID}, "550e8400-e29b-41d4-a716-446655440000", nil},
+		{"uuid wrong version", Config{Type: TypeUUID}, "550e8400-e29b-11d4-a716-446655440000", ErrInvalidID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.cfg, tt.value); err != tt.wantErr {
+				t.Errorf("Validate(%s) error = %v, want %v", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateID_Format(t *testing.T) {
+	cfg := Config{
+		Type:       TypeNumericIncremental,
+		IncrMode:   IncrModeFixed,
+		Length:     6,
+		Starting:   1,
+		CheckDigit: CheckDigitVerhoeff,
+		Format:     "CARD-{seq:8}",
+		AutoDisk:   StrategyMemory,
+	}
+	d, err := NewDispenserFactory(nil).CreateDispenser("cards", cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	num, _ := d.Next()
+	if err := ValidateID(d, num); err != nil {
+		t.Errorf("ValidateID(%s) = %v", num, err)
+	}
+	if err := ValidateID(d, "GIFT"+num[4:]); err != ErrInvalidID {
+		t.Errorf("Expected prefix mismatch for %s, got %v", "GIFT"+num[4:], err)
+	}
+	if err := ValidateID(d, "CARD-1"+num[6:]); err != ErrInvalidID {
+		t.Errorf("Expected padding mismatch, got %v", err)
+	}
+}
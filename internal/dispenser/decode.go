@@ -0,0 +1,186 @@
+package dispenser
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNotDecodable = errors.New("dispenser type is not decodable")
+	ErrInvalidID    = errors.New("invalid id")
+)
+
+// DecodedID 解码结果
+// 只有与类型相关的字段有意义：
+// Snowflake: Timestamp, DatacenterID, MachineID, Sequence
+// UUID v1/v6: Timestamp, Version, ClockSeq, DatacenterID, MachineID
+// UUID v7: Timestamp, Version, Sequence（12位计数器）
+// ULID: Timestamp
+// Type 2 permuted: Counter（置换前的计数器）
+type DecodedID struct {
+	Type         Type
+	Timestamp    int64 // Unix毫秒
+	Version      int   // UUID版本
+	DatacenterID int64
+	MachineID    int64
+	Sequence     int64
+	ClockSeq     int64
+	Counter      int64
+}
+
+// Decode 使用发号器配置（纪元、位布局等）将ID还原为各组成部分
+// 随机类型和 permuted 以外的自增类型返回 ErrNotDecodable
+func Decode(cfg Config, id string) (DecodedID, error) {
+	switch cfg.Type {
+	case TypeNumericIncremental:
+		if cfg.IncrMode != IncrModePermuted {
+			return DecodedID{}, ErrNotDecodable
+		}
+		return decodePermuted(cfg, id)
+	case TypeSnowflake:
+		return decodeSnowflake(cfg, id)
+	case TypeUUID:
+		return decodeUUID(cfg, id)
+	case TypeULID:
+		return decodeULID(id)
+	default:
+		return DecodedID{}, ErrNotDecodable
+	}
+}
+
+// decodePermuted 用置换密钥将 permuted 模式的号码还原为计数器
+func decodePermuted(cfg Config, id string) (DecodedID, error) {
+	payload, err := numericPayload(cfg, id)
+	if err != nil {
+		return DecodedID{}, err
+	}
+	value, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return DecodedID{}, ErrInvalidID
+	}
+
+	return DecodedID{
+		Type:    TypeNumericIncremental,
+		Counter: newFeistel(cfg).decrypt(value),
+	}, nil
+}
+
+// decodeSnowflake 按配置的位布局拆解Snowflake ID
+func decodeSnowflake(cfg Config, id string) (DecodedID, error) {
+	value, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || value < 0 {
+		return DecodedID{}, ErrInvalidID
+	}
+
+	// 兼容未经 NewDispenser 填充默认值的配置
+	_, datacenterBits, workerBits, sequenceBits := snowflakeLayout(cfg)
+	epoch := cfg.Epoch
+	if epoch == 0 {
+		epoch = DefaultSnowflakeEpoch
+	}
+
+	workerShift := sequenceBits
+	datacenterShift := workerShift + workerBits
+	timestampShift := datacenterShift + datacenterBits
+
+	return DecodedID{
+		Type:         TypeSnowflake,
+		Timestamp:    value>>timestampShift + epoch,
+		DatacenterID: (value >> datacenterShift) & (int64(1)<<datacenterBits - 1),
+		MachineID:    (value >> workerShift) & (int64(1)<<workerBits - 1),
+		Sequence:     value & (int64(1)<<sequenceBits - 1),
+	}, nil
+}
+
+// decodeUUID 解码时间有序的UUID（v1/v6/v7）
+func decodeUUID(cfg Config, id string) (DecodedID, error) {
+	switch cfg.UUIDVersion {
+	case UUIDVersion1, UUIDVersion6, UUIDVersion7:
+	default:
+		// v4 为纯随机，不包含可解码信息
+		return DecodedID{}, ErrNotDecodable
+	}
+
+	raw, err := hex.DecodeString(strings.ReplaceAll(id, "-", ""))
+	if err != nil || len(raw) != 16 {
+		return DecodedID{}, ErrInvalidID
+	}
+
+	version := int(raw[6] >> 4)
+	if version != cfg.UUIDVersion {
+		return DecodedID{}, ErrInvalidID
+	}
+
+	decoded := DecodedID{Type: TypeUUID, Version: version}
+
+	if version == UUIDVersion7 {
+		decoded.Timestamp = int64(raw[0])<<40 | int64(raw[1])<<32 | int64(raw[2])<<24 |
+			int64(raw[3])<<16 | int64(raw[4])<<8 | int64(raw[5])
+		decoded.Sequence = int64(raw[6]&0x0F)<<8 | int64(raw[7])
+		return decoded, nil
+	}
+
+	// v1/v6: 60位时间戳（自1582-10-15起的100纳秒间隔数）
+	var ts int64
+	if version == UUIDVersion6 {
+		ts = int64(raw[0])<<52 | int64(raw[1])<<44 | int64(raw[2])<<36 | int64(raw[3])<<28 |
+			int64(raw[4])<<20 | int64(raw[5])<<12 | int64(raw[6]&0x0F)<<8 | int64(raw[7])
+	} else {
+		ts = int64(raw[6]&0x0F)<<56 | int64(raw[7])<<48 | int64(raw[4])<<40 | int64(raw[5])<<32 |
+			int64(raw[0])<<24 | int64(raw[1])<<16 | int64(raw[2])<<8 | int64(raw[3])
+	}
+	decoded.Timestamp = (ts - uuidGregorianOffset) / 10000
+	decoded.ClockSeq = int64(raw[8]&0x3F)<<8 | int64(raw[9])
+	decoded.DatacenterID = int64(raw[11])
+	decoded.MachineID = int64(raw[12])<<24 | int64(raw[13])<<16 | int64(raw[14])<<8 | int64(raw[15])
+
+	return decoded, nil
+}
+
+// decodeULID 解码ULID的48位时间戳
+func decodeULID(id string) (DecodedID, error) {
+	ulid, err := parseULID(id)
+	if err != nil {
+		return DecodedID{}, err
+	}
+
+	timestamp := int64(ulid[0])<<40 | int64(ulid[1])<<32 | int64(ulid[2])<<24 |
+		int64(ulid[3])<<16 | int64(ulid[4])<<8 | int64(ulid[5])
+
+	return DecodedID{Type: TypeULID, Timestamp: timestamp}, nil
+}
+
+// parseULID 将26位 Crockford Base32 还原为128位ULID（encodeULID 的逆过程）
+// 按 Crockford 规范不区分大小写，并将 O 视为 0、I/L 视为 1
+func parseULID(id string) ([16]byte, error) {
+	var ulid [16]byte
+	if len(id) != 26 {
+		return ulid, ErrInvalidID
+	}
+
+	for i := 0; i < 26; i++ {
+		c := strings.ToUpper(string(id[i]))
+		switch c {
+		case "O":
+			c = "0"
+		case "I", "L":
+			c = "1"
+		}
+		v := strings.Index(crockfordAlphabet, c)
+		if v < 0 || (i == 0 && v > 7) {
+			// 首字符只携带3位，超过7会溢出128位
+			return ulid, ErrInvalidID
+		}
+
+		for b := 0; b < 5; b++ {
+			bit := i*5 + b - 2
+			if bit >= 0 && v&(0x10>>b) != 0 {
+				ulid[bit/8] |= 0x80 >> (bit % 8)
+			}
+		}
+	}
+
+	return ulid, nil
+}
@@ -0,0 +1,126 @@
+package dispenser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecode_Snowflake(t *testing.T) {
+	cfg := Config{
+		Type:           TypeSnowflake,
+		Epoch:          1700000000000,
+		TimestampBits:  41,
+		DatacenterBits: 3,
+		WorkerBits:     9,
+		SequenceBits:   10,
+		DatacenterID:   6,
+		MachineID:      257,
+	}
+
+	d, err := NewDispenser(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	before := time.Now().UnixMilli()
+	id, err := d.Next()
+	if err != nil {
+		t.Fatalf("Failed to generate number: %v", err)
+	}
+
+	decoded, err := Decode(d.GetConfig(), id)
+	if err != nil {
+		t.Fatalf("Failed to decode %s: %v", id, err)
+	}
+
+	if decoded.DatacenterID != 6 || decoded.MachineID != 257 || decoded.Sequence != 0 {
+		t.Errorf("Unexpected decoded parts: %+v", decoded)
+	}
+	if decoded.Timestamp < before || decoded.Timestamp > time.Now().UnixMilli() {
+		t.Errorf("Decoded timestamp %d out of range", decoded.Timestamp)
+	}
+}
+
+func TestDecode_TimeOrderedUUID(t *testing.T) {
+	for _, version := range []int{UUIDVersion1, UUIDVersion6, UUIDVersion7} {
+		cfg := Config{
+			Type:         TypeUUID,
+			UUIDVersion:  version,
+			DatacenterID: 9,
+			MachineID:    123456,
+		}
+
+		d, err := NewDispenser(cfg)
+		if err != nil {
+			t.Fatalf("Failed to create dispenser: %v", err)
+		}
+
+		before := time.Now().UnixMilli()
+		id, err := d.Next()
+		if err != nil {
+			t.Fatalf("Failed to generate UUID: %v", err)
+		}
+
+		decoded, err := Decode(d.GetConfig(), id)
+		if err != nil {
+			t.Fatalf("Failed to decode v%d %s: %v", version, id, err)
+		}
+
+		if decoded.Version != version {
+			t.Errorf("Expected version %d, got %d", version, decoded.Version)
+		}
+		if decoded.Timestamp < before-1 || decoded.Timestamp > time.Now().UnixMilli() {
+			t.Errorf("v%d decoded timestamp %d out of range", version, decoded.Timestamp)
+		}
+		if version != UUIDVersion7 && (decoded.DatacenterID != 9 || decoded.MachineID != 123456) {
+			t.Errorf("v%d unexpected node: %+v", version, decoded)
+		}
+	}
+}
+
+func TestDecode_ULID(t *testing.T) {
+	d, err := NewDispenser(Config{Type: TypeULID})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	before := time.Now().UnixMilli()
+	id, err := d.Next()
+	if err != nil {
+		t.Fatalf("Failed to generate ULID: %v", err)
+	}
+
+	decoded, err := Decode(d.GetConfig(), id)
+	if err != nil {
+		t.Fatalf("Failed to decode %s: %v", id, err)
+	}
+	if decoded.Timestamp < before || decoded.Timestamp > time.Now().UnixMilli() {
+		t.Errorf("Decoded timestamp %d out of range", decoded.Timestamp)
+	}
+
+	// 解码与编码互逆
+	raw, err := parseULID(id)
+	if err != nil {
+		t.Fatalf("Failed to parse ULID: %v", err)
+	}
+	if encodeULID(raw) != id {
+		t.Errorf("Round trip mismatch: %s != %s", encodeULID(raw), id)
+	}
+
+	if _, err := Decode(d.GetConfig(), "8ZZZZZZZZZZZZZZZZZZZZZZZZZ"); err != ErrInvalidID {
+		t.Errorf("Expected ErrInvalidID for overflowing ULID, got %v", err)
+	}
+}
+
+func TestDecode_NotDecodable(t *testing.T) {
+	for _, cfg := range []Config{
+		{Type: TypeNumericRandom, Length: 6},
+		{Type: TypeNumericIncremental},
+		{Type: TypeAlphanumericRandom, Length: 8},
+		{Type: TypeUUID, UUIDVersion: UUIDVersion4},
+	} {
+		if _, err := Decode(cfg, "123456"); err != ErrNotDecodable {
+			t.Errorf("Type %d: expected ErrNotDecodable, got %v", cfg.Type, err)
+		}
+	}
+}
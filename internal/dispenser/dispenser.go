@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	mathrand "math/rand"
 	"sync"
 	"time"
@@ -18,7 +19,19 @@ var (
 	ErrInvalidMachine  = errors.New("invalid machine id")
 	ErrNumberExhausted = errors.New("number range exhausted")
 	ErrInvalidCharset  = errors.New("invalid charset")
+	ErrInvalidAlphabet = errors.New("invalid custom alphabet")
+	ErrInvalidRNG      = errors.New("invalid rng")
 	ErrInvalidFormat   = errors.New("invalid format")
+	ErrInvalidCount    = errors.New("invalid count")
+	ErrInvalidVersion  = errors.New("invalid uuid version")
+	ErrInvalidLayout   = errors.New("invalid snowflake bit layout")
+	ErrInvalidEpoch    = errors.New("invalid epoch")
+	ErrInvalidRollback = errors.New("invalid clock rollback policy")
+	ErrInvalidSegment  = errors.New("invalid segment settings")
+	ErrInvalidPeriod   = errors.New("invalid reset period or date format")
+	ErrInvalidTimezone = errors.New("invalid timezone")
+
+	ErrClockMovedBackwards = errors.New("clock moved backwards")
 )
 
 // Type represents the dispenser type
@@ -27,9 +40,10 @@ type Type int
 const (
 	TypeNumericRandom      Type = 1 // 纯数字随机（去重缓存）
 	TypeNumericIncremental Type = 2 // 纯数字自增
-	TypeAlphanumericRandom Type = 3 // 字符随机（hex/base62）
+	TypeAlphanumericRandom Type = 3 // 字符随机（内置或自定义字符集）
 	TypeSnowflake          Type = 4 // 雪花ID
 	TypeUUID               Type = 5 // 标准UUID
+	TypeULID               Type = 6 // ULID（时间有序，Crockford Base32）
 )
 
 // IncrementalMode represents the incremental mode
@@ -38,14 +52,18 @@ type IncrementalMode string
 const (
 	IncrModeFixed    IncrementalMode = "fixed"    // 固定位数
 	IncrModeSequence IncrementalMode = "sequence" // 普通序列
+	IncrModePermuted IncrementalMode = "permuted" // 固定位数，计数器经密钥置换后输出（不可猜测）
 )
 
 // Charset represents the character set for Type 3
 type Charset string
 
 const (
-	CharsetHex    Charset = "hex"    // 十六进制 (0-9, a-f)
-	CharsetBase62 Charset = "base62" // Base62 (0-9, a-z, A-Z)
+	CharsetHex       Charset = "hex"       // 十六进制 (0-9, a-f)
+	CharsetBase62    Charset = "base62"    // Base62 (0-9, a-z, A-Z)
+	CharsetBase58    Charset = "base58"    // Base58，去掉易混淆的 0 O I l
+	CharsetCrockford Charset = "crockford" // Crockford Base32，大小写不敏感
+	CharsetCustom    Charset = "custom"    // 自定义字符表（custom_alphabet）
 )
 
 // UUIDFormat represents the UUID format for Type 5
@@ -56,20 +74,76 @@ const (
 	UUIDFormatCompact  UUIDFormat = "compact"  // 紧凑格式：550e8400e29b41d4a716446655440000
 )
 
+// UUID versions supported by Type 5
+const (
+	UUIDVersion1 = 1 // 基于时间 + 节点标识（RFC 9562 v1）
+	UUIDVersion4 = 4 // 随机（默认）
+	UUIDVersion6 = 6 // 字段重排的 v1，按时间可排序
+	UUIDVersion7 = 7 // Unix 毫秒时间戳 + 单调计数器
+)
+
+// ClockRollbackPolicy represents how time-based types handle a clock moving backwards
+type ClockRollbackPolicy string
+
+const (
+	RollbackWait   ClockRollbackPolicy = "wait"   // 回拨不超过 rollback_wait_ms 时等待时钟追上，超出则报错（默认）
+	RollbackBorrow ClockRollbackPolicy = "borrow" // 沿用上次时间戳作为逻辑时钟继续发号
+	RollbackError  ClockRollbackPolicy = "error"  // 直接返回 ErrClockMovedBackwards
+)
+
+// defaultRollbackWaitMs 默认回拨最大等待时间（毫秒）
+const defaultRollbackWaitMs = 5
+
+// maxRollbackWaitMs 回拨等待时间上限（毫秒），取号前在锁外等待
+const maxRollbackWaitMs = 1000
+
+// lockedRollbackWaitMs 持有锁时最多等待的毫秒数（批量取号中途遇到回拨），超出则报错
+const lockedRollbackWaitMs = 5
+
 // Config represents the configuration of a dispenser
 type Config struct {
-	Type            Type                `json:"type"`                        // 发号器类型
-	Length          int                 `json:"length,omitempty"`            // 长度（Type 1, 2 fixed, 3 使用）
-	Starting        int64               `json:"starting,omitempty"`          // 起始值（Type 2 使用）
-	Step            int64               `json:"step,omitempty"`              // 步长（Type 2 使用）
-	MachineID       int64               `json:"machine_id,omitempty"`        // 机器ID（Type 4 使用）
-	DatacenterID    int64               `json:"datacenter_id,omitempty"`     // 数据中心ID（Type 4 使用）
-	IncrMode        IncrementalMode     `json:"incr_mode,omitempty"`         // 自增模式（Type 2 使用）
-	Charset         Charset             `json:"charset,omitempty"`           // 字符集（Type 3 使用）
-	UUIDFormat      UUIDFormat          `json:"uuid_format,omitempty"`       // UUID格式（Type 5 使用）
-	AutoDisk        PersistenceStrategy `json:"auto_disk,omitempty"`         // 持久化策略
-	UniqueCheck     bool                `json:"unique_check,omitempty"`      // 是否去重（Type 1 使用）
-	UniqueCacheSize int                 `json:"unique_cache_size,omitempty"` // 去重缓存大小（Type 1 使用）
+	Type               Type                `json:"type"`                          // 发号器类型
+	Length             int                 `json:"length,omitempty"`              // 长度（Type 1, 2 fixed, 3 使用）
+	Starting           int64               `json:"starting,omitempty"`            // 起始值（Type 2 使用）
+	Step               int64               `json:"step,omitempty"`                // 步长，负数表示递减（Type 2 使用）
+	Min                int64               `json:"min,omitempty"`                 // 计数器下限（Type 2 使用）
+	Max                int64               `json:"max,omitempty"`                 // 计数器上限，0 表示位数上限或18位上限（Type 2 使用）
+	OnExhausted        ExhaustedPolicy     `json:"on_exhausted,omitempty"`        // 超出 [min, max] 时的策略 error/wrap/extend_length（Type 2 使用）
+	MachineID          int64               `json:"machine_id,omitempty"`          // 机器ID（Type 4、Type 5 v1/v6 使用）
+	DatacenterID       int64               `json:"datacenter_id,omitempty"`       // 数据中心ID（Type 4、Type 5 v1/v6 使用）
+	IncrMode           IncrementalMode     `json:"incr_mode,omitempty"`           // 自增模式（Type 2 使用）
+	PermKey            string              `json:"perm_key,omitempty"`            // 置换密钥，十六进制（Type 2 permuted 模式使用）
+	ResetPeriod        ResetPeriod         `json:"reset_period,omitempty"`        // 计数器重置周期（Type 2 使用）
+	DateFormat         string              `json:"date_format,omitempty"`         // 日期前缀格式，如 YYYYMMDD（Type 2 重置周期使用）
+	Timezone           string              `json:"timezone,omitempty"`            // 周期、日期前缀和格式模板使用的时区，如 Asia/Shanghai
+	Format             string              `json:"format,omitempty"`              // 格式模板，如 ORD-{date}-{seq:8}（所有类型可用）
+	RNG                RNG                 `json:"rng,omitempty"`                 // 随机源 fast/secure，默认secure（Type 1, 3 使用）
+	Charset            Charset             `json:"charset,omitempty"`             // 字符集（Type 3 使用）
+	CustomAlphabet     string              `json:"custom_alphabet,omitempty"`     // 自定义字符表（Type 3 custom 字符集使用）
+	UUIDFormat         UUIDFormat          `json:"uuid_format,omitempty"`         // UUID格式（Type 5 使用）
+	UUIDVersion        int                 `json:"uuid_version,omitempty"`        // UUID版本 1/4/6/7（Type 5 使用）
+	Epoch              int64               `json:"epoch,omitempty"`               // 纪元，Unix毫秒（Type 4 使用）
+	TimestampBits      int                 `json:"timestamp_bits,omitempty"`      // 时间戳位数（Type 4 使用）
+	DatacenterBits     int                 `json:"datacenter_bits,omitempty"`     // 数据中心ID位数（Type 4 使用）
+	WorkerBits         int                 `json:"worker_bits,omitempty"`         // 机器ID位数（Type 4 使用）
+	SequenceBits       int                 `json:"sequence_bits,omitempty"`       // 序列号位数（Type 4 使用）
+	ClockRollback      ClockRollbackPolicy `json:"clock_rollback,omitempty"`      // 时钟回拨策略（Type 4、5 v7、6 使用）
+	RollbackWaitMs     int64               `json:"rollback_wait_ms,omitempty"`    // 回拨最大等待时间，毫秒（wait 策略使用）
+	Monotonic          bool                `json:"monotonic,omitempty"`           // 同一毫秒内单调递增（Type 6 使用）
+	AutoDisk           PersistenceStrategy `json:"auto_disk,omitempty"`           // 持久化策略
+	SegmentSize        int64               `json:"segment_size,omitempty"`        // 号段大小，0表示使用服务端默认值（号段策略使用）
+	PreloadThreshold   float64             `json:"preload_threshold,omitempty"`   // 剩余比例低于该值时预加载下一号段（号段策略使用）
+	CheckpointInterval time.Duration       `json:"checkpoint_interval,omitempty"` // checkpoint间隔（pre-checkpoint、pre_close 使用）
+	AdaptiveSegment    bool                `json:"adaptive_segment,omitempty"`    // 根据消耗速度自动调整号段大小（号段策略使用）
+	SegmentMinSize     int64               `json:"segment_min_size,omitempty"`    // 自适应号段下限
+	SegmentMaxSize     int64               `json:"segment_max_size,omitempty"`    // 自适应号段上限
+	SegmentTarget      time.Duration       `json:"segment_target,omitempty"`      // 自适应号段的目标耗尽时间
+	CheckDigit         CheckDigit          `json:"check_digit,omitempty"`         // 追加校验码 luhn/damm/verhoeff/mod97（Type 1, 2 使用）
+	UniqueCheck        bool                `json:"unique_check,omitempty"`        // 是否去重（Type 1 使用）
+	UniqueCacheSize    int                 `json:"unique_cache_size,omitempty"`   // 去重窗口：只保证与最近 N 个号码不重复，0 表示全部（Type 1 使用）
+	Recycle            bool                `json:"recycle,omitempty"`             // 启用号码回收 RELEASE（Type 1, 2 使用）
+	RecycleCooldown    time.Duration       `json:"recycle_cooldown,omitempty"`    // 回收号码的冷却时间，0 表示释放后按先进先出立即复用
+	WarnAt             float64             `json:"warn_at,omitempty"`             // 使用率达到该比例（0~1]时发出容量告警（Type 1, 2 使用）
 }
 
 // Dispenser represents a number dispenser
@@ -84,15 +158,38 @@ type Dispenser struct {
 	segmentEnd   int64
 
 	// Type 1: 去重支持
-	used map[string]bool // 已使用的号码
+	used        *usedSet                   // 已使用的号码
+	usedOrder   []int64                    // 发号顺序（配置了 unique_cache_size 时用于淘汰最早的号码）
+	pendingUsed []int64                    // 本次调用新占用、尚未写入日志的号码
+	usedLog     func(values []int64) error // 已发号码的增量持久化
+
+	// Type 1、2: 保留号码
+	*reservations
+
+	// Type 2: 周期重置支持
+	loc         *time.Location
+	period      string    // 当前计数器所属周期（周期起始日期，随 current 一起持久化）
+	periodStart time.Time // 当前周期的起始时间
 
 	// Type 4: Snowflake 支持
-	seqCounter     int64 // 序列计数器
-	lastTimestamp  int64 // 上次生成的时间戳
-	snowflakeEpoch int64 // Snowflake纪元（毫秒）
+	seqCounter    int64 // 序列计数器
+	lastTimestamp int64 // 上次生成的时间戳（毫秒时钟类型通过 current 持久化）
+
+	// 时钟回拨统计
+	clockRollbacks      int64 // 检测到时钟回拨的次数
+	clockRollbackErrors int64 // 因时钟回拨拒绝发号的次数
+	maxClockRollback    int64 // 最大回拨幅度（毫秒）
+
+	// Type 5: UUID v1/v6 支持
+	uuidTime int64  // 上次使用的100纳秒时间戳（保证同一发号器内严格递增）
+	clockSeq uint16 // 14位时钟序列
+
+	// Type 6: ULID 支持
+	ulidRandom [10]byte // 上次生成的80位随机部分（单调模式使用）
 
 	// 统计信息
 	totalGenerated int64
+	errs           errorCounter
 }
 
 // NewDispenser creates a new dispenser with the given configuration
@@ -102,27 +199,31 @@ func NewDispenser(cfg Config) (*Dispenser, error) {
 	}
 
 	d := &Dispenser{
-		config:         cfg,
-		rng:            mathrand.New(mathrand.NewSource(time.Now().UnixNano())),
-		snowflakeEpoch: 1288834974657, // Twitter Snowflake epoch: 2010-11-04
+		config:       cfg,
+		rng:          newRand(),
+		reservations: newReservations(),
 	}
 
 	// 根据类型初始化
 	switch cfg.Type {
 	case TypeNumericRandom:
-		// Type 1: 默认启用去重
+		// Type 1: 默认启用去重，默认使用安全随机源
 		if !cfg.UniqueCheck {
 			d.config.UniqueCheck = true
 		}
-		d.used = make(map[string]bool)
+		if d.config.RNG == "" {
+			d.config.RNG = RNGSecure
+		}
+		d.used = newUsedSet(pow10(cfg.Length - 1))
 
 	case TypeNumericIncremental:
-		// Type 2: 初始化起始值
-		d.current = cfg.Starting
 		// 设置默认步长
 		if d.config.Step == 0 {
 			d.config.Step = 1
 		}
+		// Type 2: 初始化起始值（未指定时为范围的起点）
+		d.config.Starting = startingValue(d.config)
+		d.current = d.config.Starting
 		// 设置默认模式
 		if d.config.IncrMode == "" {
 			if cfg.Length > 0 {
@@ -131,16 +232,32 @@ func NewDispenser(cfg Config) (*Dispenser, error) {
 				d.config.IncrMode = IncrModeSequence
 			}
 		}
+		// 周期重置：加载时区，设置默认日期前缀
+		if cfg.ResetPeriod != "" {
+			d.loc, _ = loadLocation(cfg.Timezone)
+			d.config.DateFormat = dateFormat(cfg)
+		}
 
 	case TypeAlphanumericRandom:
-		// Type 3: 设置默认字符集
+		// Type 3: 设置默认字符集，只指定 custom_alphabet 时使用自定义字符表
+		if d.config.RNG == "" {
+			d.config.RNG = RNGSecure
+		}
 		if d.config.Charset == "" {
 			d.config.Charset = CharsetHex
+			if d.config.CustomAlphabet != "" {
+				d.config.Charset = CharsetCustom
+			}
 		}
 
 	case TypeSnowflake:
 		// Type 4: 初始化Snowflake
-		if d.config.MachineID == 0 {
+		// 未指定时使用默认位布局和纪元
+		d.config.TimestampBits, d.config.DatacenterBits, d.config.WorkerBits, d.config.SequenceBits = snowflakeLayout(cfg)
+		if d.config.Epoch == 0 {
+			d.config.Epoch = DefaultSnowflakeEpoch
+		}
+		if d.config.MachineID == 0 && d.config.WorkerBits > 0 {
 			d.config.MachineID = 1
 		}
 
@@ -149,6 +266,20 @@ func NewDispenser(cfg Config) (*Dispenser, error) {
 		if d.config.UUIDFormat == "" {
 			d.config.UUIDFormat = UUIDFormatStandard
 		}
+		if d.config.UUIDVersion == 0 {
+			d.config.UUIDVersion = UUIDVersion4
+		}
+		d.clockSeq = uint16(d.rng.Intn(1 << 14))
+	}
+
+	// 时钟类型：设置默认回拨策略
+	if UsesMillisClock(d.config) {
+		if d.config.ClockRollback == "" {
+			d.config.ClockRollback = RollbackWait
+		}
+		if d.config.RollbackWaitMs == 0 {
+			d.config.RollbackWaitMs = defaultRollbackWaitMs
+		}
 	}
 
 	return d, nil
@@ -156,20 +287,77 @@ func NewDispenser(cfg Config) (*Dispenser, error) {
 
 // Next generates the next number
 func (d *Dispenser) Next() (string, error) {
+	d.awaitClock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	num, err := d.nextLocked()
+	if err != nil {
+		return "", d.errs.record(err)
+	}
+	if err := d.flushUsed(); err != nil {
+		d.totalGenerated--
+		return "", d.errs.record(err)
+	}
+	return num, nil
+}
+
+// NextN generates n numbers in one call
+// 整批在同一把锁内生成；任一号码失败时回滚本批次，不会消耗号码
+func (d *Dispenser) NextN(n int) ([]string, error) {
+	if n <= 0 {
+		return nil, ErrInvalidCount
+	}
+
+	d.awaitClock()
+
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	current := d.current
+	period, periodStart := d.period, d.periodStart
+	totalGenerated := d.totalGenerated
+
+	numbers := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		num, err := d.nextLocked()
+		if err != nil {
+			// 回滚：恢复自增位置，并释放本批次已占用的去重记录
+			d.current = current
+			d.period = period
+			d.periodStart = periodStart
+			d.totalGenerated = totalGenerated
+			d.discardPending()
+			return nil, d.errs.record(err)
+		}
+		numbers = append(numbers, num)
+	}
+
+	// 整批写入去重日志，失败时同样回滚
+	if err := d.flushUsed(); err != nil {
+		d.totalGenerated = totalGenerated
+		return nil, d.errs.record(err)
+	}
+
+	return numbers, nil
+}
+
+// nextLocked generates the next number (must be called with lock held)
+func (d *Dispenser) nextLocked() (string, error) {
 	switch d.config.Type {
 	case TypeNumericRandom:
-		return d.nextNumericRandom()
+		return d.withCheckDigit(d.nextNumericRandom())
 	case TypeNumericIncremental:
-		return d.nextNumericIncremental()
+		return d.withCheckDigit(d.nextNumericIncremental())
 	case TypeAlphanumericRandom:
 		return d.nextAlphanumericRandom()
 	case TypeSnowflake:
 		return d.nextSnowflake()
 	case TypeUUID:
 		return d.nextUUID()
+	case TypeULID:
+		return d.nextULID()
 	default:
 		return "", ErrInvalidType
 	}
@@ -180,33 +368,44 @@ func (d *Dispenser) Next() (string, error) {
 // ============================================
 
 func (d *Dispenser) nextNumericRandom() (string, error) {
-	if d.used == nil {
-		d.used = make(map[string]bool)
-	}
-
 	min := pow10(d.config.Length - 1)
 	max := pow10(d.config.Length) - 1
 	totalSpace := max - min + 1
 
-	// 检查使用率，超过80%时拒绝生成
-	usedCount := int64(len(d.used))
-	if float64(usedCount)/float64(totalSpace) > 0.8 {
+	// 保留的号码不会发出，也计入已占用
+	usedCount := d.used.count() + d.reservations.reservedCount()
+	if usedCount >= totalSpace {
 		return "", ErrNumberExhausted
 	}
 
-	// 尝试生成不重复的号码（最多100次）
-	for retry := 0; retry < 100; retry++ {
-		num := min + d.rng.Int63n(max-min+1)
-		numStr := fmt.Sprintf("%0*d", d.config.Length, num)
+	// 使用率不到一半时随机取值并拒绝重复（期望不超过2次）
+	if usedCount*2 < totalSpace {
+		for retry := 0; retry < 100; retry++ {
+			offset, err := d.randInt63n(totalSpace)
+			if err != nil {
+				return "", err
+			}
+			num := min + offset
 
-		if !d.used[numStr] {
-			d.used[numStr] = true
-			d.totalGenerated++
-			return numStr, nil
+			if _, reserved := d.reservations.blocked(num); !reserved && !d.used.contains(num) {
+				return d.issueRandom(num), nil
+			}
 		}
 	}
 
-	return "", errors.New("failed to generate unique number after 100 retries")
+	// 使用率较高时直接在剩余的空闲号码中均匀选取，号码空间可以全部用完
+	r, err := d.randInt63n(totalSpace - usedCount)
+	if err != nil {
+		return "", err
+	}
+	return d.issueRandom(d.used.selectFreeSkipping(r, totalSpace, d.reservations.reservedRanges())), nil
+}
+
+// issueRandom 记录并格式化 Type 1 号码
+func (d *Dispenser) issueRandom(num int64) string {
+	d.markUsed(num)
+	d.totalGenerated++
+	return fmt.Sprintf("%0*d", d.config.Length, num)
 }
 
 // ============================================
@@ -214,9 +413,13 @@ func (d *Dispenser) nextNumericRandom() (string, error) {
 // ============================================
 
 func (d *Dispenser) nextNumericIncremental() (string, error) {
+	if d.config.ResetPeriod != "" {
+		return d.nextIncrPeriodic()
+	}
+
 	// 根据模式生成
 	switch d.config.IncrMode {
-	case IncrModeFixed:
+	case IncrModeFixed, IncrModePermuted:
 		return d.nextIncrFixed()
 	case IncrModeSequence:
 		return d.nextIncrSequence()
@@ -225,70 +428,150 @@ func (d *Dispenser) nextNumericIncremental() (string, error) {
 	}
 }
 
-// 固定位数自增
-func (d *Dispenser) nextIncrFixed() (string, error) {
-	maxValue := pow10(d.config.Length) - 1
+// 周期重置自增：日期前缀 + 本周期内的计数器
+func (d *Dispenser) nextIncrPeriodic() (string, error) {
+	t := d.rollPeriod()
 
-	if d.current > maxValue {
-		return "", ErrNumberExhausted
+	var num string
+	var err error
+	if d.config.IncrMode == IncrModeFixed || d.config.IncrMode == IncrModePermuted {
+		num, err = d.nextIncrFixed()
+	} else {
+		num, err = d.nextIncrSequence()
+	}
+	if err != nil {
+		return "", err
 	}
 
-	num := d.current
-	d.current += d.config.Step
-	d.totalGenerated++
+	return formatDate(t, d.config.DateFormat) + num, nil
+}
 
-	return fmt.Sprintf("%0*d", d.config.Length, num), nil
+// 固定位数自增（permuted 模式输出置换后的值）
+func (d *Dispenser) nextIncrFixed() (string, error) {
+	num, err := d.nextCounter()
+	if err != nil {
+		return "", err
+	}
+	return formatCounter(d.config, num), nil
 }
 
 // 普通序列自增
 func (d *Dispenser) nextIncrSequence() (string, error) {
+	num, err := d.nextCounter()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d", num), nil
+}
+
+// nextCounter 取出当前计数器并前进一步（调用方需持有锁）
+// 超出 [min, max] 时按 on_exhausted 处理；跳过保留的号码，跳过的计数器视为已消耗
+func (d *Dispenser) nextCounter() (int64, error) {
+	for {
+		if !inBounds(d.config, d.current) {
+			start, ok := wrapStart(d.config)
+			if !ok {
+				return 0, ErrNumberExhausted
+			}
+			d.current = start
+		}
+		next, blocked := d.reservations.skip(d.config, d.current)
+		if !blocked {
+			break
+		}
+		d.current = next
+	}
+
 	num := d.current
 	d.current += d.config.Step
 	d.totalGenerated++
-	return fmt.Sprintf("%d", num), nil
+	return num, nil
 }
 
 // ============================================
-// Type 3: 字符随机（hex/base62）
+// Type 3: 字符随机（hex/base62/base58/crockford/custom）
 // ============================================
 
-func (d *Dispenser) nextAlphanumericRandom() (string, error) {
-	switch d.config.Charset {
-	case CharsetHex:
-		return d.nextHex()
+// Type 3 内置字符集
+const (
+	hexChars    = "0123456789abcdef"
+	base62Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	base58Chars = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz" // 去掉 0 O I l
+)
+
+// 自定义字符集的大小限制
+const (
+	minAlphabetSize = 2
+	maxAlphabetSize = 94 // 可打印ASCII字符（不含空格）
+)
+
+// alphabet 返回生效的字符表
+func alphabet(cfg Config) string {
+	switch cfg.Charset {
 	case CharsetBase62:
-		return d.nextBase62()
+		return base62Chars
+	case CharsetBase58:
+		return base58Chars
+	case CharsetCrockford:
+		return crockfordAlphabet
+	case CharsetCustom:
+		return cfg.CustomAlphabet
 	default:
-		return d.nextHex()
+		if cfg.CustomAlphabet != "" {
+			return cfg.CustomAlphabet
+		}
+		return hexChars
 	}
 }
 
-// 生成十六进制字符串
-func (d *Dispenser) nextHex() (string, error) {
-	bytes := make([]byte, (d.config.Length+1)/2)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
+// validAlphabet 检查自定义字符表：可打印ASCII、无重复、至少2个字符
+func validAlphabet(chars string) bool {
+	if len(chars) < minAlphabetSize || len(chars) > maxAlphabetSize {
+		return false
+	}
+	var seen [128]bool
+	for i := 0; i < len(chars); i++ {
+		c := chars[i]
+		if c <= ' ' || c > '~' || seen[c] {
+			return false
+		}
+		seen[c] = true
 	}
+	return true
+}
 
-	hexStr := hex.EncodeToString(bytes)
-	if len(hexStr) > d.config.Length {
-		hexStr = hexStr[:d.config.Length]
+func (d *Dispenser) nextAlphanumericRandom() (string, error) {
+	result, err := randomString(d.randReader(), alphabet(d.config), d.config.Length)
+	if err != nil {
+		return "", err
 	}
 
 	d.totalGenerated++
-	return hexStr, nil
+	return result, nil
 }
 
-// 生成Base62字符串
-func (d *Dispenser) nextBase62() (string, error) {
-	const base62Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
-	result := make([]byte, d.config.Length)
-
-	for i := 0; i < d.config.Length; i++ {
-		result[i] = base62Chars[d.rng.Intn(len(base62Chars))]
+// randomString 从字符表中均匀随机选取 length 个字符
+// 拒绝采样：丢弃 >= 256 - 256%n 的随机字节，避免取模偏差
+func randomString(src io.Reader, chars string, length int) (string, error) {
+	n := len(chars)
+	limit := 256 - 256%n
+
+	result := make([]byte, 0, length)
+	buf := make([]byte, length+length/2)
+	for len(result) < length {
+		if _, err := io.ReadFull(src, buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			result = append(result, chars[int(b)%n])
+			if len(result) == length {
+				break
+			}
+		}
 	}
-
-	d.totalGenerated++
 	return string(result), nil
 }
 
@@ -296,20 +579,42 @@ func (d *Dispenser) nextBase62() (string, error) {
 // Type 4: Snowflake算法
 // ============================================
 
+// DefaultSnowflakeEpoch Twitter Snowflake 纪元：2010-11-04（Unix毫秒）
+const DefaultSnowflakeEpoch int64 = 1288834974657
+
+// 默认位布局：41位时间戳 + 5位数据中心ID + 5位机器ID + 12位序列号
+const (
+	defaultTimestampBits  = 41
+	defaultDatacenterBits = 5
+	defaultWorkerBits     = 5
+	defaultSequenceBits   = 12
+)
+
+// snowflakeLayout 返回生效的位布局（时间戳、数据中心、机器、序列号）
+// 四项均未配置时使用默认布局
+func snowflakeLayout(cfg Config) (timestampBits, datacenterBits, workerBits, sequenceBits int) {
+	if cfg.TimestampBits == 0 && cfg.DatacenterBits == 0 && cfg.WorkerBits == 0 && cfg.SequenceBits == 0 {
+		return defaultTimestampBits, defaultDatacenterBits, defaultWorkerBits, defaultSequenceBits
+	}
+	return cfg.TimestampBits, cfg.DatacenterBits, cfg.WorkerBits, cfg.SequenceBits
+}
+
 func (d *Dispenser) nextSnowflake() (string, error) {
 	// Snowflake ID 结构 (64位):
-	// 1位符号位（0） + 41位时间戳 + 10位机器ID + 12位序列号
+	// 1位符号位（0） + 时间戳 + 数据中心ID + 机器ID + 序列号，位数由配置决定（合计63位）
+	seqMask := int64(1)<<d.config.SequenceBits - 1
 
-	timestamp := time.Now().UnixNano() / 1e6 // 毫秒
+	timestamp, err := d.currentMillis() // 毫秒
+	if err != nil {
+		return "", err
+	}
 
 	// 如果是同一毫秒，序列号自增
 	if timestamp == d.lastTimestamp {
-		d.seqCounter = (d.seqCounter + 1) & 0xFFF // 12位，最大4095
+		d.seqCounter = (d.seqCounter + 1) & seqMask
 		// 如果序列号溢出，等待下一毫秒
 		if d.seqCounter == 0 {
-			for timestamp <= d.lastTimestamp {
-				timestamp = time.Now().UnixNano() / 1e6
-			}
+			timestamp = d.waitNextMillis()
 		}
 	} else {
 		d.seqCounter = 0
@@ -317,16 +622,22 @@ func (d *Dispenser) nextSnowflake() (string, error) {
 	d.lastTimestamp = timestamp
 
 	// 时间戳部分（减去纪元）
-	timestamp -= d.snowflakeEpoch
+	timestamp -= d.config.Epoch
+	if timestamp < 0 || timestamp >= int64(1)<<d.config.TimestampBits {
+		// 时间戳超出可表示范围
+		return "", ErrNumberExhausted
+	}
 
 	// 组合ID
-	// [41位时间戳] [5位数据中心ID] [5位机器ID] [12位序列号]
-	datacenterID := d.config.DatacenterID & 0x1F // 5位，最大31
-	machineID := d.config.MachineID & 0x1F       // 5位，最大31
-
-	id := (timestamp << 22) |
-		(datacenterID << 17) |
-		(machineID << 12) |
+	// [时间戳] [数据中心ID] [机器ID] [序列号]
+	// machine_id / datacenter_id 已由 validateConfig 校验不超过各自位数，不会被截断
+	workerShift := d.config.SequenceBits
+	datacenterShift := workerShift + d.config.WorkerBits
+	timestampShift := datacenterShift + d.config.DatacenterBits
+
+	id := (timestamp << timestampShift) |
+		(d.config.DatacenterID << datacenterShift) |
+		(d.config.MachineID << workerShift) |
 		d.seqCounter
 
 	d.totalGenerated++
@@ -334,19 +645,28 @@ func (d *Dispenser) nextSnowflake() (string, error) {
 }
 
 // ============================================
-// Type 5: 标准UUID v4
+// Type 5: UUID (v1/v4/v6/v7)
 // ============================================
 
+// uuidGregorianOffset 1582-10-15 到 1970-01-01 之间的100纳秒间隔数
+const uuidGregorianOffset = 0x01B21DD213814000
+
 func (d *Dispenser) nextUUID() (string, error) {
-	uuid := make([]byte, 16)
-	if _, err := rand.Read(uuid); err != nil {
+	var uuid []byte
+	var err error
+
+	switch d.config.UUIDVersion {
+	case UUIDVersion1, UUIDVersion6:
+		uuid = d.uuidTimeBased(d.config.UUIDVersion)
+	case UUIDVersion7:
+		uuid, err = d.uuidV7()
+	default:
+		uuid, err = d.uuidV4()
+	}
+	if err != nil {
 		return "", err
 	}
 
-	// 设置版本号（4）和变体（RFC 4122）
-	uuid[6] = (uuid[6] & 0x0f) | 0x40 // Version 4
-	uuid[8] = (uuid[8] & 0x3f) | 0x80 // Variant RFC 4122
-
 	d.totalGenerated++
 
 	if d.config.UUIDFormat == UUIDFormatCompact {
@@ -359,6 +679,283 @@ func (d *Dispenser) nextUUID() (string, error) {
 		uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16]), nil
 }
 
+// uuidV4 随机UUID
+func (d *Dispenser) uuidV4() ([]byte, error) {
+	uuid := make([]byte, 16)
+	if _, err := rand.Read(uuid); err != nil {
+		return nil, err
+	}
+
+	// 设置版本号（4）和变体（RFC 4122）
+	uuid[6] = (uuid[6] & 0x0f) | 0x40 // Version 4
+	uuid[8] = (uuid[8] & 0x3f) | 0x80 // Variant RFC 4122
+
+	return uuid, nil
+}
+
+// uuidV7 时间有序UUID
+// [48位Unix毫秒时间戳] [4位版本] [12位单调计数器] [2位变体] [62位随机数]
+func (d *Dispenser) uuidV7() ([]byte, error) {
+	timestamp, err := d.currentMillis()
+	if err != nil {
+		return nil, err
+	}
+
+	if timestamp <= d.lastTimestamp {
+		// 同一毫秒（或借用逻辑时钟）沿用上次时间戳，计数器加1
+		timestamp = d.lastTimestamp
+		d.seqCounter++
+		if d.seqCounter > 0xFFF {
+			// 计数器溢出，时间戳逻辑前进1毫秒
+			timestamp++
+			d.seqCounter = 0
+		}
+	} else {
+		// 新的毫秒：计数器从随机值开始，最高位置0以预留递增空间
+		d.seqCounter = int64(d.rng.Intn(1 << 11))
+	}
+	d.lastTimestamp = timestamp
+
+	uuid := make([]byte, 16)
+	if _, err := rand.Read(uuid[8:]); err != nil {
+		return nil, err
+	}
+
+	uuid[0] = byte(timestamp >> 40)
+	uuid[1] = byte(timestamp >> 32)
+	uuid[2] = byte(timestamp >> 24)
+	uuid[3] = byte(timestamp >> 16)
+	uuid[4] = byte(timestamp >> 8)
+	uuid[5] = byte(timestamp)
+	uuid[6] = 0x70 | byte(d.seqCounter>>8) // Version 7
+	uuid[7] = byte(d.seqCounter)
+	uuid[8] = (uuid[8] & 0x3f) | 0x80 // Variant RFC 4122
+
+	return uuid, nil
+}
+
+// uuidTimeBased 基于时间和节点标识的UUID（v1/v6）
+// 节点标识由 datacenter_id 和 machine_id 组成，并设置组播位以区别于真实MAC地址
+func (d *Dispenser) uuidTimeBased(version int) []byte {
+	// 60位时间戳：自1582-10-15起的100纳秒间隔数
+	ts := time.Now().UnixNano()/100 + uuidGregorianOffset
+	if ts <= d.uuidTime {
+		// 保证同一发号器内严格递增
+		ts = d.uuidTime + 1
+	}
+	d.uuidTime = ts
+
+	uuid := make([]byte, 16)
+
+	if version == UUIDVersion6 {
+		// v6: time_high(32) | time_mid(16) | ver(4) time_low(12)
+		timeHigh := uint32(ts >> 28)
+		uuid[0] = byte(timeHigh >> 24)
+		uuid[1] = byte(timeHigh >> 16)
+		uuid[2] = byte(timeHigh >> 8)
+		uuid[3] = byte(timeHigh)
+		uuid[4] = byte(ts >> 20)
+		uuid[5] = byte(ts >> 12)
+		uuid[6] = 0x60 | byte((ts>>8)&0x0F)
+		uuid[7] = byte(ts)
+	} else {
+		// v1: time_low(32) | time_mid(16) | ver(4) time_high(12)
+		uuid[0] = byte(ts >> 24)
+		uuid[1] = byte(ts >> 16)
+		uuid[2] = byte(ts >> 8)
+		uuid[3] = byte(ts)
+		uuid[4] = byte(ts >> 40)
+		uuid[5] = byte(ts >> 32)
+		uuid[6] = 0x10 | byte((ts>>56)&0x0F)
+		uuid[7] = byte(ts >> 48)
+	}
+
+	uuid[8] = 0x80 | byte(d.clockSeq>>8)&0x3F // Variant RFC 4122
+	uuid[9] = byte(d.clockSeq)
+
+	// 节点标识：[组播位] [8位数据中心ID] [32位机器ID]
+	uuid[10] = 0x01
+	uuid[11] = byte(d.config.DatacenterID)
+	uuid[12] = byte(d.config.MachineID >> 24)
+	uuid[13] = byte(d.config.MachineID >> 16)
+	uuid[14] = byte(d.config.MachineID >> 8)
+	uuid[15] = byte(d.config.MachineID)
+
+	return uuid
+}
+
+// ============================================
+// Type 6: ULID
+// ============================================
+
+// crockfordAlphabet Crockford Base32 字母表（去掉 I、L、O、U）
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func (d *Dispenser) nextULID() (string, error) {
+	// ULID 结构 (128位):
+	// 48位毫秒时间戳 + 80位随机数，编码为26位 Crockford Base32
+
+	timestamp, err := d.currentMillis()
+	if err != nil {
+		return "", err
+	}
+
+	if d.config.Monotonic && timestamp == d.lastTimestamp {
+		// 单调模式：同一毫秒内随机部分加1（ULID规范）
+		next := d.ulidRandom
+		if !incrementBytes(next[:]) {
+			// 随机部分溢出，本毫秒内无法再生成更大的ULID
+			return "", ErrNumberExhausted
+		}
+		d.ulidRandom = next
+	} else {
+		if _, err := rand.Read(d.ulidRandom[:]); err != nil {
+			return "", err
+		}
+	}
+	d.lastTimestamp = timestamp
+
+	var ulid [16]byte
+	ulid[0] = byte(timestamp >> 40)
+	ulid[1] = byte(timestamp >> 32)
+	ulid[2] = byte(timestamp >> 24)
+	ulid[3] = byte(timestamp >> 16)
+	ulid[4] = byte(timestamp >> 8)
+	ulid[5] = byte(timestamp)
+	copy(ulid[6:], d.ulidRandom[:])
+
+	d.totalGenerated++
+	return encodeULID(ulid), nil
+}
+
+// encodeULID 将128位ULID编码为26位 Crockford Base32
+// 首字符只携带最高3位，其余每个字符携带5位
+func encodeULID(ulid [16]byte) string {
+	result := make([]byte, 26)
+	for i := 0; i < 26; i++ {
+		// 第i个字符对应 130 位（高位补2个0）中的第 i*5 位起的5位
+		var v byte
+		for b := 0; b < 5; b++ {
+			bit := i*5 + b - 2
+			v <<= 1
+			if bit >= 0 && ulid[bit/8]&(0x80>>(bit%8)) != 0 {
+				v |= 1
+			}
+		}
+		result[i] = crockfordAlphabet[v]
+	}
+	return string(result)
+}
+
+// incrementBytes 将大端字节序整数加1，溢出时返回 false
+func incrementBytes(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================
+// 时钟（Snowflake / ULID 共用）
+// ============================================
+
+// UsesMillisClock reports whether the type depends on the millisecond clock
+// 这些类型需要回拨保护，GetCurrent/SetCurrent 持久化的是上次发号的时间戳
+func UsesMillisClock(cfg Config) bool {
+	switch cfg.Type {
+	case TypeSnowflake, TypeULID:
+		return true
+	case TypeUUID:
+		return cfg.UUIDVersion == UUIDVersion7
+	}
+	return false
+}
+
+// readClock 读取当前毫秒时间戳
+func (d *Dispenser) readClock() int64 {
+	return time.Now().UnixNano() / 1e6
+}
+
+// currentMillis 读取当前毫秒时间戳，并按 clock_rollback 策略处理时钟回拨
+// 返回值不小于上次发号的时间戳（lastTimestamp 在重启时从持久化数据恢复，
+// 因此重启后时钟偏慢同样能被检测到）
+func (d *Dispenser) currentMillis() (int64, error) {
+	timestamp := d.readClock()
+	if timestamp >= d.lastTimestamp {
+		return timestamp, nil
+	}
+
+	// 时钟回拨
+	offset := d.lastTimestamp - timestamp
+	d.clockRollbacks++
+	if offset > d.maxClockRollback {
+		d.maxClockRollback = offset
+	}
+
+	switch d.config.ClockRollback {
+	case RollbackBorrow:
+		// 借用逻辑时钟：停留在上次时间戳，由序列号/计数器继续区分
+		return d.lastTimestamp, nil
+
+	case RollbackError:
+		d.clockRollbackErrors++
+		return 0, ErrClockMovedBackwards
+
+	default:
+		// 取号前已在锁外等待过（awaitClock），这里是等待后或批量取号中途再次回拨，
+		// 持有锁时只等待很短的时间
+		if offset > min(d.config.RollbackWaitMs, lockedRollbackWaitMs) {
+			d.clockRollbackErrors++
+			return 0, ErrClockMovedBackwards
+		}
+		time.Sleep(time.Duration(offset) * time.Millisecond)
+		timestamp = d.readClock()
+		if timestamp < d.lastTimestamp {
+			d.clockRollbackErrors++
+			return 0, ErrClockMovedBackwards
+		}
+		return timestamp, nil
+	}
+}
+
+// awaitClock 按 wait 策略在锁外等待时钟追上上次发号的时间戳
+// 等待期间不持有锁，其他命令不会被阻塞；回拨超过 rollback_wait_ms 时不等待，由 currentMillis 报错
+func (d *Dispenser) awaitClock() {
+	d.mu.Lock()
+	if d.config.ClockRollback != RollbackWait {
+		d.mu.Unlock()
+		return
+	}
+	offset := d.lastTimestamp - d.readClock()
+	if offset <= 0 || offset > d.config.RollbackWaitMs {
+		d.mu.Unlock()
+		return
+	}
+	d.clockRollbacks++
+	if offset > d.maxClockRollback {
+		d.maxClockRollback = offset
+	}
+	d.mu.Unlock()
+
+	time.Sleep(time.Duration(offset) * time.Millisecond)
+}
+
+// waitNextMillis 自旋等待直到时钟超过上次发号的时间戳
+// 借用逻辑时钟期间（真实时钟落后）直接前进1毫秒
+func (d *Dispenser) waitNextMillis() int64 {
+	timestamp := d.readClock()
+	if timestamp < d.lastTimestamp {
+		return d.lastTimestamp + 1
+	}
+	for timestamp <= d.lastTimestamp {
+		timestamp = d.readClock()
+	}
+	return timestamp
+}
+
 // ============================================
 // 辅助函数
 // ============================================
@@ -387,9 +984,13 @@ func (d *Dispenser) GetConfig() Config {
 }
 
 // GetCurrent returns the current value (for persistence)
+// 毫秒时钟类型返回上次发号的时间戳，用于重启后检测时钟回拨
 func (d *Dispenser) GetCurrent() int64 {
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	if UsesMillisClock(d.config) {
+		return d.lastTimestamp
+	}
 	return d.current
 }
 
@@ -397,6 +998,10 @@ func (d *Dispenser) GetCurrent() int64 {
 func (d *Dispenser) SetCurrent(current int64) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	if UsesMillisClock(d.config) {
+		d.lastTimestamp = current
+		return
+	}
 	d.current = current
 }
 
@@ -410,12 +1015,20 @@ func (d *Dispenser) GetStats() DispenserStats {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	return DispenserStats{
-		TotalGenerated: d.totalGenerated,
-		TotalWasted:    0,
-		WasteRate:      0,
-		Strategy:       d.config.AutoDisk,
+	stats := DispenserStats{
+		TotalGenerated:      d.totalGenerated,
+		TotalWasted:         0,
+		WasteRate:           0,
+		Strategy:            d.config.AutoDisk,
+		ClockRollbacks:      d.clockRollbacks,
+		ClockRollbackErrors: d.clockRollbackErrors,
+		MaxClockRollback:    d.maxClockRollback,
+		UniqueTracked:       d.uniqueTracked(),
+		UniqueMemory:        d.uniqueMemory(),
 	}
+	d.reservationStats(&stats)
+	d.errs.fillStats(&stats)
+	return stats
 }
 
 // AllocateSegment allocates a number segment for distributed deployment
@@ -428,8 +1041,27 @@ func (d *Dispenser) AllocateSegment(segmentSize int64) (start, end int64, err er
 		return 0, 0, errors.New("segment allocation only supported for incremental type")
 	}
 
-	start = d.current
-	end = d.current + segmentSize*d.config.Step
+	if segmentSize <= 0 {
+		return 0, 0, ErrInvalidCount
+	}
+
+	// 周期重置的号码依赖发号时的日期前缀，无法租给客户端
+	if d.config.ResetPeriod != "" {
+		return 0, 0, errors.New("segment allocation not supported with reset_period")
+	}
+	// 置换后的号码需要密钥，客户端无法从计数器区间得到号码
+	if d.config.IncrMode == IncrModePermuted {
+		return 0, 0, errPermutedSegment
+	}
+	// 回绕后计数器会再次经过已租出的号段
+	if d.config.OnExhausted == ExhaustedWrap {
+		return 0, 0, errWrapSegment
+	}
+
+	start, end, err = segmentRange(d.config, d.current, segmentSize)
+	if err != nil {
+		return 0, 0, err
+	}
 	d.current = end
 
 	return start, end, nil
@@ -440,10 +1072,113 @@ func (d *Dispenser) AllocateSegment(segmentSize int64) (start, end int64, err er
 // ============================================
 
 func validateConfig(cfg Config) error {
-	if cfg.Type < TypeNumericRandom || cfg.Type > TypeUUID {
+	if cfg.Type < TypeNumericRandom || cfg.Type > TypeULID {
 		return ErrInvalidType
 	}
 
+	// 时钟回拨策略（Type 4、5 v7、6）
+	if cfg.ClockRollback != "" &&
+		cfg.ClockRollback != RollbackWait &&
+		cfg.ClockRollback != RollbackBorrow &&
+		cfg.ClockRollback != RollbackError {
+		return ErrInvalidRollback
+	}
+	if cfg.RollbackWaitMs < 0 || cfg.RollbackWaitMs > maxRollbackWaitMs {
+		return ErrInvalidRollback
+	}
+
+	// 号段参数（号段策略使用）
+	if cfg.SegmentSize < 0 || cfg.PreloadThreshold < 0 || cfg.PreloadThreshold >= 1 || cfg.CheckpointInterval < 0 {
+		return ErrInvalidSegment
+	}
+	if cfg.SegmentMinSize < 0 || cfg.SegmentMaxSize < 0 || cfg.SegmentTarget < 0 {
+		return ErrInvalidSegment
+	}
+	if cfg.SegmentMinSize > 0 && cfg.SegmentMaxSize > 0 && cfg.SegmentMinSize > cfg.SegmentMaxSize {
+		return ErrInvalidSegment
+	}
+
+	// 周期重置（Type 2 使用）
+	if cfg.ResetPeriod == "" && cfg.DateFormat != "" {
+		return ErrInvalidPeriod
+	}
+	if cfg.Timezone != "" && cfg.ResetPeriod == "" && cfg.Format == "" {
+		return ErrInvalidTimezone
+	}
+	if cfg.ResetPeriod != "" {
+		if cfg.Type != TypeNumericIncremental {
+			return ErrInvalidPeriod
+		}
+		if _, ok := defaultDateFormats[cfg.ResetPeriod]; !ok {
+			return ErrInvalidPeriod
+		}
+		if !validDateFormat(dateFormat(cfg), cfg.ResetPeriod) {
+			return ErrInvalidPeriod
+		}
+	}
+	if _, err := loadLocation(cfg.Timezone); err != nil {
+		return ErrInvalidTimezone
+	}
+
+	// 校验码（Type 1, 2 使用），日期前缀也参与计算，因此必须是纯数字
+	if cfg.CheckDigit != "" {
+		if cfg.Type != TypeNumericRandom && cfg.Type != TypeNumericIncremental {
+			return ErrInvalidCheckDigit
+		}
+		if !validCheckDigit(cfg.CheckDigit) {
+			return ErrInvalidCheckDigit
+		}
+		if cfg.ResetPeriod != "" && !isDigits(formatDate(time.Now(), dateFormat(cfg))) {
+			return ErrInvalidCheckDigit
+		}
+	}
+
+	// 随机源（Type 1, 3 使用）
+	if cfg.RNG != "" {
+		if cfg.Type != TypeNumericRandom && cfg.Type != TypeAlphanumericRandom {
+			return ErrInvalidRNG
+		}
+		if cfg.RNG != RNGFast && cfg.RNG != RNGSecure {
+			return ErrInvalidRNG
+		}
+	}
+
+	// 去重缓存大小（Type 1 使用），0 表示记录全部已发号码
+	if cfg.UniqueCacheSize < 0 {
+		return ErrInvalidCacheSize
+	}
+
+	// 号码回收（Type 1, 2 使用），需要能判断号码是否已发出
+	if cfg.Recycle || cfg.RecycleCooldown != 0 {
+		if cfg.Type != TypeNumericRandom && cfg.Type != TypeNumericIncremental {
+			return ErrInvalidRecycle
+		}
+		if !cfg.Recycle || cfg.RecycleCooldown < 0 {
+			return ErrInvalidRecycle
+		}
+		// 周期重置和回绕后计数器回到起点，去重窗口会淘汰旧号码，都无法判断号码是否已发出
+		if cfg.ResetPeriod != "" || cfg.OnExhausted == ExhaustedWrap || cfg.UniqueCacheSize > 0 {
+			return ErrInvalidRecycle
+		}
+	}
+
+	// 计数器范围（Type 2 使用）
+	if cfg.Type != TypeNumericIncremental && (cfg.Min != 0 || cfg.Max != 0 || cfg.OnExhausted != "") {
+		return ErrInvalidBounds
+	}
+
+	// 容量告警（号码空间有限的 Type 1、2 使用）
+	if cfg.WarnAt != 0 && (!(cfg.WarnAt > 0 && cfg.WarnAt <= 1) || !bounded(cfg)) {
+		return ErrInvalidWarnAt
+	}
+
+	// 格式模板
+	if cfg.Format != "" {
+		if err := validateTemplate(cfg); err != nil {
+			return err
+		}
+	}
+
 	switch cfg.Type {
 	case TypeNumericRandom:
 		// Type 1: 纯数字随机
@@ -453,7 +1188,14 @@ func validateConfig(cfg Config) error {
 
 	case TypeNumericIncremental:
 		// Type 2: 纯数字自增
-		if cfg.IncrMode == IncrModeFixed {
+		if cfg.IncrMode == IncrModePermuted {
+			if !validPermKey(cfg.PermKey) {
+				return ErrInvalidPermKey
+			}
+		} else if cfg.PermKey != "" {
+			return ErrInvalidPermKey
+		}
+		if cfg.IncrMode == IncrModeFixed || cfg.IncrMode == IncrModePermuted {
 			if cfg.Length <= 0 || cfg.Length > 18 {
 				return ErrInvalidLength
 			}
@@ -465,8 +1207,8 @@ func validateConfig(cfg Config) error {
 		if cfg.Starting < 0 {
 			return ErrInvalidStarting
 		}
-		if cfg.Step < 0 {
-			return ErrInvalidStep
+		if err := validateBounds(cfg); err != nil {
+			return err
 		}
 
 	case TypeAlphanumericRandom:
@@ -474,18 +1216,38 @@ func validateConfig(cfg Config) error {
 		if cfg.Length <= 0 || cfg.Length > 64 {
 			return ErrInvalidLength
 		}
-		if cfg.Charset != "" && cfg.Charset != CharsetHex && cfg.Charset != CharsetBase62 {
+		switch cfg.Charset {
+		case "", CharsetCustom:
+			if cfg.Charset == CharsetCustom && cfg.CustomAlphabet == "" {
+				return ErrInvalidAlphabet
+			}
+			if cfg.CustomAlphabet != "" && !validAlphabet(cfg.CustomAlphabet) {
+				return ErrInvalidAlphabet
+			}
+		case CharsetHex, CharsetBase62, CharsetBase58, CharsetCrockford:
+			if cfg.CustomAlphabet != "" {
+				return ErrInvalidAlphabet
+			}
+		default:
 			return ErrInvalidCharset
 		}
 
 	case TypeSnowflake:
 		// Type 4: Snowflake
-		if cfg.MachineID < 0 || cfg.MachineID > 31 {
+		timestampBits, datacenterBits, workerBits, sequenceBits := snowflakeLayout(cfg)
+		if timestampBits <= 0 || datacenterBits < 0 || workerBits < 0 || sequenceBits <= 0 ||
+			timestampBits+datacenterBits+workerBits+sequenceBits != 63 {
+			return ErrInvalidLayout
+		}
+		if cfg.MachineID < 0 || cfg.MachineID >= int64(1)<<workerBits {
 			return ErrInvalidMachine
 		}
-		if cfg.DatacenterID < 0 || cfg.DatacenterID > 31 {
+		if cfg.DatacenterID < 0 || cfg.DatacenterID >= int64(1)<<datacenterBits {
 			return ErrInvalidMachine
 		}
+		if cfg.Epoch < 0 || cfg.Epoch > time.Now().UnixNano()/1e6 {
+			return ErrInvalidEpoch
+		}
 
 	case TypeUUID:
 		// Type 5: UUID
@@ -494,6 +1256,19 @@ func validateConfig(cfg Config) error {
 			cfg.UUIDFormat != UUIDFormatCompact {
 			return ErrInvalidFormat
 		}
+		switch cfg.UUIDVersion {
+		case 0, UUIDVersion4, UUIDVersion7:
+		case UUIDVersion1, UUIDVersion6:
+			// 节点标识：8位数据中心ID + 32位机器ID
+			if cfg.MachineID < 0 || cfg.MachineID > 0xFFFFFFFF {
+				return ErrInvalidMachine
+			}
+			if cfg.DatacenterID < 0 || cfg.DatacenterID > 0xFF {
+				return ErrInvalidMachine
+			}
+		default:
+			return ErrInvalidVersion
+		}
 	}
 
 	return nil
@@ -1,11 +1,16 @@
 package dispenser
 
+import "time"
+
 // NumberDispenser 统一的发号器接口
 // 所有持久化策略都实现这个接口
 type NumberDispenser interface {
 	// Next 生成下一个号码
 	Next() (string, error)
 
+	// NextN 批量生成 n 个号码（一次加锁、一次持久化）
+	NextN(n int) ([]string, error)
+
 	// GetConfig 获取配置
 	GetConfig() Config
 
@@ -20,6 +25,97 @@ type NumberDispenser interface {
 
 	// GetStats 获取统计信息
 	GetStats() DispenserStats
+
+	// AllocateSegment 租出一个号段 [start, end)，供客户端本地发号
+	AllocateSegment(segmentSize int64) (start, end int64, err error)
+}
+
+// Wrapper 包装其他发号器的装饰器（回收池、格式模板）
+type Wrapper interface {
+	// Unwrap 返回被包装的发号器
+	Unwrap() NumberDispenser
+}
+
+// As 沿装饰器链从外到内查找实现了接口 T 的发号器（类似 errors.As）
+// 装饰器只实现需要改写行为的可选接口，其余可选接口由 As 找到内部发号器，无需逐个委托
+func As[T any](d NumberDispenser) (T, bool) {
+	for d != nil {
+		if t, ok := d.(T); ok {
+			return t, true
+		}
+		w, ok := d.(Wrapper)
+		if !ok {
+			break
+		}
+		d = w.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// PeriodicDispenser 按周期重置计数器的发号器（Type 2 reset_period）
+// 周期标识需要与 current 一起持久化，重启后继续当前周期的序列
+type PeriodicDispenser interface {
+	// GetPeriod 获取计数器所属周期的标识
+	GetPeriod() string
+
+	// SetPeriod 恢复周期标识（在 SetCurrent 之前调用）
+	SetPeriod(period string)
+}
+
+// UniqueDispenser 记录已发号码用于去重的发号器（Type 1）
+// 已发号码通过增量日志持久化，重启后恢复，避免重复发号
+type UniqueDispenser interface {
+	// RestoreUsed 按发号顺序恢复已发号码
+	RestoreUsed(values []int64)
+
+	// SetUsedLog 设置已发号码的增量持久化回调
+	SetUsedLog(fn func(values []int64) error)
+
+	// UsedValues 返回当前用于去重的已发号码（用于重建发号器时带到新实例）
+	UsedValues() []int64
+}
+
+// RecyclableDispenser 支持回收号码的发号器（Type 1、2 recycle）
+// 回收池每次变化后完整持久化，重启后恢复
+type RecyclableDispenser interface {
+	// Release 将已发出的号码归还到回收池
+	Release(values []string) error
+
+	// RestorePool 按释放顺序恢复回收池
+	RestorePool(entries []PoolEntry)
+
+	// SetPoolLog 设置回收池的持久化回调
+	SetPoolLog(fn func(entries []PoolEntry) error)
+}
+
+// ReservableDispenser 支持保留号码的发号器（Type 1、2）
+// 保留的号码不会被 Next() 发出，只能通过 Claim 显式发出；保留状态每次变化后完整持久化
+type ReservableDispenser interface {
+	// Reserve 保留号码区间，返回新增的号码数
+	Reserve(ranges []ReservedRange) (int64, error)
+
+	// Unreserve 解除保留，返回实际解除的号码数
+	Unreserve(ranges []ReservedRange) (int64, error)
+
+	// Claim 发出一个保留的号码
+	Claim(v int64) (string, error)
+
+	// Reservations 获取保留中和已认领的号码
+	Reservations() ReservationState
+
+	// RestoreReservations 恢复保留状态
+	RestoreReservations(state ReservationState)
+
+	// SetReservationLog 设置保留状态的持久化回调
+	SetReservationLog(fn func(state ReservationState) error)
+}
+
+// BoundedDispenser 号码空间有限、可能耗尽的发号器（Type 1、2）
+// 用于计算剩余容量和预测耗尽时间
+type BoundedDispenser interface {
+	// Capacity 返回号码空间总数和剩余数；号码可以一直发下去（回绕、去重窗口等）时返回 false
+	Capacity() (Capacity, bool)
 }
 
 // DispenserStats 发号器统计信息
@@ -28,4 +124,34 @@ type DispenserStats struct {
 	TotalWasted    int64               // 总共浪费的号码数
 	WasteRate      float64             // 浪费率
 	Strategy       PersistenceStrategy // 持久化策略
+	Errors         map[string]int64    // 发号失败次数，按类型（ErrorKind*）统计，没有失败时为 nil
+
+	// 号段参数（号段策略生效的值）
+	SegmentSize        int64         // 下一个号段的大小（自适应模式下随消耗速度变化）
+	PreloadThreshold   float64       // 预加载阈值
+	CheckpointInterval time.Duration // checkpoint间隔
+	AdaptiveSegment    bool          // 是否启用自适应号段
+	SegmentMinSize     int64         // 自适应号段下限
+	SegmentMaxSize     int64         // 自适应号段上限
+	SegmentTarget      time.Duration // 自适应号段的目标耗尽时间
+	ConsumptionRate    float64       // 最近一次测得的消耗速度（个/秒）
+	SegmentSwitches    int64         // 切换到预加载号段的次数
+	SyncAllocations    int64         // 预加载号段未就绪、取号时同步分配号段的次数
+
+	// 时钟回拨（Type 4、5 v7、6）
+	ClockRollbacks      int64 // 检测到时钟回拨的次数
+	ClockRollbackErrors int64 // 因时钟回拨拒绝发号的次数
+	MaxClockRollback    int64 // 最大回拨幅度（毫秒）
+
+	// 去重集合（Type 1）
+	UniqueTracked int64 // 当前用于去重的号码数
+	UniqueMemory  int64 // 去重集合占用的内存（字节，估算值）
+
+	// 回收池（recycle）
+	PoolSize  int64 // 回收池中的号码数
+	PoolReady int64 // 已过冷却期、可以再次发出的号码数
+
+	// 保留号码（Type 1、2）
+	Reserved int64 // 保留中的号码数
+	Claimed  int64 // 通过 CLAIM 发出的号码数（Type 2）
 }
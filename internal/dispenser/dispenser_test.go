@@ -1,9 +1,13 @@
 package dispenser
 
 import (
+	"crypto/rand"
+	"io"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 // ============================================
@@ -111,6 +115,82 @@ func TestType2_NumericIncrementalSequence(t *testing.T) {
 	}
 }
 
+func TestType2_ResetPeriodDaily(t *testing.T) {
+	cfg := Config{
+		Type:        TypeNumericIncremental,
+		IncrMode:    IncrModeFixed,
+		Length:      6,
+		Starting:    1,
+		ResetPeriod: ResetDaily,
+		Timezone:    "UTC",
+	}
+
+	d, err := NewDispenser(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	today := time.Now().UTC().Format("20060102")
+	for _, exp := range []string{today + "000001", today + "000002"} {
+		num, err := d.Next()
+		if err != nil {
+			t.Fatalf("Failed to generate number: %v", err)
+		}
+		if num != exp {
+			t.Errorf("Expected %s, got %s", exp, num)
+		}
+	}
+
+	// 恢复同一天的周期：继续当天的序列
+	period := d.GetPeriod()
+	restored, _ := NewDispenser(cfg)
+	restored.SetPeriod(period)
+	restored.SetCurrent(d.GetCurrent())
+	if num, _ := restored.Next(); num != today+"000003" {
+		t.Errorf("Expected sequence to continue after restore, got %s", num)
+	}
+
+	// 恢复昨天的周期：进入新的一天后从 Starting 重新开始
+	restored.SetPeriod(time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02"))
+	restored.SetCurrent(500)
+	if num, _ := restored.Next(); num != today+"000001" {
+		t.Errorf("Expected counter reset on new day, got %s", num)
+	}
+
+	// 时钟回拨到上一个周期：不重置，前缀使用已记录周期的日期
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1)
+	restored.SetPeriod(tomorrow.Format("2006-01-02"))
+	restored.SetCurrent(42)
+	if num, _ := restored.Next(); num != tomorrow.Format("20060102")+"000042" {
+		t.Errorf("Expected no reset when clock is behind the period, got %s", num)
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	// 2026-01-01 是周四，属于ISO 2026年第1周；2027-01-01 属于ISO 2026年第53周
+	tests := []struct {
+		date   time.Time
+		format string
+		want   string
+	}{
+		{time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), "YYYYMMDD", "20261016"},
+		{time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), "INV-YY-MM-", "INV-26-10-"},
+		{time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), "YYYYWW", "202601"},
+		{time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), "YYYYWW", "202653"},
+	}
+	for _, tt := range tests {
+		if got := formatDate(tt.date, tt.format); got != tt.want {
+			t.Errorf("formatDate(%s, %q) = %s, want %s", tt.date.Format("2006-01-02"), tt.format, got, tt.want)
+		}
+	}
+
+	// 周一开始的ISO周
+	start := periodStart(time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC), ResetWeekly)
+	if start.Format("2006-01-02") != "2026-10-12" {
+		t.Errorf("Expected week to start on 2026-10-12, got %s", start.Format("2006-01-02"))
+	}
+}
+
 // ============================================
 // Type 3: 字符随机测试
 // ============================================
@@ -177,6 +257,105 @@ func TestType3_AlphanumericRandomBase62(t *testing.T) {
 	}
 }
 
+func TestType3_Alphabets(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		chars string
+	}{
+		{"base58", Config{Type: TypeAlphanumericRandom, Charset: CharsetBase58, Length: 20}, base58Chars},
+		{"crockford", Config{Type: TypeAlphanumericRandom, Charset: CharsetCrockford, Length: 20}, crockfordAlphabet},
+		{"custom", Config{Type: TypeAlphanumericRandom, Charset: CharsetCustom, CustomAlphabet: "BCDFGHJKLMNPQRSTVWXZ", Length: 8}, "BCDFGHJKLMNPQRSTVWXZ"},
+		{"custom implied", Config{Type: TypeAlphanumericRandom, CustomAlphabet: "AB", Length: 8}, "AB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDispenser(tt.cfg)
+			if err != nil {
+				t.Fatalf("Failed to create dispenser: %v", err)
+			}
+
+			for i := 0; i < 50; i++ {
+				num, err := d.Next()
+				if err != nil {
+					t.Fatalf("Failed to generate number: %v", err)
+				}
+				if len(num) != tt.cfg.Length {
+					t.Errorf("Expected length %d, got %s", tt.cfg.Length, num)
+				}
+				for _, c := range num {
+					if !strings.ContainsRune(tt.chars, c) {
+						t.Fatalf("Unexpected char %q in %s", c, num)
+					}
+				}
+				if err := Validate(d.GetConfig(), num); err != nil {
+					t.Errorf("Validate(%s) = %v", num, err)
+				}
+			}
+		})
+	}
+}
+
+// 94个字符时 256%94=68，若直接取模前68个字符的概率会高出50%
+func TestRandomString_NoModuloBias(t *testing.T) {
+	var printable []byte
+	for c := byte('!'); c <= '~'; c++ {
+		printable = append(printable, c)
+	}
+	chars := string(printable)
+	if !validAlphabet(chars) {
+		t.Fatalf("Test alphabet invalid: %q", chars)
+	}
+
+	sources := map[string]io.Reader{"secure": rand.Reader, "fast": newRand()}
+	for name, src := range sources {
+		counts := make(map[byte]int)
+		const samples = 200000
+		s, err := randomString(src, chars, samples)
+		if err != nil {
+			t.Fatalf("Failed to generate: %v", err)
+		}
+		for i := 0; i < len(s); i++ {
+			counts[s[i]]++
+		}
+
+		expected := float64(samples) / float64(len(chars))
+		for i := 0; i < len(chars); i++ {
+			if ratio := float64(counts[chars[i]]) / expected; ratio < 0.85 || ratio > 1.15 {
+				t.Errorf("%s: char %q frequency ratio %.2f, expected ~1.0", name, chars[i], ratio)
+			}
+		}
+	}
+}
+
+func TestRNG(t *testing.T) {
+	// 默认使用安全随机源
+	d, err := NewDispenser(Config{Type: TypeNumericRandom, Length: 8})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+	if d.GetConfig().RNG != RNGSecure {
+		t.Errorf("Expected default rng=secure, got %q", d.GetConfig().RNG)
+	}
+
+	// 同一时刻创建的 fast 发号器不应产生相同序列
+	a, _ := NewDispenser(Config{Type: TypeAlphanumericRandom, Charset: CharsetBase62, Length: 16, RNG: RNGFast})
+	b, _ := NewDispenser(Config{Type: TypeAlphanumericRandom, Charset: CharsetBase62, Length: 16, RNG: RNGFast})
+	x, _ := a.Next()
+	y, _ := b.Next()
+	if x == y {
+		t.Errorf("Two fast dispensers produced the same output %s", x)
+	}
+
+	if _, err := NewDispenser(Config{Type: TypeNumericIncremental, RNG: RNGSecure}); err != ErrInvalidRNG {
+		t.Errorf("Expected ErrInvalidRNG for Type 2, got %v", err)
+	}
+	if _, err := NewDispenser(Config{Type: TypeNumericRandom, Length: 6, RNG: "weak"}); err != ErrInvalidRNG {
+		t.Errorf("Expected ErrInvalidRNG, got %v", err)
+	}
+}
+
 // ============================================
 // Type 4: Snowflake测试
 // ============================================
@@ -215,6 +394,139 @@ func TestType4_Snowflake(t *testing.T) {
 	}
 }
 
+func TestType4_SnowflakeCustomLayout(t *testing.T) {
+	epoch := int64(1700000000000)
+	cfg := Config{
+		Type:           TypeSnowflake,
+		Epoch:          epoch,
+		TimestampBits:  41,
+		DatacenterBits: 3,
+		WorkerBits:     9,
+		SequenceBits:   10,
+		DatacenterID:   5,
+		MachineID:      300, // 超过默认5位上限，新布局下不应被截断
+	}
+
+	d, err := NewDispenser(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	num, err := d.Next()
+	if err != nil {
+		t.Fatalf("Failed to generate number: %v", err)
+	}
+
+	id, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		t.Fatalf("Invalid snowflake ID %s: %v", num, err)
+	}
+
+	if worker := (id >> 10) & 0x1FF; worker != 300 {
+		t.Errorf("Expected worker 300, got %d", worker)
+	}
+	if datacenter := (id >> 19) & 0x7; datacenter != 5 {
+		t.Errorf("Expected datacenter 5, got %d", datacenter)
+	}
+
+	ts := id>>22 + epoch
+	if now := time.Now().UnixMilli(); ts > now || now-ts > 1000 {
+		t.Errorf("Decoded timestamp %d too far from now %d", ts, now)
+	}
+}
+
+func TestClockRollback(t *testing.T) {
+	newSnowflake := func(policy ClockRollbackPolicy, waitMs int64) *Dispenser {
+		d, err := NewDispenser(Config{
+			Type:           TypeSnowflake,
+			MachineID:      1,
+			ClockRollback:  policy,
+			RollbackWaitMs: waitMs,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create dispenser: %v", err)
+		}
+		return d
+	}
+
+	// 模拟重启后时钟落后于持久化的上次发号时间
+	ahead := time.Now().UnixMilli() + 10000
+
+	t.Run("error", func(t *testing.T) {
+		d := newSnowflake(RollbackError, 0)
+		d.SetCurrent(ahead)
+		if _, err := d.Next(); err != ErrClockMovedBackwards {
+			t.Fatalf("Expected ErrClockMovedBackwards, got %v", err)
+		}
+		if stats := d.GetStats(); stats.ClockRollbacks != 1 || stats.ClockRollbackErrors != 1 {
+			t.Errorf("Unexpected rollback stats: %+v", stats)
+		}
+	})
+
+	t.Run("wait exceeds limit", func(t *testing.T) {
+		d := newSnowflake(RollbackWait, 5)
+		d.SetCurrent(ahead)
+		if _, err := d.Next(); err != ErrClockMovedBackwards {
+			t.Fatalf("Expected ErrClockMovedBackwards, got %v", err)
+		}
+	})
+
+	t.Run("wait within limit", func(t *testing.T) {
+		d := newSnowflake(RollbackWait, 100)
+		d.SetCurrent(time.Now().UnixMilli() + 20)
+		if _, err := d.Next(); err != nil {
+			t.Fatalf("Expected wait to succeed, got %v", err)
+		}
+		if stats := d.GetStats(); stats.ClockRollbacks != 1 || stats.ClockRollbackErrors != 0 {
+			t.Errorf("Unexpected rollback stats: %+v", stats)
+		}
+	})
+
+	t.Run("wait without lock", func(t *testing.T) {
+		d := newSnowflake(RollbackWait, 1000)
+		d.SetCurrent(time.Now().UnixMilli() + 300)
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := d.Next()
+			done <- err
+		}()
+
+		// 等待期间其他调用不被阻塞
+		time.Sleep(50 * time.Millisecond)
+		start := time.Now()
+		d.GetStats()
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("GetStats blocked for %v while waiting for the clock", elapsed)
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("Expected wait to succeed, got %v", err)
+		}
+	})
+
+	t.Run("borrow", func(t *testing.T) {
+		d := newSnowflake(RollbackBorrow, 0)
+		d.SetCurrent(ahead)
+
+		var prev int64
+		for i := 0; i < 10000; i++ {
+			num, err := d.Next()
+			if err != nil {
+				t.Fatalf("Expected borrowed clock to succeed, got %v", err)
+			}
+			id, _ := strconv.ParseInt(num, 10, 64)
+			if id <= prev {
+				t.Fatalf("Snowflake IDs not increasing under borrowed clock: %d <= %d", id, prev)
+			}
+			prev = id
+		}
+
+		if d.GetCurrent() < ahead {
+			t.Errorf("Expected persisted timestamp >= %d, got %d", ahead, d.GetCurrent())
+		}
+	})
+}
+
 // ============================================
 // Type 5: UUID测试
 // ============================================
@@ -279,6 +591,132 @@ func TestType5_UUIDCompact(t *testing.T) {
 	}
 }
 
+func TestType5_UUIDTimeOrdered(t *testing.T) {
+	for _, version := range []int{UUIDVersion6, UUIDVersion7} {
+		for _, format := range []UUIDFormat{UUIDFormatStandard, UUIDFormatCompact} {
+			cfg := Config{
+				Type:        TypeUUID,
+				UUIDVersion: version,
+				UUIDFormat:  format,
+				MachineID:   42,
+			}
+
+			d, err := NewDispenser(cfg)
+			if err != nil {
+				t.Fatalf("Failed to create dispenser: %v", err)
+			}
+
+			prev := ""
+			for i := 0; i < 5000; i++ {
+				id, err := d.Next()
+				if err != nil {
+					t.Fatalf("Failed to generate UUID: %v", err)
+				}
+
+				compact := strings.ReplaceAll(id, "-", "")
+				if len(compact) != 32 {
+					t.Fatalf("Invalid UUID: %s", id)
+				}
+				if compact[12] != byte('0'+version) {
+					t.Fatalf("Expected version %d, got %s", version, id)
+				}
+
+				// 同一发号器内严格递增（同一毫秒也不例外）
+				if id <= prev {
+					t.Fatalf("v%d UUID not ordered: %s <= %s", version, id, prev)
+				}
+				prev = id
+			}
+		}
+	}
+}
+
+func TestType5_UUIDv1Node(t *testing.T) {
+	cfg := Config{
+		Type:         TypeUUID,
+		UUIDVersion:  UUIDVersion1,
+		UUIDFormat:   UUIDFormatCompact,
+		DatacenterID: 3,
+		MachineID:    0x0A0B0C0D,
+	}
+
+	d, err := NewDispenser(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	id, err := d.Next()
+	if err != nil {
+		t.Fatalf("Failed to generate UUID: %v", err)
+	}
+
+	if id[12] != '1' {
+		t.Errorf("Expected version 1, got %s", id)
+	}
+	if node := id[20:]; node != "01030a0b0c0d" {
+		t.Errorf("Expected node 01030a0b0c0d, got %s", node)
+	}
+}
+
+// ============================================
+// Type 6: ULID测试
+// ============================================
+
+func TestType6_ULIDMonotonic(t *testing.T) {
+	cfg := Config{
+		Type:      TypeULID,
+		Monotonic: true,
+	}
+
+	d, err := NewDispenser(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	prev := ""
+	for i := 0; i < 1000; i++ {
+		id, err := d.Next()
+		if err != nil {
+			t.Fatalf("Failed to generate ULID: %v", err)
+		}
+
+		if len(id) != 26 {
+			t.Errorf("Expected length 26, got %d: %s", len(id), id)
+		}
+		for _, c := range id {
+			if !strings.ContainsRune(crockfordAlphabet, c) {
+				t.Errorf("Invalid Crockford char in %s", id)
+			}
+		}
+
+		// 单调模式下字典序严格递增
+		if id <= prev {
+			t.Errorf("ULID not monotonic: %s <= %s", id, prev)
+		}
+		prev = id
+	}
+}
+
+func TestULIDEncoding(t *testing.T) {
+	var zero, max [16]byte
+	for i := range max {
+		max[i] = 0xFF
+	}
+
+	if got := encodeULID(zero); got != "00000000000000000000000000" {
+		t.Errorf("Unexpected zero ULID: %s", got)
+	}
+	if got := encodeULID(max); got != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Errorf("Unexpected max ULID: %s", got)
+	}
+
+	// 随机部分溢出
+	full := []byte{0xFF, 0xFF}
+	if incrementBytes(full) {
+		t.Error("Expected overflow when incrementing all 0xFF bytes")
+	}
+}
+
 // ============================================
 // 并发测试
 // ============================================
@@ -334,6 +772,97 @@ func TestConcurrency(t *testing.T) {
 	}
 }
 
+// ============================================
+// 批量取号测试
+// ============================================
+
+func TestNextN_Incremental(t *testing.T) {
+	cfg := Config{
+		Type:     TypeNumericIncremental,
+		IncrMode: IncrModeSequence,
+		Starting: 10,
+		Step:     2,
+	}
+
+	d, err := NewDispenser(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	nums, err := d.NextN(3)
+	if err != nil {
+		t.Fatalf("Failed to generate batch: %v", err)
+	}
+
+	expected := []string{"10", "12", "14"}
+	for i, exp := range expected {
+		if nums[i] != exp {
+			t.Errorf("Index %d: expected %s, got %s", i, exp, nums[i])
+		}
+	}
+
+	if d.GetCurrent() != 16 {
+		t.Errorf("Expected current=16, got %d", d.GetCurrent())
+	}
+}
+
+func TestNextN_RollbackOnExhausted(t *testing.T) {
+	cfg := Config{
+		Type:     TypeNumericIncremental,
+		IncrMode: IncrModeFixed,
+		Length:   2,
+		Starting: 97,
+	}
+
+	d, err := NewDispenser(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	// 只剩 97, 98, 99 三个号码，批量取 5 个应失败且不消耗号码
+	if _, err := d.NextN(5); err != ErrNumberExhausted {
+		t.Fatalf("Expected ErrNumberExhausted, got %v", err)
+	}
+
+	num, err := d.Next()
+	if err != nil {
+		t.Fatalf("Failed to generate number: %v", err)
+	}
+	if num != "97" {
+		t.Errorf("Expected 97 after rollback, got %s", num)
+	}
+}
+
+func TestNextN_RandomUnique(t *testing.T) {
+	cfg := Config{
+		Type:   TypeNumericRandom,
+		Length: 4,
+	}
+
+	d, err := NewDispenser(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		nums, err := d.NextN(100)
+		if err != nil {
+			t.Fatalf("Failed to generate batch: %v", err)
+		}
+		for _, num := range nums {
+			if seen[num] {
+				t.Errorf("Duplicate number across batches: %s", num)
+			}
+			seen[num] = true
+		}
+	}
+
+	if _, err := d.NextN(0); err != ErrInvalidCount {
+		t.Errorf("Expected ErrInvalidCount, got %v", err)
+	}
+}
+
 // ============================================
 // 配置验证测试
 // ============================================
@@ -397,6 +926,44 @@ func TestValidation(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "type 3 custom alphabet with duplicates",
+			cfg: Config{
+				Type:           TypeAlphanumericRandom,
+				Charset:        CharsetCustom,
+				CustomAlphabet: "ABCA",
+				Length:         16,
+			},
+			wantErr: true,
+		},
+		{
+			name: "type 3 custom alphabet too small",
+			cfg: Config{
+				Type:           TypeAlphanumericRandom,
+				CustomAlphabet: "A",
+				Length:         16,
+			},
+			wantErr: true,
+		},
+		{
+			name: "type 3 custom charset without alphabet",
+			cfg: Config{
+				Type:    TypeAlphanumericRandom,
+				Charset: CharsetCustom,
+				Length:  16,
+			},
+			wantErr: true,
+		},
+		{
+			name: "type 3 builtin charset with alphabet",
+			cfg: Config{
+				Type:           TypeAlphanumericRandom,
+				Charset:        CharsetBase58,
+				CustomAlphabet: "ABC",
+				Length:         16,
+			},
+			wantErr: true,
+		},
 		{
 			name: "type 3 invalid charset",
 			cfg: Config{
@@ -422,6 +989,45 @@ func TestValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "type 4 layout not 63 bits",
+			cfg: Config{
+				Type:           TypeSnowflake,
+				TimestampBits:  41,
+				DatacenterBits: 5,
+				WorkerBits:     5,
+				SequenceBits:   10,
+			},
+			wantErr: true,
+		},
+		{
+			name: "type 4 machine_id exceeds worker bits",
+			cfg: Config{
+				Type:           TypeSnowflake,
+				TimestampBits:  41,
+				DatacenterBits: 6,
+				WorkerBits:     4,
+				SequenceBits:   12,
+				MachineID:      16,
+			},
+			wantErr: true,
+		},
+		{
+			name: "type 4 epoch in the future",
+			cfg: Config{
+				Type:  TypeSnowflake,
+				Epoch: time.Now().Add(time.Hour).UnixMilli(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "type 4 invalid clock_rollback",
+			cfg: Config{
+				Type:          TypeSnowflake,
+				ClockRollback: "ignore",
+			},
+			wantErr: true,
+		},
 		{
 			name: "valid type 5 uuid",
 			cfg: Config{
@@ -430,6 +1036,58 @@ func TestValidation(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "type 5 invalid uuid version",
+			cfg: Config{
+				Type:        TypeUUID,
+				UUIDVersion: 3,
+			},
+			wantErr: true,
+		},
+		{
+			name: "type 5 v1 datacenter out of range",
+			cfg: Config{
+				Type:         TypeUUID,
+				UUIDVersion:  UUIDVersion1,
+				DatacenterID: 256,
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid type 6 ulid",
+			cfg: Config{
+				Type:      TypeULID,
+				Monotonic: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "type 2 date format cannot distinguish days",
+			cfg: Config{
+				Type:        TypeNumericIncremental,
+				ResetPeriod: ResetDaily,
+				DateFormat:  "YYYYMM",
+			},
+			wantErr: true,
+		},
+		{
+			name: "type 2 invalid timezone",
+			cfg: Config{
+				Type:        TypeNumericIncremental,
+				ResetPeriod: ResetMonthly,
+				Timezone:    "Mars/Olympus",
+			},
+			wantErr: true,
+		},
+		{
+			name: "reset period on non-incremental type",
+			cfg: Config{
+				Type:        TypeNumericRandom,
+				Length:      7,
+				ResetPeriod: ResetDaily,
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid type",
 			cfg: Config{
@@ -0,0 +1,75 @@
+package dispenser
+
+import (
+	"errors"
+	"sync"
+)
+
+// 发号失败的类型（DispenserStats.Errors 的键）
+const (
+	ErrorKindExhausted     = "exhausted"      // 号码耗尽
+	ErrorKindClockRollback = "clock_rollback" // 时钟回拨，拒绝发号
+	ErrorKindPersist       = "persist"        // 号段、去重日志或回收池持久化失败
+	ErrorKindOther         = "other"          // 其他错误（随机源等）
+)
+
+// persistError 持久化失败，错误信息保持不变，只用于按类型统计
+type persistError struct {
+	err error
+}
+
+func (e *persistError) Error() string { return e.err.Error() }
+
+func (e *persistError) Unwrap() error { return e.err }
+
+// errorKind 返回发号失败的类型
+func errorKind(err error) string {
+	var pe *persistError
+	switch {
+	case errors.As(err, &pe):
+		return ErrorKindPersist
+	case errors.Is(err, ErrNumberExhausted):
+		return ErrorKindExhausted
+	case errors.Is(err, ErrClockMovedBackwards):
+		return ErrorKindClockRollback
+	default:
+		return ErrorKindOther
+	}
+}
+
+// errorCounter 按类型统计发号失败的次数（零值可用）
+type errorCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// record 记录一次发号失败并原样返回错误，err 为 nil 时不记录
+func (c *errorCounter) record(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]int64)
+	}
+	c.counts[errorKind(err)]++
+	return err
+}
+
+// fillStats 将失败次数累加到统计信息中（装饰器与内部发号器的次数合并）
+func (c *errorCounter) fillStats(stats *DispenserStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.counts) == 0 {
+		return
+	}
+	if stats.Errors == nil {
+		stats.Errors = make(map[string]int64, len(c.counts))
+	}
+	for kind, n := range c.counts {
+		stats.Errors[kind] += n
+	}
+}
@@ -5,20 +5,70 @@ import (
 	"time"
 )
 
+// 号段策略的默认参数
+const (
+	DefaultSegmentSize        int64 = 1000
+	DefaultPreloadThreshold         = 0.1
+	DefaultCheckpointInterval       = 2 * time.Second
+)
+
 // DispenserFactory 发号器工厂
 type DispenserFactory struct {
 	persistFunc func(string, Config, int64) error
+
+	segmentSize        int64         // 号段大小
+	checkpointInterval time.Duration // checkpoint间隔
+	nodeID             string        // 节点ID（格式模板 {node} 使用）
 }
 
 // NewDispenserFactory 创建发号器工厂
 func NewDispenserFactory(persistFunc func(string, Config, int64) error) *DispenserFactory {
 	return &DispenserFactory{
-		persistFunc: persistFunc,
+		persistFunc:        persistFunc,
+		segmentSize:        DefaultSegmentSize,
+		checkpointInterval: DefaultCheckpointInterval,
+	}
+}
+
+// SetSegmentDefaults 设置号段策略的号段大小和checkpoint间隔（非正值保持不变）
+func (f *DispenserFactory) SetSegmentDefaults(segmentSize int64, checkpointInterval time.Duration) {
+	if segmentSize > 0 {
+		f.segmentSize = segmentSize
+	}
+	if checkpointInterval > 0 {
+		f.checkpointInterval = checkpointInterval
 	}
 }
 
+// SetNodeID 设置节点ID，用于格式模板中的 {node}
+func (f *DispenserFactory) SetNodeID(nodeID string) {
+	f.nodeID = nodeID
+}
+
 // CreateDispenser 根据配置创建发号器
+// 启用号码回收时套上 RecyclingDispenser，配置了格式模板时再套上 FormattedDispenser
 func (f *DispenserFactory) CreateDispenser(name string, cfg Config) (NumberDispenser, error) {
+	d, err := f.createDispenser(name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Recycle {
+		d = newRecyclingDispenser(d)
+	}
+	if cfg.Format == "" {
+		return d, nil
+	}
+
+	fd, err := newFormattedDispenser(d, name, f.nodeID)
+	if err != nil {
+		d.Shutdown()
+		return nil, err
+	}
+	return fd, nil
+}
+
+// createDispenser 按持久化策略创建发号器
+func (f *DispenserFactory) createDispenser(name string, cfg Config) (NumberDispenser, error) {
 	// 如果没有指定策略，默认使用 elegant_close
 	if cfg.AutoDisk == "" {
 		cfg.AutoDisk = StrategyElegantClose
@@ -29,6 +79,16 @@ func (f *DispenserFactory) CreateDispenser(name string, cfg Config) (NumberDispe
 		return nil, fmt.Errorf("invalid persistence strategy: %s", cfg.AutoDisk)
 	}
 
+	// 号段策略按数值预分配，无法按周期重置
+	if cfg.ResetPeriod != "" && cfg.AutoDisk != StrategyMemory && cfg.AutoDisk != StrategyElegantClose {
+		return nil, fmt.Errorf("reset_period is not supported by persistence strategy %s", cfg.AutoDisk)
+	}
+
+	// 号段只能按计数器分配；毫秒时钟类型每次发号都要持久化上次发号的时间戳，只能使用 memory、elegant_close
+	if UsesMillisClock(cfg) && cfg.AutoDisk != StrategyMemory && cfg.AutoDisk != StrategyElegantClose {
+		return nil, fmt.Errorf("persistence strategy %s is not supported by clock-based types", cfg.AutoDisk)
+	}
+
 	switch cfg.AutoDisk {
 	case StrategyMemory:
 		return f.createMemoryDispenser(cfg)
@@ -50,6 +110,26 @@ func (f *DispenserFactory) CreateDispenser(name string, cfg Config) (NumberDispe
 	}
 }
 
+// segmentSettings 返回发号器生效的号段参数：优先使用发号器自身配置，否则使用工厂默认值
+func (f *DispenserFactory) segmentSettings(cfg Config) (segmentSize int64, threshold float64, checkpointInterval time.Duration) {
+	segmentSize = f.segmentSize
+	if cfg.SegmentSize > 0 {
+		segmentSize = cfg.SegmentSize
+	}
+
+	threshold = DefaultPreloadThreshold
+	if cfg.PreloadThreshold > 0 {
+		threshold = cfg.PreloadThreshold
+	}
+
+	checkpointInterval = f.checkpointInterval
+	if cfg.CheckpointInterval > 0 {
+		checkpointInterval = cfg.CheckpointInterval
+	}
+
+	return segmentSize, threshold, checkpointInterval
+}
+
 // createMemoryDispenser 创建内存模式发号器（不持久化）
 func (f *DispenserFactory) createMemoryDispenser(cfg Config) (NumberDispenser, error) {
 	return NewDispenser(cfg)
@@ -57,7 +137,7 @@ func (f *DispenserFactory) createMemoryDispenser(cfg Config) (NumberDispenser, e
 
 // createPreBaseDispenser 创建预分配基础版发号器
 func (f *DispenserFactory) createPreBaseDispenser(name string, cfg Config) (NumberDispenser, error) {
-	segmentSize := int64(1000) // 默认号段大小
+	segmentSize, threshold, _ := f.segmentSettings(cfg)
 
 	persistFunc := func(val int64) error {
 		if f.persistFunc != nil {
@@ -66,13 +146,12 @@ func (f *DispenserFactory) createPreBaseDispenser(name string, cfg Config) (Numb
 		return nil
 	}
 
-	return NewSegmentDispenser(cfg, segmentSize, 0.1, persistFunc)
+	return NewSegmentDispenser(cfg, segmentSize, threshold, persistFunc)
 }
 
 // createPreCheckpointDispenser 创建预分配+检查点发号器
 func (f *DispenserFactory) createPreCheckpointDispenser(name string, cfg Config) (NumberDispenser, error) {
-	segmentSize := int64(1000)
-	checkpointInterval := 2 * time.Second // 2秒检查点
+	segmentSize, threshold, checkpointInterval := f.segmentSettings(cfg)
 
 	persistFunc := func(val int64) error {
 		if f.persistFunc != nil {
@@ -81,7 +160,7 @@ func (f *DispenserFactory) createPreCheckpointDispenser(name string, cfg Config)
 		return nil
 	}
 
-	return NewOptimizedSegmentDispenser(cfg, segmentSize, 0.1, checkpointInterval, persistFunc)
+	return NewOptimizedSegmentDispenser(cfg, segmentSize, threshold, checkpointInterval, persistFunc)
 }
 
 // createElegantCloseDispenser 创建优雅关闭模式发号器（立即保存）
@@ -92,8 +171,7 @@ func (f *DispenserFactory) createElegantCloseDispenser(cfg Config) (NumberDispen
 
 // createPreCloseDispenser 创建预分配+检查点+优雅关闭发号器（最优）
 func (f *DispenserFactory) createPreCloseDispenser(name string, cfg Config) (NumberDispenser, error) {
-	segmentSize := int64(1000)
-	checkpointInterval := 2 * time.Second
+	segmentSize, threshold, checkpointInterval := f.segmentSettings(cfg)
 
 	persistFunc := func(val int64) error {
 		if f.persistFunc != nil {
@@ -102,5 +180,5 @@ func (f *DispenserFactory) createPreCloseDispenser(name string, cfg Config) (Num
 		return nil
 	}
 
-	return NewOptimizedSegmentDispenser(cfg, segmentSize, 0.1, checkpointInterval, persistFunc)
+	return NewOptimizedSegmentDispenser(cfg, segmentSize, threshold, checkpointInterval, persistFunc)
 }
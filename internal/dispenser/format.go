@@ -0,0 +1,459 @@
+package dispenser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidTemplate = errors.New("invalid format template")
+
+// 模板占位符：
+//
+//	{seq} / {rand} / {id}  发号器生成的值（三者等价），{seq:8} 表示左侧补零到8位
+//	{date}                 日期，默认 YYYYMMDD，{date:YYYY-MM} 自定义格式
+//	{time}                 时间，默认 HHmmss，{time:HHmm} 自定义格式
+//	{name}                 发号器名称
+//	{node}                 节点ID（cluster.node_id）
+//
+// {{ 和 }} 分别表示字面量 { 和 }
+//
+// 示例：ORD-{date}-{seq:8}、U{rand:6}、{node}-{id}
+
+// templatePartKind 模板片段类型
+type templatePartKind int
+
+const (
+	partLiteral templatePartKind = iota
+	partValue
+	partDate
+	partName
+	partNode
+)
+
+// templatePart 模板片段
+type templatePart struct {
+	kind  templatePartKind
+	text  string // 字面量内容，或日期时间格式
+	width int    // 值的补零宽度，0表示不补零
+}
+
+// maxTemplateWidth 值补零宽度上限
+const maxTemplateWidth = 64
+
+// parseTemplate 解析格式模板，模板必须包含且只包含一个值占位符
+func parseTemplate(format string) ([]templatePart, error) {
+	var parts []templatePart
+	var literal strings.Builder
+	values := 0
+
+	flush := func() {
+		if literal.Len() > 0 {
+			parts = append(parts, templatePart{kind: partLiteral, text: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		switch {
+		case c == '{' && strings.HasPrefix(format[i:], "{{"):
+			literal.WriteByte('{')
+			i++
+		case c == '}' && strings.HasPrefix(format[i:], "}}"):
+			literal.WriteByte('}')
+			i++
+		case c == '}':
+			return nil, ErrInvalidTemplate
+		case c == '{':
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return nil, ErrInvalidTemplate
+			}
+			part, err := parsePlaceholder(format[i+1 : i+end])
+			if err != nil {
+				return nil, err
+			}
+			if part.kind == partValue {
+				values++
+			}
+			flush()
+			parts = append(parts, part)
+			i += end
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	flush()
+
+	if values != 1 {
+		return nil, ErrInvalidTemplate
+	}
+	return parts, nil
+}
+
+// parsePlaceholder 解析 {name:arg} 中的内容
+func parsePlaceholder(placeholder string) (templatePart, error) {
+	name, arg, hasArg := strings.Cut(placeholder, ":")
+
+	switch name {
+	case "seq", "rand", "id":
+		part := templatePart{kind: partValue}
+		if hasArg {
+			width, err := strconv.Atoi(arg)
+			if err != nil || width <= 0 || width > maxTemplateWidth {
+				return templatePart{}, ErrInvalidTemplate
+			}
+			part.width = width
+		}
+		return part, nil
+
+	case "date", "time":
+		layout := "YYYYMMDD"
+		if name == "time" {
+			layout = "HHmmss"
+		}
+		if hasArg {
+			if arg == "" {
+				return templatePart{}, ErrInvalidTemplate
+			}
+			layout = arg
+		}
+		return templatePart{kind: partDate, text: layout}, nil
+
+	case "name", "node":
+		if hasArg {
+			return templatePart{}, ErrInvalidTemplate
+		}
+		if name == "name" {
+			return templatePart{kind: partName}, nil
+		}
+		return templatePart{kind: partNode}, nil
+	}
+
+	return templatePart{}, ErrInvalidTemplate
+}
+
+// validateTemplate 检查模板语法，以及补零宽度能否容纳该类型可能生成的最长值
+// 值超出补零宽度后输出长度不再固定，不同长度的号码混在一起会破坏定长号码的唯一性空间
+func validateTemplate(cfg Config) error {
+	parts, err := parseTemplate(cfg.Format)
+	if err != nil {
+		return err
+	}
+
+	for _, part := range parts {
+		if part.kind == partValue && part.width > 0 && part.width < maxValueWidth(cfg) {
+			return ErrInvalidTemplate
+		}
+	}
+	return nil
+}
+
+// maxValueWidth 返回 Next() 可能生成的最长值的长度
+func maxValueWidth(cfg Config) int {
+	switch cfg.Type {
+	case TypeNumericRandom:
+		return cfg.Length + checkDigitLen(cfg.CheckDigit)
+
+	case TypeAlphanumericRandom:
+		return cfg.Length
+
+	case TypeNumericIncremental:
+		width := len(strconv.FormatInt(int64(^uint64(0)>>1), 10))
+		if fixedWidth(cfg) {
+			width = cfg.Length
+			if cfg.OnExhausted == ExhaustedExtend {
+				width = 18
+			}
+		}
+		if cfg.ResetPeriod != "" {
+			width += len(formatDate(time.Now(), dateFormat(cfg)))
+		}
+		return width + checkDigitLen(cfg.CheckDigit)
+
+	case TypeSnowflake:
+		// 63位正整数的十进制最长19位
+		return len(strconv.FormatInt(int64(^uint64(0)>>1), 10))
+
+	case TypeUUID:
+		if cfg.UUIDFormat == UUIDFormatCompact {
+			return 32
+		}
+		return 36
+
+	case TypeULID:
+		return 26
+	}
+	return 0
+}
+
+// ============================================
+// 模板包装
+// ============================================
+
+// FormattedDispenser 按格式模板包装任意发号器的输出
+// 名称和节点ID在创建时确定，其余方法直接委托给内部发号器（可选接口通过 As 查找）
+type FormattedDispenser struct {
+	NumberDispenser
+	parts []templatePart
+	name  string
+	node  string
+	loc   *time.Location
+}
+
+// newFormattedDispenser 为发号器套上格式模板（配置已通过 validateConfig 校验）
+func newFormattedDispenser(inner NumberDispenser, name, node string) (*FormattedDispenser, error) {
+	cfg := inner.GetConfig()
+
+	parts, err := parseTemplate(cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := loadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+
+	return &FormattedDispenser{
+		NumberDispenser: inner,
+		parts:           parts,
+		name:            name,
+		node:            node,
+		loc:             loc,
+	}, nil
+}
+
+// Next 生成下一个号码并套用模板
+func (fd *FormattedDispenser) Next() (string, error) {
+	value, err := fd.NumberDispenser.Next()
+	if err != nil {
+		return "", err
+	}
+	return fd.render(value, time.Now()), nil
+}
+
+// NextN 批量生成号码并套用模板
+func (fd *FormattedDispenser) NextN(n int) ([]string, error) {
+	values, err := fd.NumberDispenser.NextN(n)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i, value := range values {
+		values[i] = fd.render(value, now)
+	}
+	return values, nil
+}
+
+// render 按模板拼接号码
+func (fd *FormattedDispenser) render(value string, now time.Time) string {
+	now = now.In(fd.loc)
+
+	var b strings.Builder
+	for _, part := range fd.parts {
+		switch part.kind {
+		case partLiteral:
+			b.WriteString(part.text)
+		case partValue:
+			for i := len(value); i < part.width; i++ {
+				b.WriteByte('0')
+			}
+			b.WriteString(value)
+		case partDate:
+			b.WriteString(formatDate(now, part.text))
+		case partName:
+			b.WriteString(fd.name)
+		case partNode:
+			b.WriteString(fd.node)
+		}
+	}
+	return b.String()
+}
+
+// valueBounds 返回值占位符之前和之后的片段总长度
+// 除值以外的片段长度都是固定的
+func (fd *FormattedDispenser) valueBounds() (prefix, suffix int) {
+	seenValue := false
+	for _, part := range fd.parts {
+		var width int
+		switch part.kind {
+		case partLiteral:
+			width = len(part.text)
+		case partDate:
+			width = len(formatDate(time.Time{}, part.text))
+		case partName:
+			width = len(fd.name)
+		case partNode:
+			width = len(fd.node)
+		case partValue:
+			seenValue = true
+			continue
+		}
+		if seenValue {
+			suffix += width
+		} else {
+			prefix += width
+		}
+	}
+	return prefix, suffix
+}
+
+// ExtractValue 从格式化后的号码中取出发号器生成的原始值（用于 DECODE、VALIDATE）
+// 按前后缀长度截取
+func (fd *FormattedDispenser) ExtractValue(id string) (string, error) {
+	prefix, suffix := fd.valueBounds()
+	if len(id) <= prefix+suffix {
+		return "", ErrInvalidID
+	}
+	value := id[prefix : len(id)-suffix]
+
+	// 去掉模板补的前导零
+	if width := maxValueWidth(fd.GetConfig()); width > 0 && len(value) > width {
+		value = value[len(value)-width:]
+	}
+	return value, nil
+}
+
+// matchTemplate 检查号码与模板的固定部分一致：字面量、名称和节点ID逐字比较，
+// 日期时间只比较数字位置，补零部分须全为0
+func (fd *FormattedDispenser) matchTemplate(id string) bool {
+	prefix, suffix := fd.valueBounds()
+	if len(id) <= prefix+suffix {
+		return false
+	}
+
+	pos := 0
+	for _, part := range fd.parts {
+		var expected string
+		switch part.kind {
+		case partLiteral:
+			expected = part.text
+		case partName:
+			expected = fd.name
+		case partNode:
+			expected = fd.node
+		case partDate:
+			expected = formatDate(time.Time{}, part.text)
+		case partValue:
+			region := id[prefix : len(id)-suffix]
+			if len(region) < part.width {
+				return false
+			}
+			if width := maxValueWidth(fd.GetConfig()); width > 0 && len(region) > width &&
+				strings.Trim(region[:len(region)-width], "0") != "" {
+				return false
+			}
+			pos = len(id) - suffix
+			continue
+		}
+
+		got := id[pos : pos+len(expected)]
+		if part.kind == partDate {
+			for i := 0; i < len(got); i++ {
+				if isDigits(expected[i:i+1]) != isDigits(got[i:i+1]) {
+					return false
+				}
+			}
+		} else if got != expected {
+			return false
+		}
+		pos += len(expected)
+	}
+	return true
+}
+
+// Unwrap 返回被包装的发号器
+// 只有需要处理格式模板的 Release、Claim 所在的接口由 FormattedDispenser 实现，其余可选接口通过 As 查找
+func (fd *FormattedDispenser) Unwrap() NumberDispenser {
+	return fd.NumberDispenser
+}
+
+// Release 去掉格式模板后归还给支持回收的内部发号器
+func (fd *FormattedDispenser) Release(values []string) error {
+	rd, ok := As[RecyclableDispenser](fd.NumberDispenser)
+	if !ok {
+		return ErrRecycleDisabled
+	}
+
+	raw := make([]string, len(values))
+	for i, id := range values {
+		if !fd.matchTemplate(id) {
+			return fmt.Errorf("%w: %s", ErrInvalidID, id)
+		}
+		value, err := fd.ExtractValue(id)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, id)
+		}
+		raw[i] = value
+	}
+	return rd.Release(raw)
+}
+
+// RestorePool 委托给支持回收的内部发号器
+func (fd *FormattedDispenser) RestorePool(entries []PoolEntry) {
+	if rd, ok := As[RecyclableDispenser](fd.NumberDispenser); ok {
+		rd.RestorePool(entries)
+	}
+}
+
+// SetPoolLog 委托给支持回收的内部发号器
+func (fd *FormattedDispenser) SetPoolLog(fn func(entries []PoolEntry) error) {
+	if rd, ok := As[RecyclableDispenser](fd.NumberDispenser); ok {
+		rd.SetPoolLog(fn)
+	}
+}
+
+// Reserve 委托给支持保留号码的内部发号器
+func (fd *FormattedDispenser) Reserve(ranges []ReservedRange) (int64, error) {
+	if rd, ok := As[ReservableDispenser](fd.NumberDispenser); ok {
+		return rd.Reserve(ranges)
+	}
+	return 0, ErrNotReservable
+}
+
+// Unreserve 委托给支持保留号码的内部发号器
+func (fd *FormattedDispenser) Unreserve(ranges []ReservedRange) (int64, error) {
+	if rd, ok := As[ReservableDispenser](fd.NumberDispenser); ok {
+		return rd.Unreserve(ranges)
+	}
+	return 0, ErrNotReservable
+}
+
+// Claim 发出保留的号码并套用模板
+func (fd *FormattedDispenser) Claim(v int64) (string, error) {
+	rd, ok := As[ReservableDispenser](fd.NumberDispenser)
+	if !ok {
+		return "", ErrNotReservable
+	}
+	value, err := rd.Claim(v)
+	if err != nil {
+		return "", err
+	}
+	return fd.render(value, time.Now()), nil
+}
+
+// Reservations 委托给支持保留号码的内部发号器
+func (fd *FormattedDispenser) Reservations() ReservationState {
+	if rd, ok := As[ReservableDispenser](fd.NumberDispenser); ok {
+		return rd.Reservations()
+	}
+	return ReservationState{}
+}
+
+// RestoreReservations 委托给支持保留号码的内部发号器
+func (fd *FormattedDispenser) RestoreReservations(state ReservationState) {
+	if rd, ok := As[ReservableDispenser](fd.NumberDispenser); ok {
+		rd.RestoreReservations(state)
+	}
+}
+
+// SetReservationLog 委托给支持保留号码的内部发号器
+func (fd *FormattedDispenser) SetReservationLog(fn func(state ReservationState) error) {
+	if rd, ok := As[ReservableDispenser](fd.NumberDispenser); ok {
+		rd.SetReservationLog(fn)
+	}
+}
@@ -0,0 +1,136 @@
+package dispenser
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormattedDispenser(t *testing.T) {
+	factory := NewDispenserFactory(nil)
+	factory.SetNodeID("n1")
+
+	cfg := Config{
+		Type:     TypeNumericIncremental,
+		IncrMode: IncrModeFixed,
+		Length:   6,
+		Starting: 42,
+		Format:   "ORD-{date}-{seq:8}-{name}@{node}",
+		Timezone: "UTC",
+		AutoDisk: StrategyMemory,
+	}
+
+	d, err := factory.CreateDispenser("orders", cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	today := time.Now().UTC().Format("20060102")
+	num, err := d.Next()
+	if err != nil {
+		t.Fatalf("Failed to generate number: %v", err)
+	}
+	if expected := "ORD-" + today + "-00000042-orders@n1"; num != expected {
+		t.Errorf("Expected %s, got %s", expected, num)
+	}
+
+	nums, err := d.NextN(2)
+	if err != nil {
+		t.Fatalf("Failed to generate batch: %v", err)
+	}
+	if !strings.HasSuffix(nums[1], "-00000044-orders@n1") {
+		t.Errorf("Unexpected batch output: %v", nums)
+	}
+
+	// 其余方法委托给内部发号器
+	if d.GetCurrent() != 45 {
+		t.Errorf("Expected current=45, got %d", d.GetCurrent())
+	}
+}
+
+func TestFormattedDispenser_SegmentStrategy(t *testing.T) {
+	factory := NewDispenserFactory(nil)
+
+	cfg := Config{
+		Type:     TypeNumericIncremental,
+		IncrMode: IncrModeSequence,
+		Starting: 1,
+		Format:   "U{{{id}}}",
+		AutoDisk: StrategyPreClose,
+	}
+
+	d, err := factory.CreateDispenser("users", cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+	defer d.Shutdown()
+
+	if num, _ := d.Next(); num != "U{1}" {
+		t.Errorf("Expected U{1}, got %s", num)
+	}
+}
+
+func TestFormattedDispenser_ExtractValue(t *testing.T) {
+	factory := NewDispenserFactory(nil)
+	factory.SetNodeID("node-7")
+
+	cfg := Config{
+		Type:      TypeSnowflake,
+		MachineID: 3,
+		Format:    "{node}-{time:HHmm}-{id:24}",
+		AutoDisk:  StrategyMemory,
+	}
+
+	d, err := factory.CreateDispenser("sf", cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	num, err := d.Next()
+	if err != nil {
+		t.Fatalf("Failed to generate number: %v", err)
+	}
+
+	fd := d.(*FormattedDispenser)
+	value, err := fd.ExtractValue(num)
+	if err != nil {
+		t.Fatalf("Failed to extract value from %s: %v", num, err)
+	}
+
+	decoded, err := Decode(d.GetConfig(), value)
+	if err != nil {
+		t.Fatalf("Failed to decode %s: %v", value, err)
+	}
+	if decoded.MachineID != 3 {
+		t.Errorf("Expected machine_id=3, got %d", decoded.MachineID)
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"padding fits fixed length", Config{Type: TypeNumericIncremental, IncrMode: IncrModeFixed, Length: 8, Format: "ORD-{seq:8}"}, false},
+		{"padding shorter than fixed length", Config{Type: TypeNumericIncremental, IncrMode: IncrModeFixed, Length: 10, Format: "ORD-{seq:8}"}, true},
+		{"padding on unbounded sequence", Config{Type: TypeNumericIncremental, IncrMode: IncrModeSequence, Format: "{seq:8}"}, true},
+		{"padding on random", Config{Type: TypeNumericRandom, Length: 6, Format: "U{rand:6}"}, false},
+		{"padding on uuid", Config{Type: TypeUUID, Format: "{id:32}"}, true},
+		{"no padding", Config{Type: TypeULID, Format: "evt_{id}"}, false},
+		{"missing value", Config{Type: TypeULID, Format: "{date}"}, true},
+		{"two values", Config{Type: TypeULID, Format: "{id}{id}"}, true},
+		{"unknown placeholder", Config{Type: TypeULID, Format: "{foo}{id}"}, true},
+		{"unclosed brace", Config{Type: TypeULID, Format: "{id"}, true},
+		{"stray brace", Config{Type: TypeULID, Format: "{id}}"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateConfig(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
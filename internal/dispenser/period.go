@@ -0,0 +1,179 @@
+package dispenser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ResetPeriod represents how often a Type 2 counter restarts from Starting
+type ResetPeriod string
+
+const (
+	ResetDaily   ResetPeriod = "daily"   // 每天重置
+	ResetWeekly  ResetPeriod = "weekly"  // 每周一重置（ISO周）
+	ResetMonthly ResetPeriod = "monthly" // 每月1日重置
+	ResetYearly  ResetPeriod = "yearly"  // 每年1月1日重置
+)
+
+// periodKeyLayout 周期标识格式（周期起始日期），按字符串比较即可判断先后
+const periodKeyLayout = "2006-01-02"
+
+// defaultDateFormats 各周期默认的日期前缀格式
+var defaultDateFormats = map[ResetPeriod]string{
+	ResetDaily:   "YYYYMMDD",
+	ResetWeekly:  "YYYYWW",
+	ResetMonthly: "YYYYMM",
+	ResetYearly:  "YYYY",
+}
+
+// dateFormatTokens 日期时间占位符，YYYY 需排在 YY 之前（大小写敏感，MM 为月、mm 为分）
+var dateFormatTokens = []string{"YYYY", "YY", "MM", "DD", "WW", "HH", "mm", "ss"}
+
+// loadLocation 解析时区，空字符串表示服务器本地时区
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// periodStart 返回 t 所在周期的起始时间
+func periodStart(t time.Time, period ResetPeriod) time.Time {
+	year, month, day := t.Date()
+	switch period {
+	case ResetWeekly:
+		// ISO周从周一开始
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, t.Location())
+	case ResetMonthly:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	case ResetYearly:
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// dateFormat 返回生效的日期前缀格式
+func dateFormat(cfg Config) string {
+	if cfg.DateFormat != "" {
+		return cfg.DateFormat
+	}
+	return defaultDateFormats[cfg.ResetPeriod]
+}
+
+// formatDate 按 YYYY/YY/MM/DD/WW/HH/mm/ss 占位符格式化日期，其余字符原样保留
+// 每个占位符输出固定宽度，因此相同格式的输出长度固定
+// 格式中包含 WW 时年份使用ISO周所属的年份
+func formatDate(t time.Time, format string) string {
+	year := t.Year()
+	isoYear, week := t.ISOWeek()
+	if strings.Contains(format, "WW") {
+		year = isoYear
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(format); {
+		token := ""
+		for _, tok := range dateFormatTokens {
+			if strings.HasPrefix(format[i:], tok) {
+				token = tok
+				break
+			}
+		}
+
+		switch token {
+		case "YYYY":
+			b.WriteString(fmt.Sprintf("%04d", year))
+		case "YY":
+			b.WriteString(fmt.Sprintf("%02d", year%100))
+		case "MM":
+			b.WriteString(fmt.Sprintf("%02d", int(t.Month())))
+		case "DD":
+			b.WriteString(fmt.Sprintf("%02d", t.Day()))
+		case "WW":
+			b.WriteString(fmt.Sprintf("%02d", week))
+		case "HH":
+			b.WriteString(fmt.Sprintf("%02d", t.Hour()))
+		case "mm":
+			b.WriteString(fmt.Sprintf("%02d", t.Minute()))
+		case "ss":
+			b.WriteString(fmt.Sprintf("%02d", t.Second()))
+		default:
+			b.WriteByte(format[i])
+			i++
+			continue
+		}
+		i += len(token)
+	}
+	return b.String()
+}
+
+// validDateFormat 检查日期前缀能否区分不同周期，否则重置后会产生重复号码
+func validDateFormat(format string, period ResetPeriod) bool {
+	hasYear := strings.Contains(format, "YY")
+	hasMonth := strings.Contains(format, "MM")
+	hasDay := strings.Contains(format, "DD")
+	hasWeek := strings.Contains(format, "WW")
+
+	switch period {
+	case ResetDaily:
+		return hasYear && ((hasMonth && hasDay) || (hasWeek && hasDay))
+	case ResetWeekly:
+		return hasYear && (hasWeek || (hasMonth && hasDay))
+	case ResetMonthly:
+		return hasYear && hasMonth
+	case ResetYearly:
+		return hasYear
+	}
+	return false
+}
+
+// ============================================
+// Dispenser 周期状态
+// ============================================
+
+// rollPeriod 进入新周期时将计数器重置为 Starting（调用方需持有锁）
+// 时钟回拨到上一个周期时不重置，继续使用已记录的周期，避免号码重复
+// 返回用于日期前缀的时间
+func (d *Dispenser) rollPeriod() time.Time {
+	now := time.Now().In(d.loc)
+	start := periodStart(now, d.config.ResetPeriod)
+
+	if d.period == "" || start.Format(periodKeyLayout) > d.period {
+		d.period = start.Format(periodKeyLayout)
+		d.periodStart = start
+		d.current = d.config.Starting
+		return now
+	}
+
+	if now.Before(d.periodStart) {
+		return d.periodStart
+	}
+	return now
+}
+
+// GetPeriod 返回计数器所属周期的标识（用于持久化）
+func (d *Dispenser) GetPeriod() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.period
+}
+
+// SetPeriod 恢复周期标识（用于重启恢复）
+// 应在 SetCurrent 之前调用；无法解析的标识会被忽略，下次发号时进入当前周期
+func (d *Dispenser) SetPeriod(period string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.config.ResetPeriod == "" {
+		return
+	}
+	start, err := time.ParseInLocation(periodKeyLayout, period, d.loc)
+	if err != nil {
+		return
+	}
+	d.period = period
+	d.periodStart = start
+}
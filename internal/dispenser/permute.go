@@ -0,0 +1,116 @@
+package dispenser
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidPermKey = errors.New("invalid perm_key")
+
+// errPermutedSegment permuted 模式不支持 ALLOCSEG
+var errPermutedSegment = errors.New("segment allocation not supported with permuted mode")
+
+// permuted 模式：内部计数器经过带密钥的 Feistel 置换后输出
+//
+// 置换是 [0, 10^length) 上的双射，因此每个计数器对应唯一的号码，
+// 不需要去重缓存，直到整个号码空间用完；相邻计数器的输出看起来是随机的，
+// 无法通过相邻号码的差值推算业务量。持有密钥可以把号码还原为计数器（DECODE）
+
+// feistelRounds Feistel 轮数（与 FF1 相同）
+const feistelRounds = 10
+
+// 密钥长度（字节）
+const (
+	minPermKeyBytes = 16
+	maxPermKeyBytes = 64
+)
+
+// GeneratePermKey 生成随机的置换密钥（十六进制，32字节）
+func GeneratePermKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// validPermKey 检查密钥为 16~64 字节的十六进制字符串
+func validPermKey(key string) bool {
+	b, err := hex.DecodeString(key)
+	return err == nil && len(b) >= minPermKeyBytes && len(b) <= maxPermKeyBytes
+}
+
+// feistel 十进制定长空间上的 Feistel 网络（FF1 结构，轮函数为 HMAC-SHA256）
+// 左半部分 u 位、右半部分 v 位，两半轮流作为被加数，每一轮都保持在十进制空间内，无需循环遍历
+type feistel struct {
+	key    []byte
+	length int
+	u, v   int
+}
+
+// newFeistel 创建置换（配置已通过 validateConfig 校验）
+func newFeistel(cfg Config) *feistel {
+	key, _ := hex.DecodeString(cfg.PermKey)
+	u := cfg.Length / 2
+	return &feistel{key: key, length: cfg.Length, u: u, v: cfg.Length - u}
+}
+
+// round 轮函数：HMAC(key, 轮次 || 长度 || 输入) 对 10^m 取模
+func (f *feistel) round(i int, x int64, m int) int64 {
+	var msg [10]byte
+	msg[0] = byte(i)
+	msg[1] = byte(f.length)
+	binary.BigEndian.PutUint64(msg[2:], uint64(x))
+
+	mac := hmac.New(sha256.New, f.key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	return int64(binary.BigEndian.Uint64(sum[:8]) % uint64(pow10(m)))
+}
+
+// encrypt 将计数器映射为号码
+func (f *feistel) encrypt(x int64) int64 {
+	a, b := x/pow10(f.v), x%pow10(f.v)
+	for i := 0; i < feistelRounds; i++ {
+		m := f.u
+		if i%2 == 1 {
+			m = f.v
+		}
+		c := (a + f.round(i, b, m)) % pow10(m)
+		a, b = b, c
+	}
+	return a*pow10(f.v) + b
+}
+
+// decrypt 将号码还原为计数器
+func (f *feistel) decrypt(y int64) int64 {
+	// 轮数为偶数，结束时两半的位数与开始时相同
+	a, b := y/pow10(f.v), y%pow10(f.v)
+	for i := feistelRounds - 1; i >= 0; i-- {
+		m := f.u
+		if i%2 == 1 {
+			m = f.v
+		}
+		c := b
+		b = a
+		a = ((c-f.round(i, b, m))%pow10(m) + pow10(m)) % pow10(m)
+	}
+	return a*pow10(f.v) + b
+}
+
+// formatCounter 按自增模式格式化计数器（三种实现共用）
+func formatCounter(cfg Config, num int64) string {
+	switch cfg.IncrMode {
+	case IncrModeFixed:
+		return fmt.Sprintf("%0*d", cfg.Length, num)
+	case IncrModePermuted:
+		return fmt.Sprintf("%0*d", cfg.Length, newFeistel(cfg).encrypt(num))
+	default:
+		return fmt.Sprintf("%d", num)
+	}
+}
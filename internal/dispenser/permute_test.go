@@ -0,0 +1,184 @@
+package dispenser
+
+import (
+	"strconv"
+	"testing"
+)
+
+const testPermKey = "000102030405060708090a0b0c0d0e0f"
+
+func TestFeistel_Bijection(t *testing.T) {
+	for length := 1; length <= 4; length++ {
+		f := newFeistel(Config{Length: length, PermKey: testPermKey})
+		space := pow10(length)
+
+		seen := make(map[int64]bool, space)
+		for x := int64(0); x < space; x++ {
+			y := f.encrypt(x)
+			if y < 0 || y >= space {
+				t.Fatalf("length %d: encrypt(%d)=%d out of range", length, x, y)
+			}
+			if seen[y] {
+				t.Fatalf("length %d: encrypt(%d)=%d collides", length, x, y)
+			}
+			seen[y] = true
+
+			if back := f.decrypt(y); back != x {
+				t.Fatalf("length %d: decrypt(encrypt(%d))=%d", length, x, back)
+			}
+		}
+	}
+
+	// 最大长度抽样验证可逆
+	f := newFeistel(Config{Length: 18, PermKey: testPermKey})
+	for _, x := range []int64{0, 1, 2, 123456789, pow10(18) - 1} {
+		if back := f.decrypt(f.encrypt(x)); back != x {
+			t.Errorf("length 18: decrypt(encrypt(%d))=%d", x, back)
+		}
+	}
+}
+
+func TestType2_Permuted(t *testing.T) {
+	cfg := Config{
+		Type:     TypeNumericIncremental,
+		IncrMode: IncrModePermuted,
+		Length:   8,
+		Starting: 1,
+		PermKey:  testPermKey,
+	}
+	d, err := NewDispenser(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	nums, err := d.NextN(1000)
+	if err != nil {
+		t.Fatalf("Failed to generate batch: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	sequential := 0
+	for i, num := range nums {
+		if len(num) != 8 {
+			t.Fatalf("Expected 8 digits, got %s", num)
+		}
+		if seen[num] {
+			t.Fatalf("Duplicate number %s", num)
+		}
+		seen[num] = true
+
+		if i > 0 {
+			prev, _ := strconv.ParseInt(nums[i-1], 10, 64)
+			cur, _ := strconv.ParseInt(num, 10, 64)
+			if cur == prev+1 {
+				sequential++
+			}
+		}
+
+		decoded, err := Decode(d.GetConfig(), num)
+		if err != nil || decoded.Counter != int64(i)+1 {
+			t.Fatalf("Decode(%s) = %+v, %v; want counter %d", num, decoded, err, i+1)
+		}
+		if err := Validate(d.GetConfig(), num); err != nil {
+			t.Errorf("Validate(%s) = %v", num, err)
+		}
+	}
+	if sequential > 5 {
+		t.Errorf("Output looks sequential: %d consecutive pairs", sequential)
+	}
+
+	// 不同密钥得到不同序列
+	other := cfg
+	other.PermKey = "ff0102030405060708090a0b0c0d0e0f"
+	d2, _ := NewDispenser(other)
+	if num, _ := d2.Next(); num == nums[0] {
+		t.Errorf("Different keys produced the same first number %s", num)
+	}
+}
+
+func TestType2_PermutedExhaustion(t *testing.T) {
+	d, err := NewDispenser(Config{
+		Type:     TypeNumericIncremental,
+		IncrMode: IncrModePermuted,
+		Length:   2,
+		PermKey:  testPermKey,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	// 整个号码空间都能发出，且不重复
+	nums, err := d.NextN(100)
+	if err != nil {
+		t.Fatalf("Expected 100 numbers, got error: %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, num := range nums {
+		seen[num] = true
+	}
+	if len(seen) != 100 {
+		t.Errorf("Expected 100 unique numbers, got %d", len(seen))
+	}
+
+	if _, err := d.Next(); err != ErrNumberExhausted {
+		t.Errorf("Expected ErrNumberExhausted, got %v", err)
+	}
+}
+
+func TestType2_PermutedSegmentStrategy(t *testing.T) {
+	cfg := Config{
+		Type:     TypeNumericIncremental,
+		IncrMode: IncrModePermuted,
+		Length:   10,
+		PermKey:  testPermKey,
+	}
+
+	mem, err := NewDispenser(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	cfg.AutoDisk = StrategyPreBase
+	seg, err := NewDispenserFactory(nil).CreateDispenser("perm", cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+	defer seg.Shutdown()
+
+	// 相同密钥和计数器，号段实现与内存实现输出一致
+	for i := 0; i < 5; i++ {
+		a, _ := mem.Next()
+		b, _ := seg.Next()
+		if a != b {
+			t.Errorf("Expected %s, got %s", a, b)
+		}
+	}
+
+	if _, _, err := seg.AllocateSegment(10); err == nil {
+		t.Error("Expected ALLOCSEG to be rejected in permuted mode")
+	}
+}
+
+func TestType2_PermutedValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"missing key", Config{Type: TypeNumericIncremental, IncrMode: IncrModePermuted, Length: 6}},
+		{"short key", Config{Type: TypeNumericIncremental, IncrMode: IncrModePermuted, Length: 6, PermKey: "0011"}},
+		{"non-hex key", Config{Type: TypeNumericIncremental, IncrMode: IncrModePermuted, Length: 6, PermKey: "zz0102030405060708090a0b0c0d0e0f"}},
+		{"key without permuted", Config{Type: TypeNumericIncremental, IncrMode: IncrModeFixed, Length: 6, PermKey: testPermKey}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateConfig(tt.cfg); err != ErrInvalidPermKey {
+				t.Errorf("validateConfig() = %v, want ErrInvalidPermKey", err)
+			}
+		})
+	}
+
+	if err := validateConfig(Config{Type: TypeNumericIncremental, IncrMode: IncrModePermuted, PermKey: testPermKey}); err != ErrInvalidLength {
+		t.Errorf("Expected ErrInvalidLength without length, got %v", err)
+	}
+}
@@ -0,0 +1,315 @@
+package dispenser
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidRecycle  = errors.New("invalid recycle config")
+	ErrRecycleDisabled = errors.New("recycling is not enabled")
+	ErrNotIssued       = errors.New("value has not been issued")
+	ErrAlreadyReleased = errors.New("value already released")
+)
+
+// PoolEntry 回收池中的号码
+type PoolEntry struct {
+	Value    string    `json:"value"`    // 发号器生成的原始值（不含格式模板）
+	Released time.Time `json:"released"` // 释放时间，用于计算冷却期
+}
+
+// issuedChecker 能判断号码是否已经发出的发号器（Type 1、2 的各实现）
+type issuedChecker interface {
+	// issued 判断 numericCounter 解析出的数值是否已经发出
+	issued(num int64) bool
+}
+
+// ============================================
+// 号码回收
+// ============================================
+
+// RecyclingDispenser 为 Type 1、2 发号器增加回收池
+// RELEASE 归还的号码按释放顺序排队，过了冷却期后优先于新号码发出
+type RecyclingDispenser struct {
+	NumberDispenser
+	mu       sync.Mutex
+	pool     []PoolEntry // 按释放顺序排列
+	pooled   map[string]bool
+	cooldown time.Duration
+	poolLog  func(entries []PoolEntry) error
+	errs     errorCounter // 回收池持久化失败（内部发号器的失败由其自身统计）
+}
+
+// newRecyclingDispenser 为发号器套上回收池（配置已通过 validateConfig 校验）
+func newRecyclingDispenser(inner NumberDispenser) *RecyclingDispenser {
+	return &RecyclingDispenser{
+		NumberDispenser: inner,
+		pooled:          make(map[string]bool),
+		cooldown:        inner.GetConfig().RecycleCooldown,
+	}
+}
+
+// Next 优先发出回收池中已过冷却期的号码，否则生成新号码
+func (rd *RecyclingDispenser) Next() (string, error) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	taken := rd.takeLocked(1, time.Now())
+	if len(taken) == 0 {
+		return rd.NumberDispenser.Next()
+	}
+	if err := rd.persistLocked(); err != nil {
+		rd.restoreLocked(taken)
+		return "", rd.errs.record(err)
+	}
+	return taken[0].Value, nil
+}
+
+// NextN 批量取号，先取回收池中的号码，不足部分生成新号码
+func (rd *RecyclingDispenser) NextN(n int) ([]string, error) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	taken := rd.takeLocked(n, time.Now())
+
+	var fresh []string
+	if rest := n - len(taken); rest > 0 {
+		var err error
+		fresh, err = rd.NumberDispenser.NextN(rest)
+		if err != nil {
+			rd.restoreLocked(taken)
+			return nil, err
+		}
+	}
+
+	if len(taken) > 0 {
+		if err := rd.persistLocked(); err != nil {
+			rd.restoreLocked(taken)
+			return nil, rd.errs.record(err)
+		}
+	}
+
+	values := make([]string, 0, n)
+	for _, entry := range taken {
+		values = append(values, entry.Value)
+	}
+	return append(values, fresh...), nil
+}
+
+// takeLocked 从队首取出最多 n 个已过冷却期的号码（调用方需持有锁）
+// 冷却期相同，已过冷却期的号码总是位于队首
+func (rd *RecyclingDispenser) takeLocked(n int, now time.Time) []PoolEntry {
+	k := 0
+	for k < len(rd.pool) && k < n && !rd.pool[k].Released.Add(rd.cooldown).After(now) {
+		k++
+	}
+	if k == 0 {
+		return nil
+	}
+
+	taken := make([]PoolEntry, k)
+	copy(taken, rd.pool[:k])
+	rd.pool = rd.pool[k:]
+	for _, entry := range taken {
+		delete(rd.pooled, entry.Value)
+	}
+	return taken
+}
+
+// restoreLocked 将取出的号码放回队首（调用方需持有锁）
+func (rd *RecyclingDispenser) restoreLocked(taken []PoolEntry) {
+	rd.pool = append(append([]PoolEntry(nil), taken...), rd.pool...)
+	for _, entry := range taken {
+		rd.pooled[entry.Value] = true
+	}
+}
+
+// persistLocked 保存回收池（调用方需持有锁）
+func (rd *RecyclingDispenser) persistLocked() error {
+	if rd.poolLog == nil {
+		return nil
+	}
+	if err := rd.poolLog(rd.pool); err != nil {
+		return &persistError{fmt.Errorf("failed to persist recycle pool: %w", err)}
+	}
+	return nil
+}
+
+// Release 将已发出的号码归还到回收池
+// 任一号码不属于该发号器、尚未发出或已在回收池中时整批拒绝
+func (rd *RecyclingDispenser) Release(values []string) error {
+	cfg := rd.GetConfig()
+	checker, _ := rd.NumberDispenser.(issuedChecker)
+
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		if err := Validate(cfg, value); err != nil {
+			return fmt.Errorf("%w: %s", err, value)
+		}
+		num, err := numericCounter(cfg, value)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, value)
+		}
+		// 只接受与发号器输出完全一致的写法（如顺序模式不带前导零）
+		if value != numericValue(cfg, num) {
+			return fmt.Errorf("%w: %s", ErrInvalidID, value)
+		}
+		if checker == nil || !checker.issued(num) {
+			return fmt.Errorf("%w: %s", ErrNotIssued, value)
+		}
+		if rd.pooled[value] || seen[value] {
+			return fmt.Errorf("%w: %s", ErrAlreadyReleased, value)
+		}
+		seen[value] = true
+	}
+
+	now := time.Now()
+	before := len(rd.pool)
+	for _, value := range values {
+		rd.pool = append(rd.pool, PoolEntry{Value: value, Released: now})
+		rd.pooled[value] = true
+	}
+
+	if err := rd.persistLocked(); err != nil {
+		for _, entry := range rd.pool[before:] {
+			delete(rd.pooled, entry.Value)
+		}
+		rd.pool = rd.pool[:before]
+		return err
+	}
+	return nil
+}
+
+// RestorePool 按释放顺序恢复回收池（用于重启恢复）
+func (rd *RecyclingDispenser) RestorePool(entries []PoolEntry) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	rd.pool = rd.pool[:0]
+	rd.pooled = make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if rd.pooled[entry.Value] {
+			continue
+		}
+		rd.pool = append(rd.pool, entry)
+		rd.pooled[entry.Value] = true
+	}
+}
+
+// SetPoolLog 设置回收池的持久化回调
+// 回收池每次变化后以完整内容调用，回调不得保留传入的切片
+func (rd *RecyclingDispenser) SetPoolLog(fn func(entries []PoolEntry) error) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	rd.poolLog = fn
+}
+
+// GetStats 在内部发号器的统计上附加回收池信息
+func (rd *RecyclingDispenser) GetStats() DispenserStats {
+	stats := rd.NumberDispenser.GetStats()
+
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	now := time.Now()
+	stats.PoolSize = int64(len(rd.pool))
+	for _, entry := range rd.pool {
+		if entry.Released.Add(rd.cooldown).After(now) {
+			break
+		}
+		stats.PoolReady++
+	}
+	rd.errs.fillStats(&stats)
+	return stats
+}
+
+// Unwrap 返回被包装的发号器，其余可选接口通过 As 查找
+func (rd *RecyclingDispenser) Unwrap() NumberDispenser {
+	return rd.NumberDispenser
+}
+
+// Capacity 在内部发号器的剩余数上加上回收池中等待再次发出的号码
+func (rd *RecyclingDispenser) Capacity() (Capacity, bool) {
+	inner, ok := As[BoundedDispenser](rd.NumberDispenser)
+	if !ok {
+		return Capacity{}, false
+	}
+	c, ok := inner.Capacity()
+	if !ok {
+		return c, false
+	}
+
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	c.Remaining = min(c.Remaining+int64(len(rd.pool)), c.Total)
+	return c, true
+}
+
+// numericValue 按发号器的输出格式还原 Type 1、2 的号码
+func numericValue(cfg Config, num int64) string {
+	if cfg.Type == TypeNumericRandom {
+		return appendCheckDigit(cfg.CheckDigit, fmt.Sprintf("%0*d", cfg.Length, num))
+	}
+	return appendCheckDigit(cfg.CheckDigit, formatCounter(cfg, num))
+}
+
+// ============================================
+// 已发号码判断
+// ============================================
+
+// issued 判断号码是否已发出：Type 1 查去重集合，Type 2 判断计数器是否已经过该位置
+// 保留中的号码被计数器跳过，不算已发出
+func (d *Dispenser) issued(num int64) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.issuedLocked(num) && !d.reservations.holds(d.config, num)
+}
+
+// issuedLocked 同 issued，但不排除保留中的号码（调用方需持有锁；保留号码时已持有 rmu）
+func (d *Dispenser) issuedLocked(num int64) bool {
+	if d.config.Type == TypeNumericRandom {
+		return d.used != nil && d.used.contains(num)
+	}
+	return between(d.config, num, d.config.Starting, d.current)
+}
+
+// issued 判断计数器是否已发出：已分配的范围内，除去当前号段和预加载号段中尚未使用的部分，以及保留中的号码
+func (sd *SegmentDispenser) issued(num int64) bool {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	sd.nextSegmentMu.Lock()
+	defer sd.nextSegmentMu.Unlock()
+	return sd.issuedLocked(num) && !sd.reservations.holds(sd.config, num)
+}
+
+// issuedLocked 同 issued，但不排除保留中的号码（调用方需持有 mu 和 nextSegmentMu）
+func (sd *SegmentDispenser) issuedLocked(num int64) bool {
+	cfg := sd.config
+	if !between(cfg, num, cfg.Starting, sd.allocEnd) || between(cfg, num, sd.currentNumber, sd.segmentEnd) {
+		return false
+	}
+	return !sd.nextSegmentReady || !between(cfg, num, sd.nextSegmentStart, sd.nextSegmentEnd)
+}
+
+// issued 判断计数器是否已发出：已分配的范围内，除去当前号段和预加载号段中尚未使用的部分，以及保留中的号码
+func (osd *OptimizedSegmentDispenser) issued(num int64) bool {
+	osd.mu.Lock()
+	defer osd.mu.Unlock()
+	osd.nextSegmentMu.Lock()
+	defer osd.nextSegmentMu.Unlock()
+	return osd.issuedLocked(num) && !osd.reservations.holds(osd.config, num)
+}
+
+// issuedLocked 同 issued，但不排除保留中的号码（调用方需持有 mu 和 nextSegmentMu）
+func (osd *OptimizedSegmentDispenser) issuedLocked(num int64) bool {
+	cfg := osd.config
+	if !between(cfg, num, cfg.Starting, osd.allocEnd) || between(cfg, num, osd.currentNumber, osd.segmentEnd) {
+		return false
+	}
+	return !osd.nextSegmentReady || !between(cfg, num, osd.nextSegmentStart, osd.nextSegmentEnd)
+}
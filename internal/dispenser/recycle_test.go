@@ -0,0 +1,258 @@
+package dispenser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecyclingDispenser_FIFO(t *testing.T) {
+	factory := NewDispenserFactory(nil)
+	d, err := factory.CreateDispenser("rooms", Config{
+		Type:     TypeNumericIncremental,
+		IncrMode: IncrModeFixed,
+		Length:   4,
+		Starting: 1001,
+		Recycle:  true,
+		AutoDisk: StrategyMemory,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+	rd := d.(*RecyclingDispenser)
+
+	var saved []PoolEntry
+	rd.SetPoolLog(func(entries []PoolEntry) error {
+		saved = append(saved[:0], entries...)
+		return nil
+	})
+
+	nums, _ := d.NextN(5) // 1001~1005
+
+	// 先归还的先发出
+	if err := rd.Release([]string{"1003", "1001"}); err != nil {
+		t.Fatalf("Failed to release: %v", err)
+	}
+	if len(saved) != 2 {
+		t.Errorf("Expected pool persisted with 2 entries, got %d", len(saved))
+	}
+
+	nums, err = d.NextN(3)
+	if err != nil {
+		t.Fatalf("Failed to generate batch: %v", err)
+	}
+	if strings.Join(nums, ",") != "1003,1001,1006" {
+		t.Errorf("Expected recycled numbers first, got %v", nums)
+	}
+	if len(saved) != 0 {
+		t.Errorf("Expected empty pool persisted, got %v", saved)
+	}
+
+	// 校验失败时整批拒绝
+	tests := []struct {
+		values []string
+		want   error
+	}{
+		{[]string{"1002", "1007"}, ErrNotIssued},       // 尚未发出
+		{[]string{"1002", "1002"}, ErrAlreadyReleased}, // 同一批重复
+		{[]string{"abcd"}, ErrInvalidID},               // 不属于该发号器
+		{[]string{"0999"}, ErrOutOfRange},              // 小于起始值
+	}
+	for _, tt := range tests {
+		if err := rd.Release(tt.values); !errors.Is(err, tt.want) {
+			t.Errorf("Release(%v) error = %v, want %v", tt.values, err, tt.want)
+		}
+	}
+	if stats := d.GetStats(); stats.PoolSize != 0 {
+		t.Errorf("Expected empty pool after rejected batches, got %d", stats.PoolSize)
+	}
+
+	rd.Release([]string{"1002"})
+	if err := rd.Release([]string{"1002"}); !errors.Is(err, ErrAlreadyReleased) {
+		t.Errorf("Expected ErrAlreadyReleased, got %v", err)
+	}
+
+	// 持久化失败时不取出回收池中的号码
+	rd.SetPoolLog(func(entries []PoolEntry) error { return errors.New("disk full") })
+	if _, err := d.Next(); err == nil {
+		t.Error("Expected error when pool log fails")
+	}
+	if stats := d.GetStats(); stats.PoolSize != 1 || stats.PoolReady != 1 {
+		t.Errorf("Expected 1 pooled number, got %+v", stats)
+	}
+}
+
+func TestRecyclingDispenser_Cooldown(t *testing.T) {
+	factory := NewDispenserFactory(nil)
+	d, err := factory.CreateDispenser("seats", Config{
+		Type:            TypeNumericRandom,
+		Length:          3,
+		Recycle:         true,
+		RecycleCooldown: time.Hour,
+		AutoDisk:        StrategyMemory,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+	rd := d.(*RecyclingDispenser)
+
+	num, _ := d.Next()
+	if err := rd.Release([]string{num}); err != nil {
+		t.Fatalf("Failed to release %s: %v", num, err)
+	}
+
+	// 冷却期内不会再次发出
+	for i := 0; i < 100; i++ {
+		if next, _ := d.Next(); next == num {
+			t.Fatalf("Number %s reissued during cooldown", num)
+		}
+	}
+	if stats := d.GetStats(); stats.PoolSize != 1 || stats.PoolReady != 0 {
+		t.Errorf("Expected 1 pooled and 0 ready, got %+v", stats)
+	}
+
+	// 恢复的回收池中已过冷却期的号码优先发出
+	rd.RestorePool([]PoolEntry{{Value: num, Released: time.Now().Add(-2 * time.Hour)}})
+	if next, _ := d.Next(); next != num {
+		t.Errorf("Expected %s after cooldown, got %s", num, next)
+	}
+}
+
+func TestRecyclingDispenser_SegmentAndFormat(t *testing.T) {
+	factory := NewDispenserFactory(nil)
+	d, err := factory.CreateDispenser("tickets", Config{
+		Type:     TypeNumericIncremental,
+		IncrMode: IncrModeSequence,
+		Starting: 1,
+		Format:   "T-{seq}",
+		Recycle:  true,
+		AutoDisk: StrategyPreBase,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+	defer d.Shutdown()
+
+	d.NextN(3) // T-1 ~ T-3
+	rd := d.(RecyclableDispenser)
+
+	// 号段内尚未发出的号码不能归还
+	if err := rd.Release([]string{"T-4"}); !errors.Is(err, ErrNotIssued) {
+		t.Errorf("Expected ErrNotIssued, got %v", err)
+	}
+	if err := rd.Release([]string{"X-2"}); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID, got %v", err)
+	}
+	if err := rd.Release([]string{"T-2"}); err != nil {
+		t.Fatalf("Failed to release: %v", err)
+	}
+	if num, _ := d.Next(); num != "T-2" {
+		t.Errorf("Expected T-2, got %s", num)
+	}
+}
+
+// 测试计数器跳过的保留号码不能归还，否则下次发号会绕过 CLAIM 发出保留号码
+func TestRecyclingDispenser_Reserved(t *testing.T) {
+	factory := NewDispenserFactory(nil)
+	for _, strategy := range []PersistenceStrategy{StrategyMemory, StrategyPreCheckpoint} {
+		d, err := factory.CreateDispenser("vip", Config{
+			Type:     TypeNumericIncremental,
+			IncrMode: IncrModeFixed,
+			Length:   4,
+			Starting: 8886,
+			Recycle:  true,
+			AutoDisk: strategy,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create dispenser: %v", err)
+		}
+		rd := d.(*RecyclingDispenser)
+		res, _ := As[ReservableDispenser](d)
+
+		if _, err := res.Reserve([]ReservedRange{{Start: 8888, End: 8888}}); err != nil {
+			t.Fatalf("Failed to reserve: %v", err)
+		}
+		nums, _ := d.NextN(3)
+		if strings.Join(nums, ",") != "8886,8887,8889" {
+			t.Fatalf("%s: expected reserved value skipped, got %v", strategy, nums)
+		}
+
+		if err := rd.Release([]string{"8888"}); !errors.Is(err, ErrNotIssued) {
+			t.Errorf("%s: expected ErrNotIssued for reserved value, got %v", strategy, err)
+		}
+		if num, _ := d.Next(); num == "8888" {
+			t.Errorf("%s: reserved value issued without CLAIM", strategy)
+		}
+
+		// 认领后即为已发出，可以归还
+		if _, err := res.Claim(8888); err != nil {
+			t.Fatalf("Failed to claim: %v", err)
+		}
+		if err := rd.Release([]string{"8888"}); err != nil {
+			t.Errorf("%s: failed to release claimed value: %v", strategy, err)
+		}
+		d.Shutdown()
+	}
+}
+
+// 测试 As 沿格式模板、回收池装饰器链找到实现可选接口的发号器
+func TestAs_WrapperChain(t *testing.T) {
+	factory := NewDispenserFactory(nil)
+	d, err := factory.CreateDispenser("seats", Config{
+		Type:     TypeNumericRandom,
+		Length:   2,
+		Format:   "S{seq}",
+		Recycle:  true,
+		AutoDisk: StrategyMemory,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	// 格式模板改写 Release，回收池改写 Capacity，去重集合由最内层的发号器实现
+	if rd, ok := As[RecyclableDispenser](d); !ok || rd != d.(RecyclableDispenser) {
+		t.Errorf("Expected the formatted dispenser for RecyclableDispenser, got %T", rd)
+	}
+	if bd, ok := As[BoundedDispenser](d); !ok {
+		t.Error("Expected BoundedDispenser")
+	} else if _, isRecycling := bd.(*RecyclingDispenser); !isRecycling {
+		t.Errorf("Expected the recycling dispenser for BoundedDispenser, got %T", bd)
+	}
+	if ud, ok := As[UniqueDispenser](d); !ok {
+		t.Error("Expected UniqueDispenser")
+	} else if _, isBase := ud.(*Dispenser); !isBase {
+		t.Errorf("Expected the inner dispenser for UniqueDispenser, got %T", ud)
+	}
+	if _, ok := As[PeriodicDispenser](d); !ok {
+		t.Error("Expected PeriodicDispenser from the inner dispenser")
+	}
+	if _, ok := As[*SegmentDispenser](d); ok {
+		t.Error("Unexpected SegmentDispenser")
+	}
+}
+
+func TestValidateRecycle(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"type 1", Config{Type: TypeNumericRandom, Length: 4, Recycle: true}, false},
+		{"type 2 cooldown", Config{Type: TypeNumericIncremental, Recycle: true, RecycleCooldown: time.Minute}, false},
+		{"type 3", Config{Type: TypeAlphanumericRandom, Length: 8, Recycle: true}, true},
+		{"cooldown without recycle", Config{Type: TypeNumericIncremental, RecycleCooldown: time.Minute}, true},
+		{"negative cooldown", Config{Type: TypeNumericIncremental, Recycle: true, RecycleCooldown: -time.Second}, true},
+		{"reset period", Config{Type: TypeNumericIncremental, Recycle: true, ResetPeriod: ResetDaily}, true},
+		{"unique window", Config{Type: TypeNumericRandom, Length: 4, Recycle: true, UniqueCacheSize: 10}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateConfig(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,547 @@
+package dispenser
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrNotReservable    = errors.New("reservations are only supported by types 1 and 2 without reset_period")
+	ErrAlreadyIssued    = errors.New("value already issued")
+	ErrNotReserved      = errors.New("value is not reserved")
+	ErrTooManyReserved  = errors.New("too many values to reserve at once")
+	errReservedTooLarge = fmt.Errorf("%w: permuted mode checks every value, at most %d per request", ErrTooManyReserved, maxPermutedReserve)
+)
+
+// maxPermutedReserve permuted 模式单次最多保留的号码数
+// 置换后的号码与计数器没有顺序关系，只能逐个检查是否已发出
+const maxPermutedReserve = 1000000
+
+// ReservedRange 保留的号码区间（闭区间），单个号码的 Start 与 End 相同
+// 号码指不含校验码和格式模板的数字本体，permuted 模式为置换后的值
+type ReservedRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// Count 返回区间内的号码数
+func (r ReservedRange) Count() int64 {
+	return r.End - r.Start + 1
+}
+
+// String 返回区间的文本形式：单个号码为 "8888"，区间为 "1000-1999"
+func (r ReservedRange) String() string {
+	if r.Start == r.End {
+		return fmt.Sprintf("%d", r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// ReservationState 保留号码的持久化内容
+type ReservationState struct {
+	Reserved []ReservedRange `json:"reserved,omitempty"` // 保留中、不会被 Next() 发出的号码
+	Claimed  []ReservedRange `json:"claimed,omitempty"`  // 已通过 CLAIM 发出的保留号码（Type 2，计数器经过时继续跳过）
+}
+
+// ============================================
+// 区间集合
+// ============================================
+
+// 区间集合按 Start 升序排列，区间之间互不重叠也不相邻
+
+// findRange 返回包含 v 的区间
+func findRange(set []ReservedRange, v int64) (ReservedRange, bool) {
+	i := sort.Search(len(set), func(i int) bool { return set[i].End >= v })
+	if i < len(set) && set[i].Start <= v {
+		return set[i], true
+	}
+	return ReservedRange{}, false
+}
+
+// addRange 返回加入区间 r 后的新集合（不修改原集合）
+func addRange(set []ReservedRange, r ReservedRange) []ReservedRange {
+	result := make([]ReservedRange, 0, len(set)+1)
+	i := 0
+	for ; i < len(set) && set[i].End < r.Start-1; i++ {
+		result = append(result, set[i])
+	}
+	for ; i < len(set) && set[i].Start <= r.End+1; i++ {
+		r.Start = min(r.Start, set[i].Start)
+		r.End = max(r.End, set[i].End)
+	}
+	result = append(result, r)
+	return append(result, set[i:]...)
+}
+
+// removeRange 返回去掉区间 r 后的新集合，以及实际去掉的号码数（不修改原集合）
+func removeRange(set []ReservedRange, r ReservedRange) ([]ReservedRange, int64) {
+	result := make([]ReservedRange, 0, len(set)+1)
+	var removed int64
+	for _, s := range set {
+		if s.End < r.Start || s.Start > r.End {
+			result = append(result, s)
+			continue
+		}
+		if s.Start < r.Start {
+			result = append(result, ReservedRange{Start: s.Start, End: r.Start - 1})
+		}
+		if s.End > r.End {
+			result = append(result, ReservedRange{Start: r.End + 1, End: s.End})
+		}
+		removed += min(s.End, r.End) - max(s.Start, r.Start) + 1
+	}
+	return result, removed
+}
+
+// countRanges 返回集合中的号码总数
+func countRanges(set []ReservedRange) int64 {
+	var n int64
+	for _, r := range set {
+		n += r.Count()
+	}
+	return n
+}
+
+// overlapCount 返回集合与 [lo, hi] 重叠的号码数
+func overlapCount(set []ReservedRange, lo, hi int64) int64 {
+	var n int64
+	for i := sort.Search(len(set), func(i int) bool { return set[i].End >= lo }); i < len(set) && set[i].Start <= hi; i++ {
+		n += min(set[i].End, hi) - max(set[i].Start, lo) + 1
+	}
+	return n
+}
+
+// ============================================
+// 保留号码
+// ============================================
+
+// reservations 发号器的保留号码，由 Type 1、2 的各实现共用
+// 加锁顺序：先持有发号器的锁，再持有 rmu
+type reservations struct {
+	rmu      sync.RWMutex
+	reserved []ReservedRange
+	claimed  []ReservedRange
+	log      func(state ReservationState) error
+}
+
+func newReservations() *reservations {
+	return &reservations{}
+}
+
+// reservable 检查配置是否支持保留号码
+// 周期重置和回绕后计数器回到起点，无法判断号码是否已发出
+func reservable(cfg Config) bool {
+	return (cfg.Type == TypeNumericRandom || cfg.Type == TypeNumericIncremental) &&
+		cfg.ResetPeriod == "" && cfg.OnExhausted != ExhaustedWrap
+}
+
+// reserveBounds 返回可以保留的号码范围
+func reserveBounds(cfg Config) (int64, int64) {
+	switch {
+	case cfg.Type == TypeNumericRandom:
+		return pow10(cfg.Length - 1), pow10(cfg.Length) - 1
+	case cfg.IncrMode == IncrModePermuted:
+		return 0, pow10(cfg.Length) - 1
+	default:
+		return counterBounds(cfg)
+	}
+}
+
+// counterIn 判断 [lo, hi] 内是否有计数器会经过的值（Starting + k×Step，k >= 0）
+func counterIn(cfg Config, lo, hi int64) bool {
+	if cfg.Step < 0 {
+		hi = min(hi, cfg.Starting)
+		if lo > hi {
+			return false
+		}
+		step := -cfg.Step
+		last := cfg.Starting - (cfg.Starting-hi+step-1)/step*step
+		return last >= lo
+	}
+
+	lo = max(lo, cfg.Starting)
+	if lo > hi {
+		return false
+	}
+	first := cfg.Starting + (lo-cfg.Starting+cfg.Step-1)/cfg.Step*cfg.Step
+	return first <= hi
+}
+
+// pathIn 判断从 from（含）到 to（不含）的发号路径上是否有计数器落在 [lo, hi] 内
+func pathIn(cfg Config, lo, hi, from, to int64) bool {
+	if cfg.Step < 0 {
+		return counterIn(cfg, max(lo, to+1), min(hi, from))
+	}
+	return counterIn(cfg, max(lo, from), min(hi, to-1))
+}
+
+// blocked 检查号码是否被保留或已被认领，返回所在的区间
+func (r *reservations) blocked(v int64) (ReservedRange, bool) {
+	r.rmu.RLock()
+	defer r.rmu.RUnlock()
+
+	if rng, ok := findRange(r.reserved, v); ok {
+		return rng, true
+	}
+	return findRange(r.claimed, v)
+}
+
+// holds 检查计数器 num 生成的号码是否保留中（已认领的号码不算）
+// 计数器跳过保留号码，因此保留中的号码即使落在已经过的范围内也没有发出
+func (r *reservations) holds(cfg Config, num int64) bool {
+	value := num
+	if cfg.Type == TypeNumericIncremental && cfg.IncrMode == IncrModePermuted {
+		value = newFeistel(cfg).encrypt(num)
+	}
+
+	r.rmu.RLock()
+	defer r.rmu.RUnlock()
+	_, ok := findRange(r.reserved, value)
+	return ok
+}
+
+// skip 检查计数器 num 生成的号码是否被保留，被保留时返回下一个候选计数器
+// fixed、sequence 模式号码即计数器，直接跳过整个区间；permuted 模式逐个前进
+func (r *reservations) skip(cfg Config, num int64) (int64, bool) {
+	r.rmu.RLock()
+	empty := len(r.reserved) == 0 && len(r.claimed) == 0
+	r.rmu.RUnlock()
+	if empty {
+		return num, false
+	}
+
+	value := num
+	if cfg.IncrMode == IncrModePermuted {
+		value = newFeistel(cfg).encrypt(num)
+	}
+
+	rng, ok := r.blocked(value)
+	if !ok {
+		return num, false
+	}
+	switch {
+	case cfg.IncrMode == IncrModePermuted:
+		return num + cfg.Step, true
+	case cfg.Step < 0:
+		return num + ((num-rng.Start)/-cfg.Step+1)*cfg.Step, true
+	default:
+		return num + ((rng.End-num)/cfg.Step+1)*cfg.Step, true
+	}
+}
+
+// reservedCount 返回保留中的号码数（Type 1 用于计算剩余空间）
+func (r *reservations) reservedCount() int64 {
+	r.rmu.RLock()
+	defer r.rmu.RUnlock()
+	return countRanges(r.reserved)
+}
+
+// reservedRanges 返回保留区间的快照
+func (r *reservations) reservedRanges() []ReservedRange {
+	r.rmu.RLock()
+	defer r.rmu.RUnlock()
+	return append([]ReservedRange(nil), r.reserved...)
+}
+
+// pendingCount 返回计数器 from 之后仍会被跳过的号码数（保留中和已认领的号码）
+// permuted 模式的号码与计数器没有顺序关系，只统计保留中的号码
+func (r *reservations) pendingCount(cfg Config, from int64) int64 {
+	r.rmu.RLock()
+	defer r.rmu.RUnlock()
+
+	if cfg.IncrMode == IncrModePermuted {
+		return countRanges(r.reserved)
+	}
+
+	// 只统计发号路径上、计数器尚未经过的值
+	lo, hi := counterBounds(cfg)
+	if cfg.Step < 0 {
+		hi = from
+	} else {
+		lo = from
+	}
+	var n int64
+	for _, set := range [][]ReservedRange{r.reserved, r.claimed} {
+		for _, rng := range set {
+			n += gridCount(cfg, max(rng.Start, lo), min(rng.End, hi))
+		}
+	}
+	return n
+}
+
+// gridCount 返回 [lo, hi] 内计数器会经过的值的个数（与 Starting 相差步长的整数倍）
+func gridCount(cfg Config, lo, hi int64) int64 {
+	if lo > hi {
+		return 0
+	}
+	step := cfg.Step
+	if step < 0 {
+		step = -step
+	}
+	first := lo + ((cfg.Starting-lo)%step+step)%step
+	if first > hi {
+		return 0
+	}
+	return (hi-first)/step + 1
+}
+
+// persistLocked 保存新的保留状态，成功后才替换内存中的状态（调用方需持有 rmu）
+func (r *reservations) persistLocked(reserved, claimed []ReservedRange) error {
+	if r.log != nil {
+		if err := r.log(ReservationState{Reserved: reserved, Claimed: claimed}); err != nil {
+			return fmt.Errorf("failed to persist reservations: %w", err)
+		}
+	}
+	r.reserved = reserved
+	r.claimed = claimed
+	return nil
+}
+
+// add 保留号码，返回新增的号码数（调用方需持有发号器的锁）
+// issued 判断计数器是否已发出，rangeIssued 判断 [lo, hi] 内是否有号码已发出（号码即计数器时使用）
+func (r *reservations) add(cfg Config, ranges []ReservedRange, issued func(num int64) bool, rangeIssued func(lo, hi int64) bool) (int64, error) {
+	if !reservable(cfg) {
+		return 0, ErrNotReservable
+	}
+
+	lo, hi := reserveBounds(cfg)
+	var total int64
+	for _, rng := range ranges {
+		if rng.Start > rng.End || rng.Start < lo || rng.End > hi {
+			return 0, fmt.Errorf("%w: %s", ErrOutOfRange, rng)
+		}
+		total += rng.Count()
+	}
+
+	r.rmu.Lock()
+	defer r.rmu.Unlock()
+
+	permuted := cfg.Type == TypeNumericIncremental && cfg.IncrMode == IncrModePermuted
+	if permuted && total > maxPermutedReserve {
+		return 0, errReservedTooLarge
+	}
+
+	// 已发出的号码不能再保留，否则 CLAIM 会重复发号
+	for _, rng := range ranges {
+		if overlapCount(r.claimed, rng.Start, rng.End) > 0 {
+			return 0, fmt.Errorf("%w: %s", ErrAlreadyIssued, rng)
+		}
+		if permuted {
+			f := newFeistel(cfg)
+			for v := rng.Start; v <= rng.End; v++ {
+				if issued(f.decrypt(v)) {
+					return 0, fmt.Errorf("%w: %d", ErrAlreadyIssued, v)
+				}
+			}
+		} else if rangeIssued(rng.Start, rng.End) {
+			return 0, fmt.Errorf("%w: %s", ErrAlreadyIssued, rng)
+		}
+	}
+
+	reserved := r.reserved
+	for _, rng := range ranges {
+		reserved = addRange(reserved, rng)
+	}
+	added := countRanges(reserved) - countRanges(r.reserved)
+	if added == 0 {
+		return 0, nil
+	}
+	if err := r.persistLocked(reserved, r.claimed); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// Unreserve 解除保留，之后 Next() 可以再次发出这些号码
+// 返回实际解除保留的号码数，已认领的号码不受影响
+func (r *reservations) Unreserve(ranges []ReservedRange) (int64, error) {
+	r.rmu.Lock()
+	defer r.rmu.Unlock()
+
+	reserved := r.reserved
+	var removed int64
+	for _, rng := range ranges {
+		var n int64
+		reserved, n = removeRange(reserved, rng)
+		removed += n
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	if err := r.persistLocked(reserved, r.claimed); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// take 将保留号码移出保留集合；keepClaimed 为 true 时记入已认领集合
+func (r *reservations) take(v int64, keepClaimed bool) error {
+	r.rmu.Lock()
+	defer r.rmu.Unlock()
+
+	if _, ok := findRange(r.reserved, v); !ok {
+		return fmt.Errorf("%w: %d", ErrNotReserved, v)
+	}
+	reserved, _ := removeRange(r.reserved, ReservedRange{Start: v, End: v})
+	claimed := r.claimed
+	if keepClaimed {
+		claimed = addRange(claimed, ReservedRange{Start: v, End: v})
+	}
+	return r.persistLocked(reserved, claimed)
+}
+
+// putBack 认领失败时恢复保留（尽力而为）
+func (r *reservations) putBack(v int64) {
+	r.rmu.Lock()
+	defer r.rmu.Unlock()
+	_ = r.persistLocked(addRange(r.reserved, ReservedRange{Start: v, End: v}), r.claimed)
+}
+
+// Reservations 返回保留中和已认领号码的快照
+func (r *reservations) Reservations() ReservationState {
+	r.rmu.RLock()
+	defer r.rmu.RUnlock()
+	return ReservationState{
+		Reserved: append([]ReservedRange(nil), r.reserved...),
+		Claimed:  append([]ReservedRange(nil), r.claimed...),
+	}
+}
+
+// RestoreReservations 恢复保留中和已认领的号码（用于重启恢复）
+func (r *reservations) RestoreReservations(state ReservationState) {
+	r.rmu.Lock()
+	defer r.rmu.Unlock()
+
+	r.reserved, r.claimed = nil, nil
+	for _, rng := range state.Reserved {
+		r.reserved = addRange(r.reserved, rng)
+	}
+	for _, rng := range state.Claimed {
+		r.claimed = addRange(r.claimed, rng)
+	}
+}
+
+// SetReservationLog 设置保留状态的持久化回调
+// 每次变化后以完整内容调用，回调失败时本次操作失败
+func (r *reservations) SetReservationLog(fn func(state ReservationState) error) {
+	r.rmu.Lock()
+	defer r.rmu.Unlock()
+	r.log = fn
+}
+
+// reservationStats 填充统计信息中的保留号码数
+func (r *reservations) reservationStats(stats *DispenserStats) {
+	r.rmu.RLock()
+	defer r.rmu.RUnlock()
+	stats.Reserved = countRanges(r.reserved)
+	stats.Claimed = countRanges(r.claimed)
+}
+
+// claimedValue 按发号器的输出格式生成认领的号码（与 formatCounter 的补零规则一致）
+func claimedValue(cfg Config, v int64) string {
+	body := fmt.Sprintf("%d", v)
+	if cfg.Type == TypeNumericRandom || cfg.IncrMode == IncrModeFixed || cfg.IncrMode == IncrModePermuted {
+		body = fmt.Sprintf("%0*d", cfg.Length, v)
+	}
+	return appendCheckDigit(cfg.CheckDigit, body)
+}
+
+// ============================================
+// 各实现的保留与认领
+// ============================================
+
+// Reserve 保留号码区间，认领或解除保留之前 Next() 不会发出
+func (d *Dispenser) Reserve(ranges []ReservedRange) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.reservations.add(d.config, ranges, d.issuedLocked, d.rangeIssuedLocked)
+}
+
+// Claim 显式发出一个保留的号码
+// Type 1 的号码记入去重集合和去重日志；Type 2 的号码记入已认领集合，计数器经过时继续跳过
+func (d *Dispenser) Claim(v int64) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.config.Type != TypeNumericRandom {
+		if err := d.reservations.take(v, true); err != nil {
+			return "", err
+		}
+		return claimedValue(d.config, v), nil
+	}
+
+	if err := d.reservations.take(v, false); err != nil {
+		return "", err
+	}
+	d.markUsed(v)
+	if err := d.flushUsed(); err != nil {
+		d.reservations.putBack(v)
+		return "", err
+	}
+	d.totalGenerated++
+	return claimedValue(d.config, v), nil
+}
+
+// rangeIssuedLocked 判断 [lo, hi] 内是否有号码已发出（调用方需持有锁）
+func (d *Dispenser) rangeIssuedLocked(lo, hi int64) bool {
+	if d.config.Type == TypeNumericRandom {
+		return d.used.containsAny(lo, hi)
+	}
+	return pathIn(d.config, lo, hi, d.config.Starting, d.current)
+}
+
+// Reserve 保留号码区间，认领或解除保留之前 Next() 不会发出
+func (sd *SegmentDispenser) Reserve(ranges []ReservedRange) (int64, error) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	sd.nextSegmentMu.Lock()
+	defer sd.nextSegmentMu.Unlock()
+
+	return sd.reservations.add(sd.config, ranges, sd.issuedLocked, func(lo, hi int64) bool {
+		return segmentRangeIssued(sd.config, lo, hi, sd.currentNumber, sd.segmentEnd, sd.allocEnd,
+			sd.nextSegmentReady, sd.nextSegmentStart, sd.nextSegmentEnd)
+	})
+}
+
+// Claim 显式发出一个保留的号码
+func (sd *SegmentDispenser) Claim(v int64) (string, error) {
+	if err := sd.reservations.take(v, true); err != nil {
+		return "", err
+	}
+	return claimedValue(sd.config, v), nil
+}
+
+// Reserve 保留号码区间，认领或解除保留之前 Next() 不会发出
+func (osd *OptimizedSegmentDispenser) Reserve(ranges []ReservedRange) (int64, error) {
+	osd.mu.Lock()
+	defer osd.mu.Unlock()
+	osd.nextSegmentMu.Lock()
+	defer osd.nextSegmentMu.Unlock()
+
+	return osd.reservations.add(osd.config, ranges, osd.issuedLocked, func(lo, hi int64) bool {
+		return segmentRangeIssued(osd.config, lo, hi, osd.currentNumber, osd.segmentEnd, osd.allocEnd,
+			osd.nextSegmentReady, osd.nextSegmentStart, osd.nextSegmentEnd)
+	})
+}
+
+// Claim 显式发出一个保留的号码
+func (osd *OptimizedSegmentDispenser) Claim(v int64) (string, error) {
+	if err := osd.reservations.take(v, true); err != nil {
+		return "", err
+	}
+	return claimedValue(osd.config, v), nil
+}
+
+// segmentRangeIssued 判断号段发号器中 [lo, hi] 内是否有号码已发出或已租出
+// 当前号段和预加载号段中尚未使用的部分不算已发出
+func segmentRangeIssued(cfg Config, lo, hi, current, segmentEnd, allocEnd int64, nextReady bool, nextStart, nextEnd int64) bool {
+	if pathIn(cfg, lo, hi, cfg.Starting, current) {
+		return true
+	}
+	if !nextReady {
+		return pathIn(cfg, lo, hi, segmentEnd, allocEnd)
+	}
+	return pathIn(cfg, lo, hi, segmentEnd, nextStart) || pathIn(cfg, lo, hi, nextEnd, allocEnd)
+}
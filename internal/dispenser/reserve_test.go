@@ -0,0 +1,162 @@
+package dispenser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReserve_Incremental(t *testing.T) {
+	d, err := NewDispenser(Config{Type: TypeNumericIncremental, IncrMode: IncrModeFixed, Length: 2, Starting: 1, Step: 2})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	var saved ReservationState
+	d.SetReservationLog(func(state ReservationState) error {
+		saved = state
+		return nil
+	})
+
+	d.NextN(2) // 01, 03
+	tests := []struct {
+		ranges []ReservedRange
+		want   error
+	}{
+		{[]ReservedRange{{Start: 3, End: 3}}, ErrAlreadyIssued},
+		{[]ReservedRange{{Start: 2, End: 4}}, ErrAlreadyIssued},
+		{[]ReservedRange{{Start: 90, End: 100}}, ErrOutOfRange},
+	}
+	for _, tt := range tests {
+		if _, err := d.Reserve(tt.ranges); !errors.Is(err, tt.want) {
+			t.Errorf("Reserve(%v) error = %v, want %v", tt.ranges, err, tt.want)
+		}
+	}
+
+	// 重叠的区间合并，只统计新增的号码
+	if n, err := d.Reserve([]ReservedRange{{Start: 7, End: 12}, {Start: 4, End: 4}}); err != nil || n != 7 {
+		t.Fatalf("Reserve() = %d, %v, expected 7", n, err)
+	}
+	if n, _ := d.Reserve([]ReservedRange{{Start: 10, End: 13}}); n != 1 {
+		t.Errorf("Expected 1 new value, got %d", n)
+	}
+	if len(saved.Reserved) != 2 {
+		t.Errorf("Expected persisted ranges [4 7-13], got %v", saved.Reserved)
+	}
+
+	// 计数器跳过整个保留区间
+	nums, _ := d.NextN(3)
+	if strings.Join(nums, ",") != "05,15,17" {
+		t.Errorf("Expected reserved values skipped, got %v", nums)
+	}
+
+	// 认领的号码计数器经过时继续跳过
+	if num, err := d.Claim(21); !errors.Is(err, ErrNotReserved) {
+		t.Errorf("Expected ErrNotReserved, got %s, %v", num, err)
+	}
+	d.Reserve([]ReservedRange{{Start: 21, End: 21}})
+	if num, err := d.Claim(21); err != nil || num != "21" {
+		t.Fatalf("Claim() = %s, %v", num, err)
+	}
+	if nums, _ := d.NextN(2); strings.Join(nums, ",") != "19,23" {
+		t.Errorf("Expected claimed value skipped, got %v", nums)
+	}
+
+	if n, _ := d.Unreserve([]ReservedRange{{Start: 0, End: 99}}); n != 8 {
+		t.Errorf("Expected 8 unreserved, got %d", n)
+	}
+	if stats := d.GetStats(); stats.Reserved != 0 || stats.Claimed != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// 持久化失败时保留状态不变
+	d.SetReservationLog(func(state ReservationState) error { return errors.New("disk full") })
+	if _, err := d.Reserve([]ReservedRange{{Start: 99, End: 99}}); err == nil {
+		t.Error("Expected error when reservation log fails")
+	}
+	if state := d.Reservations(); len(state.Reserved) != 0 {
+		t.Errorf("Expected no reservations, got %v", state.Reserved)
+	}
+}
+
+func TestReserve_Random(t *testing.T) {
+	d, err := NewDispenser(Config{Type: TypeNumericRandom, Length: 2, RNG: RNGFast})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	// 保留的号码计入耗尽：90个号码保留80个，只能再发10个
+	if _, err := d.Reserve([]ReservedRange{{Start: 10, End: 79}, {Start: 88, End: 88}, {Start: 90, End: 98}}); err != nil {
+		t.Fatalf("Failed to reserve: %v", err)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		num, err := d.Next()
+		if err != nil {
+			t.Fatalf("Failed at %d: %v", i, err)
+		}
+		if num != "99" && (num < "80" || num > "89" || num == "88") {
+			t.Fatalf("Reserved number %s issued", num)
+		}
+		seen[num] = true
+	}
+	if _, err := d.Next(); err != ErrNumberExhausted {
+		t.Errorf("Expected ErrNumberExhausted, got %v", err)
+	}
+
+	if num, err := d.Claim(88); err != nil || num != "88" {
+		t.Fatalf("Claim() = %s, %v", num, err)
+	}
+	if _, err := d.Reserve([]ReservedRange{{Start: 88, End: 88}}); !errors.Is(err, ErrAlreadyIssued) {
+		t.Errorf("Expected ErrAlreadyIssued, got %v", err)
+	}
+	if stats := d.GetStats(); stats.Reserved != 79 || stats.UniqueTracked != 11 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestReserve_SegmentAndFormat(t *testing.T) {
+	factory := NewDispenserFactory(nil)
+	d, err := factory.CreateDispenser("vip", Config{
+		Type:     TypeNumericIncremental,
+		IncrMode: IncrModeSequence,
+		Starting: 1,
+		Format:   "VIP{seq}",
+		AutoDisk: StrategyPreCheckpoint,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+	defer d.Shutdown()
+
+	rd := d.(ReservableDispenser)
+	d.Next() // VIP1，当前号段为 [1, 101)
+
+	// 跨越多个号段的保留区间
+	if _, err := rd.Reserve([]ReservedRange{{Start: 3, End: 3}, {Start: 5, End: 350}}); err != nil {
+		t.Fatalf("Failed to reserve: %v", err)
+	}
+	nums, _ := d.NextN(3)
+	if strings.Join(nums, ",") != "VIP2,VIP4,VIP351" {
+		t.Errorf("Expected reserved values skipped, got %v", nums)
+	}
+	if num, err := rd.Claim(3); err != nil || num != "VIP3" {
+		t.Errorf("Claim() = %s, %v", num, err)
+	}
+
+	// 已发出和已租出的号码不能保留
+	if _, err := rd.Reserve([]ReservedRange{{Start: 2, End: 2}}); !errors.Is(err, ErrAlreadyIssued) {
+		t.Errorf("Expected ErrAlreadyIssued, got %v", err)
+	}
+}
+
+func TestReserve_Unsupported(t *testing.T) {
+	d, _ := NewDispenser(Config{Type: TypeNumericIncremental, ResetPeriod: ResetDaily})
+	if _, err := d.Reserve([]ReservedRange{{Start: 1, End: 1}}); !errors.Is(err, ErrNotReservable) {
+		t.Errorf("Expected ErrNotReservable for reset_period, got %v", err)
+	}
+	d, _ = NewDispenser(Config{Type: TypeAlphanumericRandom, Length: 8})
+	if _, err := d.Reserve([]ReservedRange{{Start: 1, End: 1}}); !errors.Is(err, ErrNotReservable) {
+		t.Errorf("Expected ErrNotReservable for type 3, got %v", err)
+	}
+}
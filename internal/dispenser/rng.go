@@ -0,0 +1,49 @@
+package dispenser
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"math/big"
+	mathrand "math/rand"
+	"time"
+)
+
+// RNG represents the random source for Types 1 and 3
+type RNG string
+
+const (
+	RNGFast   RNG = "fast"   // math/rand，速度快但输出可预测，适合非安全场景
+	RNGSecure RNG = "secure" // crypto/rand，不可预测，适合优惠券码、验证码（默认）
+)
+
+// newRand 创建 math/rand 生成器，种子取自 crypto/rand
+// 避免同一纳秒创建的发号器得到相同的随机序列
+func newRand() *mathrand.Rand {
+	var seed [8]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return mathrand.New(mathrand.NewSource(time.Now().UnixNano()))
+	}
+	return mathrand.New(mathrand.NewSource(int64(binary.LittleEndian.Uint64(seed[:]))))
+}
+
+// randReader 返回 Type 3 使用的随机字节来源
+func (d *Dispenser) randReader() io.Reader {
+	if d.config.RNG == RNGFast {
+		return d.rng
+	}
+	return rand.Reader
+}
+
+// randInt63n 返回 [0, n) 内均匀分布的随机数
+func (d *Dispenser) randInt63n(n int64) (int64, error) {
+	if d.config.RNG == RNGFast {
+		return d.rng.Int63n(n), nil
+	}
+
+	v, err := rand.Int(rand.Reader, big.NewInt(n))
+	if err != nil {
+		return 0, err
+	}
+	return v.Int64(), nil
+}
@@ -1,6 +1,7 @@
 package dispenser
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 )
@@ -12,17 +13,39 @@ type SegmentDispenser struct {
 	config        Config
 	currentNumber int64   // 当前要生成的号码
 	segmentEnd    int64   // 当前号段的结束位置（不包含）
-	segmentSize   int64   // 号段大小
+	segmentSize   int64   // 当前号段大小
 	threshold     float64 // 剩余比例阈值，触发预加载
+	sizer         *segmentSizer
 
 	// 下一个号段（异步预加载）
 	nextSegmentMu    sync.Mutex
 	nextSegmentStart int64
 	nextSegmentEnd   int64
+	nextSegmentSize  int64
 	nextSegmentReady bool
 
+	// 号段分配高水位（已分配给本地号段、预加载号段和外部租约的最大END）
+	// 受 nextSegmentMu 保护
+	allocEnd int64
+
+	// 批量取号失败时退回的号段，按顺序先于预加载号段使用（受 mu 保护）
+	returned []segment
+
 	// 持久化回调
 	persistFunc func(nextStart int64) error
+
+	// 统计信息（受 mu 保护）
+	segmentSwitches int64 // 切换到预加载号段的次数
+	syncAllocations int64 // 取号时同步分配号段的次数
+	errs            errorCounter
+
+	// 保留号码
+	*reservations
+}
+
+// segment 已分配的号段 [start, end)
+type segment struct {
+	start, end, size int64
 }
 
 // NewSegmentDispenser 创建基于号段的发号器
@@ -42,21 +65,25 @@ func NewSegmentDispenser(cfg Config, segmentSize int64, threshold float64, persi
 	}
 
 	sd := &SegmentDispenser{
-		config:      cfg,
-		segmentSize: segmentSize,
-		threshold:   threshold,
-		persistFunc: persistFunc,
+		config:       cfg,
+		segmentSize:  segmentSize,
+		threshold:    threshold,
+		persistFunc:  persistFunc,
+		reservations: newReservations(),
 	}
 
-	// 设置默认步长
+	// 设置默认步长和起始值
 	if sd.config.Step == 0 {
 		sd.config.Step = 1
 	}
+	sd.config.Starting = startingValue(sd.config)
+
+	sd.sizer = newSegmentSizer(cfg, segmentSize)
 
 	// 初始化第一个号段
-	start := cfg.Starting
+	start := sd.config.Starting
 
-	if err := sd.allocateSegment(start); err != nil {
+	if err := sd.allocateSegment(start, sd.sizer.size); err != nil {
 		return nil, err
 	}
 
@@ -73,70 +100,134 @@ func (sd *SegmentDispenser) Next() (string, error) {
 	sd.mu.Lock()
 	defer sd.mu.Unlock()
 
-	// 检查是否需要切换到下一个号段
-	if sd.currentNumber >= sd.segmentEnd {
-		// 当前号段用尽，切换到预加载的下一段
-		sd.nextSegmentMu.Lock()
-		if sd.nextSegmentReady {
-			sd.currentNumber = sd.nextSegmentStart
-			sd.segmentEnd = sd.nextSegmentEnd
-			sd.nextSegmentReady = false
-			sd.nextSegmentMu.Unlock()
-		} else {
-			// 下一段还没准备好（异常情况），同步分配
-			sd.nextSegmentMu.Unlock()
-			if err := sd.allocateSegment(sd.segmentEnd); err != nil {
-				return "", err
+	num, err := sd.nextLocked()
+	return num, sd.errs.record(err)
+}
+
+// NextN 批量生成 n 个号码（整批在同一把锁内完成）
+func (sd *SegmentDispenser) NextN(n int) ([]string, error) {
+	if sd.config.Type != TypeNumericIncremental {
+		return nil, fmt.Errorf("segment allocation only supported for incremental type")
+	}
+	if n <= 0 {
+		return nil, ErrInvalidCount
+	}
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	current, segmentEnd, segmentSize := sd.currentNumber, sd.segmentEnd, sd.segmentSize
+	var entered []segment
+
+	numbers := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		end := sd.segmentEnd
+		num, err := sd.nextLocked()
+		if err != nil {
+			// 回滚：本批次的号码没有发出，恢复取号位置，批次中切入的号段退回，下次按顺序继续使用
+			sd.currentNumber, sd.segmentEnd, sd.segmentSize = current, segmentEnd, segmentSize
+			sd.returned = append(entered, sd.returned...)
+			return nil, sd.errs.record(err)
+		}
+		if sd.segmentEnd != end {
+			entered = append(entered, segment{start: sd.currentNumber - sd.config.Step, end: sd.segmentEnd, size: sd.segmentSize})
+		}
+		numbers = append(numbers, num)
+	}
+
+	return numbers, nil
+}
+
+// nextLocked 在号段内生成一个号码（调用方需持有锁）
+func (sd *SegmentDispenser) nextLocked() (string, error) {
+	for {
+		// 检查是否需要切换到下一个号段
+		if reached(sd.config, sd.currentNumber, sd.segmentEnd) && len(sd.returned) > 0 {
+			// 先用完批量取号失败时退回的号段
+			seg := sd.returned[0]
+			sd.returned = sd.returned[1:]
+			sd.currentNumber, sd.segmentEnd, sd.segmentSize = seg.start, seg.end, seg.size
+		} else if reached(sd.config, sd.currentNumber, sd.segmentEnd) {
+			// 当前号段用尽，切换到预加载的下一段
+			sd.nextSegmentMu.Lock()
+			if sd.nextSegmentReady {
+				sd.currentNumber = sd.nextSegmentStart
+				sd.segmentEnd = sd.nextSegmentEnd
+				sd.segmentSize = sd.nextSegmentSize
+				sd.nextSegmentReady = false
+				sd.nextSegmentMu.Unlock()
+				sd.segmentSwitches++
+			} else {
+				// 下一段还没准备好（异常情况），同步分配
+				sd.syncAllocations++
+				err := sd.allocateSegment(sd.allocEnd, sd.sizer.next())
+				sd.nextSegmentMu.Unlock()
+				if err != nil {
+					return "", err
+				}
 			}
 		}
+
+		// 跳过保留的号码，跳过的计数器视为已消耗
+		next, blocked := sd.reservations.skip(sd.config, sd.currentNumber)
+		if !blocked {
+			break
+		}
+		if reached(sd.config, next, sd.segmentEnd) {
+			next = sd.segmentEnd
+		}
+		sd.currentNumber = next
 	}
 
 	// 在号段内生成号码（无磁盘IO，极快）
 	num := sd.currentNumber
 	sd.currentNumber += sd.config.Step
+	sd.sizer.record(1)
 
 	// 检查是否需要预加载下一个号段
 	remaining := float64(sd.segmentEnd-sd.currentNumber) / float64(sd.segmentSize*sd.config.Step)
-	if remaining <= sd.threshold && !sd.nextSegmentReady {
+	if remaining <= sd.threshold && !sd.preloadPending() {
 		// 异步预加载下一个号段
 		go sd.preloadNextSegment()
 	}
 
 	// 格式化输出
-	if sd.config.IncrMode == IncrModeFixed {
-		return fmt.Sprintf("%0*d", sd.config.Length, num), nil
+	return appendCheckDigit(sd.config.CheckDigit, formatCounter(sd.config, num)), nil
+}
+
+// preloadPending 检查下一个号段是否已就绪或正在预加载（调用方需持有 mu）
+// nextSegmentReady 受 nextSegmentMu 保护；预加载持有该锁写磁盘期间取锁失败，
+// 视为正在预加载，避免取号等待磁盘IO
+func (sd *SegmentDispenser) preloadPending() bool {
+	if !sd.nextSegmentMu.TryLock() {
+		return true
 	}
-	return fmt.Sprintf("%d", num), nil
+	defer sd.nextSegmentMu.Unlock()
+	return sd.nextSegmentReady
 }
 
 // allocateSegment 分配一个新号段（会写磁盘）
-func (sd *SegmentDispenser) allocateSegment(start int64) error {
-	end := start + sd.segmentSize*sd.config.Step
-
-	// 检查固定位数模式的边界
-	if sd.config.IncrMode == IncrModeFixed {
-		maxValue := pow10(sd.config.Length) - 1
-
-		if start >= maxValue {
-			return ErrNumberExhausted
-		}
-
-		if end > maxValue {
-			end = maxValue + 1
-		}
+// 调用方需持有 nextSegmentMu（构造期间除外）
+func (sd *SegmentDispenser) allocateSegment(start, size int64) error {
+	start, end, err := segmentRange(sd.config, start, size)
+	if err != nil {
+		return err
 	}
+	size = (end - start) / sd.config.Step
 
 	// 持久化号段结束位置
 	// 关键：保存的是号段的END，而不是START
 	// 这样即使重启，也会从END开始分配新号段，不会重复
 	if sd.persistFunc != nil {
 		if err := sd.persistFunc(end); err != nil {
-			return err
+			return &persistError{err}
 		}
 	}
 
 	sd.currentNumber = start
 	sd.segmentEnd = end
+	sd.segmentSize = size
+	sd.allocEnd = end
 
 	return nil
 }
@@ -151,8 +242,12 @@ func (sd *SegmentDispenser) preloadNextSegment() {
 	}
 
 	// 计算下一个号段
-	start := sd.segmentEnd
-	end := start + sd.segmentSize*sd.config.Step
+	start, end, err := segmentRange(sd.config, sd.allocEnd, sd.sizer.next())
+	if err != nil {
+		// 号码耗尽，下次同步分配时返回错误
+		return
+	}
+	size := (end - start) / sd.config.Step
 
 	// 持久化
 	if sd.persistFunc != nil {
@@ -164,7 +259,45 @@ func (sd *SegmentDispenser) preloadNextSegment() {
 
 	sd.nextSegmentStart = start
 	sd.nextSegmentEnd = end
+	sd.nextSegmentSize = size
 	sd.nextSegmentReady = true
+	sd.allocEnd = end
+}
+
+// AllocateSegment 租出一个号段给客户端自行发号
+// 号段从分配高水位开始，与本地号段、预加载号段都不重叠，返回前已持久化
+func (sd *SegmentDispenser) AllocateSegment(segmentSize int64) (start, end int64, err error) {
+	if sd.config.Type != TypeNumericIncremental {
+		return 0, 0, errors.New("segment allocation only supported for incremental type")
+	}
+	if segmentSize <= 0 {
+		return 0, 0, ErrInvalidCount
+	}
+	if sd.config.IncrMode == IncrModePermuted {
+		return 0, 0, errPermutedSegment
+	}
+	if sd.config.OnExhausted == ExhaustedWrap {
+		return 0, 0, errWrapSegment
+	}
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	sd.nextSegmentMu.Lock()
+	defer sd.nextSegmentMu.Unlock()
+
+	start, end, err = segmentRange(sd.config, sd.allocEnd, segmentSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if sd.persistFunc != nil {
+		if err := sd.persistFunc(end); err != nil {
+			return 0, 0, err
+		}
+	}
+	sd.allocEnd = end
+
+	return start, end, nil
 }
 
 // GetConfig 返回配置
@@ -174,11 +307,13 @@ func (sd *SegmentDispenser) GetConfig() Config {
 	return sd.config
 }
 
-// GetCurrent 返回当前号段结束位置（用于持久化）
+// GetCurrent 返回号段分配高水位（用于持久化）
 func (sd *SegmentDispenser) GetCurrent() int64 {
 	sd.mu.Lock()
 	defer sd.mu.Unlock()
-	return sd.segmentEnd // 返回号段END，不是当前号码
+	sd.nextSegmentMu.Lock()
+	defer sd.nextSegmentMu.Unlock()
+	return sd.allocEnd // 返回已分配的最大END，不是当前号码
 }
 
 // GetSegmentInfo 返回号段信息（用于监控）
@@ -186,18 +321,30 @@ func (sd *SegmentDispenser) GetSegmentInfo() (current, end int64, usage float64)
 	sd.mu.Lock()
 	defer sd.mu.Unlock()
 
-	used := sd.currentNumber - (sd.segmentEnd - sd.segmentSize*sd.config.Step)
-	total := sd.segmentSize * sd.config.Step
-	usage = float64(used) / float64(total)
+	used := sd.segmentSize - countBetween(sd.config, sd.currentNumber, sd.segmentEnd)
+	usage = float64(used) / float64(sd.segmentSize)
 
 	return sd.currentNumber, sd.segmentEnd, usage
 }
 
 // SetCurrent 设置当前位置（用于恢复）
+// 丢弃构造时分配的号段，下次取号时从 current 重新分配；
+// 同时立即持久化 current，覆盖构造时写入的初始号段END
 func (sd *SegmentDispenser) SetCurrent(current int64) {
 	sd.mu.Lock()
 	defer sd.mu.Unlock()
+	sd.nextSegmentMu.Lock()
+	defer sd.nextSegmentMu.Unlock()
+
 	sd.currentNumber = current
+	sd.segmentEnd = current
+	sd.allocEnd = current
+	sd.nextSegmentReady = false
+	sd.returned = nil
+
+	if sd.persistFunc != nil {
+		_ = sd.persistFunc(current)
+	}
 }
 
 // Shutdown 关闭发号器（基础版无需特殊处理）
@@ -209,10 +356,12 @@ func (sd *SegmentDispenser) Shutdown() error {
 func (sd *SegmentDispenser) GetStats() DispenserStats {
 	sd.mu.Lock()
 	defer sd.mu.Unlock()
+	sd.nextSegmentMu.Lock()
+	defer sd.nextSegmentMu.Unlock()
 
 	// 预分配基础版可能有浪费
-	wasted := sd.segmentEnd - sd.currentNumber
-	generated := sd.currentNumber - sd.config.Starting
+	wasted := countBetween(sd.config, sd.currentNumber, sd.segmentEnd)
+	generated := countBetween(sd.config, sd.config.Starting, sd.currentNumber)
 	totalNumbers := generated + wasted
 
 	var wasteRate float64
@@ -220,10 +369,18 @@ func (sd *SegmentDispenser) GetStats() DispenserStats {
 		wasteRate = float64(wasted) / float64(totalNumbers) * 100
 	}
 
-	return DispenserStats{
-		TotalGenerated: generated,
-		TotalWasted:    wasted,
-		WasteRate:      wasteRate,
-		Strategy:       sd.config.AutoDisk,
+	stats := DispenserStats{
+		TotalGenerated:   generated,
+		TotalWasted:      wasted,
+		WasteRate:        wasteRate,
+		Strategy:         sd.config.AutoDisk,
+		PreloadThreshold: sd.threshold,
+		SegmentSwitches:  sd.segmentSwitches,
+		SyncAllocations:  sd.syncAllocations,
 	}
+	sd.sizer.fillStats(&stats)
+	sd.reservationStats(&stats)
+	sd.errs.fillStats(&stats)
+
+	return stats
 }
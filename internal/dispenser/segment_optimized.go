@@ -1,6 +1,7 @@
 package dispenser
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -14,24 +15,41 @@ type OptimizedSegmentDispenser struct {
 	config        Config
 	currentNumber int64 // 当前要生成的号码
 	segmentEnd    int64 // 当前号段的结束位置
-	segmentSize   int64 // 号段大小
+	segmentSize   int64 // 当前号段大小
 	threshold     float64
+	sizer         *segmentSizer
 
 	// 下一个号段
 	nextSegmentMu    sync.Mutex
 	nextSegmentStart int64
 	nextSegmentEnd   int64
+	nextSegmentSize  int64
 	nextSegmentReady bool
 
+	// 号段分配高水位（受 nextSegmentMu 保护）
+	allocEnd int64
+	// 批量取号失败时退回的号段（受 mu 保护）
+	returned []segment
+	// 已租出号段的最大END，持久化位置不得落在此值之前
+	leasedEnd int64
+	leased    bool
+
 	// 持久化相关
 	persistFunc      func(nextStart int64) error
 	lastPersisted    int64 // 上次持久化的位置
 	checkpointTicker *time.Ticker
+	checkpointEvery  time.Duration
 	stopChan         chan struct{}
 
 	// 统计信息
-	totalGenerated int64 // 总共生成的号码数
-	totalWasted    int64 // 总共浪费的号码数
+	totalGenerated  int64 // 总共生成的号码数
+	totalWasted     int64 // 总共浪费的号码数
+	segmentSwitches int64 // 切换到预加载号段的次数
+	syncAllocations int64 // 取号时同步分配号段的次数
+	errs            errorCounter
+
+	// 保留号码
+	*reservations
 }
 
 // NewOptimizedSegmentDispenser 创建优化版号段发号器
@@ -56,22 +74,27 @@ func NewOptimizedSegmentDispenser(
 	}
 
 	osd := &OptimizedSegmentDispenser{
-		config:      cfg,
-		segmentSize: segmentSize,
-		threshold:   threshold,
-		persistFunc: persistFunc,
-		stopChan:    make(chan struct{}),
+		config:          cfg,
+		segmentSize:     segmentSize,
+		threshold:       threshold,
+		persistFunc:     persistFunc,
+		checkpointEvery: checkpointInterval,
+		stopChan:        make(chan struct{}),
+		reservations:    newReservations(),
 	}
 
-	// 设置默认步长
+	// 设置默认步长和起始值
 	if osd.config.Step == 0 {
 		osd.config.Step = 1
 	}
+	osd.config.Starting = startingValue(osd.config)
+
+	osd.sizer = newSegmentSizer(cfg, segmentSize)
 
 	// 初始化第一个号段
-	start := cfg.Starting
+	start := osd.config.Starting
 
-	if err := osd.allocateSegment(start); err != nil {
+	if err := osd.allocateSegment(start, osd.sizer.size); err != nil {
 		return nil, err
 	}
 
@@ -93,70 +116,135 @@ func (osd *OptimizedSegmentDispenser) Next() (string, error) {
 	osd.mu.Lock()
 	defer osd.mu.Unlock()
 
-	// 检查是否需要切换号段
-	if osd.currentNumber >= osd.segmentEnd {
-		osd.nextSegmentMu.Lock()
-		if osd.nextSegmentReady {
-			// 记录浪费的号码数
-			wasted := osd.segmentEnd - osd.lastPersisted
-			atomic.AddInt64(&osd.totalWasted, wasted)
-
-			osd.currentNumber = osd.nextSegmentStart
-			osd.segmentEnd = osd.nextSegmentEnd
-			osd.nextSegmentReady = false
-			osd.nextSegmentMu.Unlock()
-		} else {
-			osd.nextSegmentMu.Unlock()
-			if err := osd.allocateSegment(osd.segmentEnd); err != nil {
-				return "", err
+	num, err := osd.nextLocked()
+	return num, osd.errs.record(err)
+}
+
+// NextN 批量生成 n 个号码（整批在同一把锁内完成）
+func (osd *OptimizedSegmentDispenser) NextN(n int) ([]string, error) {
+	if osd.config.Type != TypeNumericIncremental {
+		return nil, fmt.Errorf("segment allocation only supported for incremental type")
+	}
+	if n <= 0 {
+		return nil, ErrInvalidCount
+	}
+
+	osd.mu.Lock()
+	defer osd.mu.Unlock()
+
+	current, segmentEnd, segmentSize := osd.currentNumber, osd.segmentEnd, osd.segmentSize
+	totalGenerated := atomic.LoadInt64(&osd.totalGenerated)
+	var entered []segment
+
+	numbers := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		end := osd.segmentEnd
+		num, err := osd.nextLocked()
+		if err != nil {
+			// 回滚：恢复取号位置并退回批次中切入的号段（同 SegmentDispenser.NextN）
+			osd.currentNumber, osd.segmentEnd, osd.segmentSize = current, segmentEnd, segmentSize
+			osd.returned = append(entered, osd.returned...)
+			atomic.StoreInt64(&osd.totalGenerated, totalGenerated)
+			return nil, osd.errs.record(err)
+		}
+		if osd.segmentEnd != end {
+			entered = append(entered, segment{start: osd.currentNumber - osd.config.Step, end: osd.segmentEnd, size: osd.segmentSize})
+		}
+		numbers = append(numbers, num)
+	}
+
+	return numbers, nil
+}
+
+// nextLocked 生成一个号码（调用方需持有锁）
+func (osd *OptimizedSegmentDispenser) nextLocked() (string, error) {
+	for {
+		// 检查是否需要切换号段
+		if reached(osd.config, osd.currentNumber, osd.segmentEnd) && len(osd.returned) > 0 {
+			// 先用完批量取号失败时退回的号段
+			seg := osd.returned[0]
+			osd.returned = osd.returned[1:]
+			osd.currentNumber, osd.segmentEnd, osd.segmentSize = seg.start, seg.end, seg.size
+		} else if reached(osd.config, osd.currentNumber, osd.segmentEnd) {
+			osd.nextSegmentMu.Lock()
+			if osd.nextSegmentReady {
+				// 记录浪费的号码数
+				wasted := countBetween(osd.config, osd.lastPersisted, osd.segmentEnd)
+				atomic.AddInt64(&osd.totalWasted, wasted)
+
+				osd.currentNumber = osd.nextSegmentStart
+				osd.segmentEnd = osd.nextSegmentEnd
+				osd.segmentSize = osd.nextSegmentSize
+				osd.nextSegmentReady = false
+				osd.nextSegmentMu.Unlock()
+				atomic.AddInt64(&osd.segmentSwitches, 1)
+			} else {
+				atomic.AddInt64(&osd.syncAllocations, 1)
+				err := osd.allocateSegment(osd.allocEnd, osd.sizer.next())
+				osd.nextSegmentMu.Unlock()
+				if err != nil {
+					return "", err
+				}
 			}
 		}
+
+		// 跳过保留的号码，跳过的计数器视为已消耗
+		next, blocked := osd.reservations.skip(osd.config, osd.currentNumber)
+		if !blocked {
+			break
+		}
+		if reached(osd.config, next, osd.segmentEnd) {
+			next = osd.segmentEnd
+		}
+		osd.currentNumber = next
 	}
 
 	// 生成号码
 	num := osd.currentNumber
 	osd.currentNumber += osd.config.Step
 	atomic.AddInt64(&osd.totalGenerated, 1)
+	osd.sizer.record(1)
 
 	// 检查是否需要预加载
 	remaining := float64(osd.segmentEnd-osd.currentNumber) / float64(osd.segmentSize*osd.config.Step)
-	if remaining <= osd.threshold && !osd.nextSegmentReady {
+	if remaining <= osd.threshold && !osd.preloadPending() {
 		go osd.preloadNextSegment()
 	}
 
 	// 格式化输出
-	if osd.config.IncrMode == IncrModeFixed {
-		return fmt.Sprintf("%0*d", osd.config.Length, num), nil
+	return appendCheckDigit(osd.config.CheckDigit, formatCounter(osd.config, num)), nil
+}
+
+// preloadPending 检查下一个号段是否已就绪或正在预加载（调用方需持有 mu）
+// 取锁失败时视为正在预加载，见 SegmentDispenser.preloadPending
+func (osd *OptimizedSegmentDispenser) preloadPending() bool {
+	if !osd.nextSegmentMu.TryLock() {
+		return true
 	}
-	return fmt.Sprintf("%d", num), nil
+	defer osd.nextSegmentMu.Unlock()
+	return osd.nextSegmentReady
 }
 
 // allocateSegment 分配新号段
-func (osd *OptimizedSegmentDispenser) allocateSegment(start int64) error {
-	end := start + osd.segmentSize*osd.config.Step
-
-	// 检查边界
-	if osd.config.IncrMode == IncrModeFixed {
-		maxValue := pow10(osd.config.Length) - 1
-
-		if start >= maxValue {
-			return ErrNumberExhausted
-		}
-
-		if end > maxValue {
-			end = maxValue + 1
-		}
+// 调用方需持有 nextSegmentMu（构造期间除外）
+func (osd *OptimizedSegmentDispenser) allocateSegment(start, size int64) error {
+	start, end, err := segmentRange(osd.config, start, size)
+	if err != nil {
+		return err
 	}
+	size = (end - start) / osd.config.Step
 
 	// 持久化号段END（用于恢复时的起点）
 	if osd.persistFunc != nil {
 		if err := osd.persistFunc(end); err != nil {
-			return err
+			return &persistError{err}
 		}
 	}
 
 	osd.currentNumber = start
 	osd.segmentEnd = end
+	osd.segmentSize = size
+	osd.allocEnd = end
 	osd.lastPersisted = end // 记录持久化位置
 
 	return nil
@@ -171,8 +259,11 @@ func (osd *OptimizedSegmentDispenser) preloadNextSegment() {
 		return
 	}
 
-	start := osd.segmentEnd
-	end := start + osd.segmentSize*osd.config.Step
+	start, end, err := segmentRange(osd.config, osd.allocEnd, osd.sizer.next())
+	if err != nil {
+		return
+	}
+	size := (end - start) / osd.config.Step
 
 	if osd.persistFunc != nil {
 		if err := osd.persistFunc(end); err != nil {
@@ -182,7 +273,56 @@ func (osd *OptimizedSegmentDispenser) preloadNextSegment() {
 
 	osd.nextSegmentStart = start
 	osd.nextSegmentEnd = end
+	osd.nextSegmentSize = size
 	osd.nextSegmentReady = true
+	osd.allocEnd = end
+}
+
+// AllocateSegment 租出一个号段给客户端自行发号
+// 号段从分配高水位开始，与本地号段、预加载号段都不重叠，返回前已持久化
+func (osd *OptimizedSegmentDispenser) AllocateSegment(segmentSize int64) (start, end int64, err error) {
+	if osd.config.Type != TypeNumericIncremental {
+		return 0, 0, errors.New("segment allocation only supported for incremental type")
+	}
+	if segmentSize <= 0 {
+		return 0, 0, ErrInvalidCount
+	}
+	if osd.config.IncrMode == IncrModePermuted {
+		return 0, 0, errPermutedSegment
+	}
+	if osd.config.OnExhausted == ExhaustedWrap {
+		return 0, 0, errWrapSegment
+	}
+
+	osd.mu.Lock()
+	defer osd.mu.Unlock()
+	osd.nextSegmentMu.Lock()
+	defer osd.nextSegmentMu.Unlock()
+
+	start, end, err = segmentRange(osd.config, osd.allocEnd, segmentSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if osd.persistFunc != nil {
+		if err := osd.persistFunc(end); err != nil {
+			return 0, 0, err
+		}
+	}
+	osd.allocEnd = end
+	osd.leasedEnd = end
+	osd.leased = true
+
+	return start, end, nil
+}
+
+// persistFloorLocked 返回可安全持久化的最低位置（调用方需持有 mu）
+// checkpoint 只保存实际使用位置，但不能低于已租出号段的END
+func (osd *OptimizedSegmentDispenser) persistFloorLocked() int64 {
+	if osd.leased && !reached(osd.config, osd.currentNumber, osd.leasedEnd) {
+		return osd.leasedEnd
+	}
+	return osd.currentNumber
 }
 
 // startCheckpoint 启动定期checkpoint
@@ -205,15 +345,18 @@ func (osd *OptimizedSegmentDispenser) startCheckpoint(interval time.Duration) {
 // 这是减少浪费的关键
 func (osd *OptimizedSegmentDispenser) checkpoint() error {
 	osd.mu.Lock()
-	current := osd.currentNumber
+	current := osd.persistFloorLocked()
+	changed := current != osd.lastPersisted
 	osd.mu.Unlock()
 
 	// 如果当前位置和上次持久化位置不同，则保存
-	if current != osd.lastPersisted && osd.persistFunc != nil {
+	if changed && osd.persistFunc != nil {
 		if err := osd.persistFunc(current); err != nil {
 			return err
 		}
+		osd.mu.Lock()
 		osd.lastPersisted = current
+		osd.mu.Unlock()
 	}
 
 	return nil
@@ -230,7 +373,7 @@ func (osd *OptimizedSegmentDispenser) GracefulShutdown() error {
 
 	// 保存当前实际位置
 	osd.mu.Lock()
-	current := osd.currentNumber
+	current := osd.persistFloorLocked()
 	lastPersisted := osd.lastPersisted
 	osd.mu.Unlock()
 
@@ -243,8 +386,7 @@ func (osd *OptimizedSegmentDispenser) GracefulShutdown() error {
 	// 计算最终浪费的号码
 	// 浪费 = 原本承诺分配到的位置(lastPersisted) - 实际使用的位置(current)
 	// 如果优雅关闭前有checkpoint，浪费就更少
-	if lastPersisted > current {
-		wasted := lastPersisted - current
+	if wasted := countBetween(osd.config, current, lastPersisted); wasted > 0 {
 		atomic.AddInt64(&osd.totalWasted, wasted)
 	}
 	// 当前号段剩余的号码在优雅关闭后不算浪费，因为我们保存了current
@@ -262,12 +404,24 @@ func (osd *OptimizedSegmentDispenser) GetStats() DispenserStats {
 		wasteRate = float64(wasted) / float64(generated+wasted) * 100
 	}
 
-	return DispenserStats{
-		TotalGenerated: generated,
-		TotalWasted:    wasted,
-		WasteRate:      wasteRate,
-		Strategy:       osd.config.AutoDisk,
+	stats := DispenserStats{
+		TotalGenerated:     generated,
+		TotalWasted:        wasted,
+		WasteRate:          wasteRate,
+		Strategy:           osd.config.AutoDisk,
+		PreloadThreshold:   osd.threshold,
+		CheckpointInterval: osd.checkpointEvery,
+		SegmentSwitches:    atomic.LoadInt64(&osd.segmentSwitches),
+		SyncAllocations:    atomic.LoadInt64(&osd.syncAllocations),
 	}
+
+	osd.nextSegmentMu.Lock()
+	osd.sizer.fillStats(&stats)
+	osd.nextSegmentMu.Unlock()
+	osd.reservationStats(&stats)
+	osd.errs.fillStats(&stats)
+
+	return stats
 }
 
 // GetConfig 返回配置
@@ -277,18 +431,33 @@ func (osd *OptimizedSegmentDispenser) GetConfig() Config {
 	return osd.config
 }
 
-// GetCurrent 返回当前位置
+// GetCurrent 返回当前位置（不低于已租出号段的END）
 func (osd *OptimizedSegmentDispenser) GetCurrent() int64 {
 	osd.mu.Lock()
 	defer osd.mu.Unlock()
-	return osd.currentNumber
+	return osd.persistFloorLocked()
 }
 
 // SetCurrent 设置当前位置（用于恢复）
+// 丢弃构造时分配的号段，下次取号时从 current 重新分配；
+// 同时立即持久化 current，覆盖构造时写入的初始号段END
 func (osd *OptimizedSegmentDispenser) SetCurrent(current int64) {
 	osd.mu.Lock()
 	defer osd.mu.Unlock()
+	osd.nextSegmentMu.Lock()
+	defer osd.nextSegmentMu.Unlock()
+
 	osd.currentNumber = current
+	osd.segmentEnd = current
+	osd.allocEnd = current
+	osd.leasedEnd = 0
+	osd.leased = false
+	osd.nextSegmentReady = false
+	osd.returned = nil
+
+	if osd.persistFunc != nil && osd.persistFunc(current) == nil {
+		osd.lastPersisted = current
+	}
 }
 
 // Shutdown 优雅关闭（调用GracefulShutdown）
@@ -0,0 +1,101 @@
+package dispenser
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 自适应号段的默认参数
+const (
+	DefaultSegmentMinSize int64 = 10
+	DefaultSegmentMaxSize int64 = 100000
+	DefaultSegmentTarget        = 15 * time.Minute
+)
+
+// segmentSizer 根据实际消耗速度计算下一个号段的大小
+// 参考美团 Leaf 的动态步长：让每个号段大约在 target 时间内用完，
+// 流量突增时号段变大，避免同步分配；流量低时号段变小，减少重启浪费。
+// 每次调整最多翻倍或减半，避免抖动。
+// 除 issued 外的字段由调用方的 nextSegmentMu 保护
+type segmentSizer struct {
+	adaptive bool
+	minSize  int64
+	maxSize  int64
+	target   time.Duration
+
+	size       int64     // 下一个号段的大小
+	issued     int64     // 上次调整以来发出的号码数（原子操作）
+	lastResize time.Time // 上次调整的时间
+	rate       float64   // 上个统计窗口的消耗速度（个/秒）
+}
+
+// newSegmentSizer 创建号段大小计算器，size 为初始号段大小
+// 未配置的上下限以初始大小为基准放宽，保证初始大小落在范围内
+func newSegmentSizer(cfg Config, size int64) *segmentSizer {
+	s := &segmentSizer{
+		adaptive:   cfg.AdaptiveSegment,
+		size:       size,
+		lastResize: time.Now(),
+	}
+	if !s.adaptive {
+		return s
+	}
+
+	s.minSize = cfg.SegmentMinSize
+	if s.minSize == 0 {
+		s.minSize = min(DefaultSegmentMinSize, size)
+	}
+	s.maxSize = cfg.SegmentMaxSize
+	if s.maxSize == 0 {
+		s.maxSize = max(DefaultSegmentMaxSize, size)
+	}
+	s.target = cfg.SegmentTarget
+	if s.target == 0 {
+		s.target = DefaultSegmentTarget
+	}
+
+	s.size = min(max(size, s.minSize), s.maxSize)
+	return s
+}
+
+// record 记录发出的号码数
+func (s *segmentSizer) record(n int64) {
+	atomic.AddInt64(&s.issued, n)
+}
+
+// next 返回下一个号段的大小，自适应模式下先按上个窗口的消耗速度调整
+func (s *segmentSizer) next() int64 {
+	if !s.adaptive {
+		return s.size
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(s.lastResize)
+	issued := atomic.SwapInt64(&s.issued, 0)
+	s.lastResize = now
+
+	if elapsed <= 0 {
+		// 时间窗口过短，按最大增幅处理
+		s.size = min(s.size*2, s.maxSize)
+		return s.size
+	}
+	s.rate = float64(issued) / elapsed.Seconds()
+
+	desired := int64(s.rate * s.target.Seconds())
+	desired = min(max(desired, s.size/2), s.size*2)
+	s.size = min(max(desired, s.minSize), s.maxSize)
+
+	return s.size
+}
+
+// fillStats 填充号段相关统计信息
+func (s *segmentSizer) fillStats(stats *DispenserStats) {
+	stats.SegmentSize = s.size
+	stats.AdaptiveSegment = s.adaptive
+	stats.ConsumptionRate = s.rate
+	if s.adaptive {
+		stats.SegmentMinSize = s.minSize
+		stats.SegmentMaxSize = s.maxSize
+		stats.SegmentTarget = s.target
+	}
+}
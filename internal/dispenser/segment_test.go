@@ -2,9 +2,11 @@ package dispenser
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // 模拟持久化函数
@@ -52,12 +54,12 @@ func TestSegmentDispenser(t *testing.T) {
 	// 第一个号段: [0, 100), 持久化1次
 	// 第二个号段: [100, 200), 持久化1次
 	// 预加载第三个号段可能触发
-	if persistCalled < 2 {
-		t.Errorf("Expected at least 2 persist calls, got %d", persistCalled)
+	if n := atomic.LoadInt64(&persistCalled); n < 2 {
+		t.Errorf("Expected at least 2 persist calls, got %d", n)
 	}
 
-	t.Logf("Generated 150 numbers with only %d disk writes", persistCalled)
-	t.Logf("Performance improvement: %.1fx", 150.0/float64(persistCalled))
+	t.Logf("Generated 150 numbers with only %d disk writes", atomic.LoadInt64(&persistCalled))
+	t.Logf("Performance improvement: %.1fx", 150.0/float64(atomic.LoadInt64(&persistCalled)))
 }
 
 func TestSegmentConcurrency(t *testing.T) {
@@ -112,8 +114,195 @@ func TestSegmentConcurrency(t *testing.T) {
 		t.Errorf("Expected %d unique numbers, got %d", goroutines*numbersPerGoroutine, len(seen))
 	}
 
-	t.Logf("Generated %d numbers with %d disk writes", len(seen), persistCalled)
-	t.Logf("Disk write reduction: %.1fx", float64(len(seen))/float64(persistCalled))
+	t.Logf("Generated %d numbers with %d disk writes", len(seen), atomic.LoadInt64(&persistCalled))
+	t.Logf("Disk write reduction: %.1fx", float64(len(seen))/float64(atomic.LoadInt64(&persistCalled)))
+}
+
+func TestSegmentDispenser_NextN(t *testing.T) {
+	var persistCalled int64
+
+	cfg := Config{
+		Type:     TypeNumericIncremental,
+		IncrMode: IncrModeSequence,
+		Starting: 0,
+		Step:     1,
+	}
+
+	sd, err := NewSegmentDispenser(cfg, 100, 0.2, mockPersist(&persistCalled))
+	if err != nil {
+		t.Fatalf("Failed to create segment dispenser: %v", err)
+	}
+
+	// 批量跨越号段边界
+	nums, err := sd.NextN(250)
+	if err != nil {
+		t.Fatalf("Failed to generate batch: %v", err)
+	}
+
+	for i, num := range nums {
+		if num != formatNum(int64(i)) {
+			t.Fatalf("Expected %d, got %s", i, num)
+		}
+	}
+
+	next, err := sd.Next()
+	if err != nil {
+		t.Fatalf("Failed to generate number: %v", err)
+	}
+	if next != "250" {
+		t.Errorf("Expected 250 after batch, got %s", next)
+	}
+}
+
+func TestSegmentDispenser_AllocateSegment(t *testing.T) {
+	var lastPersisted int64
+
+	cfg := Config{
+		Type:     TypeNumericIncremental,
+		IncrMode: IncrModeSequence,
+		Starting: 0,
+		Step:     1,
+	}
+
+	persist := func(val int64) error {
+		atomic.StoreInt64(&lastPersisted, val)
+		return nil
+	}
+
+	for _, optimized := range []bool{false, true} {
+		t.Run(fmt.Sprintf("optimized=%v", optimized), func(t *testing.T) {
+			var d NumberDispenser
+			var err error
+			if optimized {
+				d, err = NewOptimizedSegmentDispenser(cfg, 100, 0.2, 0, persist)
+			} else {
+				d, err = NewSegmentDispenser(cfg, 100, 0.2, persist)
+			}
+			if err != nil {
+				t.Fatalf("Failed to create dispenser: %v", err)
+			}
+
+			if _, err := d.NextN(10); err != nil {
+				t.Fatalf("Failed to generate batch: %v", err)
+			}
+
+			start, end, err := d.AllocateSegment(50)
+			if err != nil {
+				t.Fatalf("Failed to allocate segment: %v", err)
+			}
+			if start < 100 || end != start+50 {
+				t.Errorf("Lease [%d, %d) overlaps local segment", start, end)
+			}
+			if atomic.LoadInt64(&lastPersisted) < end || d.GetCurrent() < end {
+				t.Errorf("Lease end %d not persisted (persisted=%d, current=%d)",
+					end, atomic.LoadInt64(&lastPersisted), d.GetCurrent())
+			}
+
+			// 本地号段用尽后，新号段必须跳过已租出的号段
+			nums, err := d.NextN(200)
+			if err != nil {
+				t.Fatalf("Failed to generate batch: %v", err)
+			}
+			for _, num := range nums {
+				var n int64
+				fmt.Sscanf(num, "%d", &n)
+				if n >= start && n < end {
+					t.Fatalf("Number %d falls inside leased segment [%d, %d)", n, start, end)
+				}
+			}
+		})
+	}
+}
+
+func TestSegmentSizer_Adaptive(t *testing.T) {
+	cfg := Config{
+		AdaptiveSegment: true,
+		SegmentMinSize:  50,
+		SegmentMaxSize:  1000,
+		SegmentTarget:   time.Second,
+	}
+	s := newSegmentSizer(cfg, 100)
+
+	// 100ms 内用掉 100 个，速度约 1000/s，期望约 1000，但单次最多翻倍
+	s.lastResize = time.Now().Add(-100 * time.Millisecond)
+	s.record(100)
+	if size := s.next(); size != 200 {
+		t.Errorf("Expected size to double to 200, got %d", size)
+	}
+	if s.rate < 500 {
+		t.Errorf("Expected rate around 1000/s, got %.2f", s.rate)
+	}
+
+	// 持续高速消耗，增长到上限为止
+	for i := 0; i < 5; i++ {
+		s.lastResize = time.Now().Add(-100 * time.Millisecond)
+		s.record(1000)
+		s.next()
+	}
+	if s.size != 1000 {
+		t.Errorf("Expected size capped at 1000, got %d", s.size)
+	}
+
+	// 流量下降，逐步缩小到下限
+	for i := 0; i < 10; i++ {
+		s.lastResize = time.Now().Add(-10 * time.Second)
+		s.record(1)
+		s.next()
+	}
+	if s.size != 50 {
+		t.Errorf("Expected size shrunk to 50, got %d", s.size)
+	}
+
+	// 未启用自适应时大小不变
+	fixed := newSegmentSizer(Config{}, 100)
+	fixed.record(100000)
+	if size := fixed.next(); size != 100 {
+		t.Errorf("Expected fixed size 100, got %d", size)
+	}
+}
+
+func TestSegmentDispenser_AdaptiveStats(t *testing.T) {
+	var persistCalled int64
+
+	cfg := Config{
+		Type:            TypeNumericIncremental,
+		IncrMode:        IncrModeSequence,
+		Starting:        0,
+		Step:            1,
+		AdaptiveSegment: true,
+	}
+
+	sd, err := NewSegmentDispenser(cfg, 10, 0.5, mockPersist(&persistCalled))
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	// 快速消耗多个号段，号段应变大
+	if _, err := sd.NextN(500); err != nil {
+		t.Fatalf("Failed to generate batch: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	stats := sd.GetStats()
+	if !stats.AdaptiveSegment || stats.SegmentMinSize != DefaultSegmentMinSize ||
+		stats.SegmentMaxSize != DefaultSegmentMaxSize || stats.SegmentTarget != DefaultSegmentTarget {
+		t.Errorf("Unexpected adaptive settings: %+v", stats)
+	}
+	if stats.SegmentSize <= 10 {
+		t.Errorf("Expected segment size to grow, got %d", stats.SegmentSize)
+	}
+	if stats.ConsumptionRate <= 0 {
+		t.Errorf("Expected positive consumption rate, got %.2f", stats.ConsumptionRate)
+	}
+
+	// 号码连续不重复
+	nums, err := sd.NextN(100)
+	if err != nil {
+		t.Fatalf("Failed to generate batch: %v", err)
+	}
+	if nums[0] != "500" {
+		t.Errorf("Expected numbers to continue from 500, got %s", nums[0])
+	}
 }
 
 func formatNum(n int64) string {
@@ -181,3 +370,98 @@ func BenchmarkDispenserWithImmediateSave(b *testing.B) {
 
 	b.ReportMetric(float64(b.N)/float64(persistCalled), "numbers/write")
 }
+
+// 测试批量取号中途失败时不消耗号码：号段切换、同步分配失败和号码耗尽后都回滚到批次开始前
+func TestSegmentDispenser_NextNRollback(t *testing.T) {
+	var failing atomic.Bool
+	persist := func(int64) error {
+		if failing.Load() {
+			return fmt.Errorf("disk full")
+		}
+		return nil
+	}
+
+	cfg := Config{Type: TypeNumericIncremental, IncrMode: IncrModeFixed, Length: 1, Starting: 0, Step: 1}
+	for _, strategy := range []PersistenceStrategy{StrategyPreBase, StrategyPreCheckpoint} {
+		var d NumberDispenser
+		var err error
+		if strategy == StrategyPreBase {
+			d, err = NewSegmentDispenser(cfg, 4, 0.2, persist)
+		} else {
+			d, err = NewOptimizedSegmentDispenser(cfg, 4, 0.2, time.Hour, persist)
+		}
+		if err != nil {
+			t.Fatalf("Failed to create %s dispenser: %v", strategy, err)
+		}
+
+		if _, err := d.NextN(3); err != nil {
+			t.Fatalf("%s: failed to generate batch: %v", strategy, err)
+		}
+		// 第二个号段写盘失败
+		failing.Store(true)
+		if _, err := d.NextN(4); err == nil {
+			t.Fatalf("%s: expected persist error", strategy)
+		}
+		failing.Store(false)
+
+		// 0~9 只剩 7 个号码，批量超出时整批失败，已切入的号段退回
+		if _, err := d.NextN(8); err != ErrNumberExhausted {
+			t.Fatalf("%s: expected ErrNumberExhausted, got %v", strategy, err)
+		}
+		nums, err := d.NextN(7)
+		if err != nil {
+			t.Fatalf("%s: failed to generate remaining numbers: %v", strategy, err)
+		}
+		if got := strings.Join(nums, ","); got != "3,4,5,6,7,8,9" {
+			t.Errorf("%s: expected rolled back numbers reissued in order, got %s", strategy, got)
+		}
+		d.Shutdown()
+	}
+}
+
+func TestSegmentDispenser_ErrorStats(t *testing.T) {
+	var failing atomic.Bool
+	persist := func(int64) error {
+		if failing.Load() {
+			return fmt.Errorf("disk full")
+		}
+		return nil
+	}
+
+	cfg := Config{Type: TypeNumericIncremental, IncrMode: IncrModeFixed, Length: 1, Starting: 0, Step: 1}
+	sd, err := NewSegmentDispenser(cfg, 4, 0.2, persist)
+	if err != nil {
+		t.Fatalf("Failed to create segment dispenser: %v", err)
+	}
+
+	// 预加载和同步分配都失败，第5个号码返回持久化错误（错误信息不变）
+	failing.Store(true)
+	for i := 0; i < 4; i++ {
+		if _, err := sd.Next(); err != nil {
+			t.Fatalf("Failed to generate number: %v", err)
+		}
+	}
+	if _, err := sd.Next(); err == nil || err.Error() != "disk full" {
+		t.Fatalf("Expected persist error, got %v", err)
+	}
+
+	// 恢复后取完 0~9，之后号码耗尽
+	failing.Store(false)
+	for i := 4; i < 10; i++ {
+		if _, err := sd.Next(); err != nil {
+			t.Fatalf("Failed to generate number: %v", err)
+		}
+	}
+	if _, err := sd.NextN(2); err != ErrNumberExhausted {
+		t.Fatalf("Expected ErrNumberExhausted, got %v", err)
+	}
+
+	stats := sd.GetStats()
+	if stats.Errors[ErrorKindPersist] != 1 || stats.Errors[ErrorKindExhausted] != 1 || len(stats.Errors) != 2 {
+		t.Errorf("Unexpected error counts: %v", stats.Errors)
+	}
+	// 跨过了 4、8 两个号段边界，另有一次同步分配失败
+	if stats.SyncAllocations < 2 || stats.SegmentSwitches+stats.SyncAllocations != 4 {
+		t.Errorf("Unexpected segment counters: switches=%d sync=%d", stats.SegmentSwitches, stats.SyncAllocations)
+	}
+}
@@ -0,0 +1,116 @@
+package dispenser
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidCacheSize = errors.New("invalid unique cache size")
+
+// ============================================
+// Type 1 去重集合
+// ============================================
+
+// markUsed 记录新发出的号码（调用方需持有锁）
+// 配置了 unique_cache_size 时只保留最近发出的 N 个号码，更早的号码会被淘汰，之后可能再次发出
+func (d *Dispenser) markUsed(num int64) {
+	d.used.add(num)
+	d.pendingUsed = append(d.pendingUsed, num)
+
+	if size := d.config.UniqueCacheSize; size > 0 {
+		d.usedOrder = append(d.usedOrder, num)
+		if len(d.usedOrder) > size {
+			d.used.remove(d.usedOrder[0])
+			d.usedOrder = d.usedOrder[1:]
+		}
+	}
+}
+
+// flushUsed 将本次调用新占用的号码写入去重日志（调用方需持有锁）
+// 写入失败时释放这些号码，不会把未持久化的号码发出去
+func (d *Dispenser) flushUsed() error {
+	if len(d.pendingUsed) == 0 {
+		return nil
+	}
+
+	if d.usedLog != nil {
+		if err := d.usedLog(d.pendingUsed); err != nil {
+			d.discardPending()
+			return &persistError{fmt.Errorf("failed to persist issued numbers: %w", err)}
+		}
+	}
+	d.pendingUsed = d.pendingUsed[:0]
+	return nil
+}
+
+// discardPending 释放本次调用新占用的号码（调用方需持有锁）
+func (d *Dispenser) discardPending() {
+	for _, num := range d.pendingUsed {
+		d.used.remove(num)
+	}
+
+	// 新占用的号码位于发号顺序的末尾
+	n := min(len(d.pendingUsed), len(d.usedOrder))
+	d.usedOrder = d.usedOrder[:len(d.usedOrder)-n]
+	d.pendingUsed = d.pendingUsed[:0]
+}
+
+// RestoreUsed 按发号顺序恢复已发号码（用于重启恢复）
+// 配置了 unique_cache_size 时只保留最后 N 个
+func (d *Dispenser) RestoreUsed(values []int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.config.Type != TypeNumericRandom {
+		return
+	}
+	if size := d.config.UniqueCacheSize; size > 0 && len(values) > size {
+		values = values[len(values)-size:]
+	}
+	for _, num := range values {
+		if d.used.contains(num) {
+			continue
+		}
+		d.markUsed(num)
+	}
+	d.pendingUsed = d.pendingUsed[:0]
+}
+
+// SetUsedLog 设置新发号码的持久化回调
+// 每次发号在返回前调用，回调失败时本次发号失败
+func (d *Dispenser) SetUsedLog(fn func(values []int64) error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.usedLog = fn
+}
+
+// UsedValues 返回当前用于去重的已发号码
+// 配置了 unique_cache_size 时按发号顺序返回，否则按数值升序
+func (d *Dispenser) UsedValues() []int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.used == nil {
+		return nil
+	}
+	if d.config.UniqueCacheSize > 0 {
+		return append([]int64(nil), d.usedOrder...)
+	}
+	return d.used.values()
+}
+
+// uniqueTracked 返回当前用于去重的号码数（调用方需持有锁）
+func (d *Dispenser) uniqueTracked() int64 {
+	if d.used == nil {
+		return 0
+	}
+	return d.used.count()
+}
+
+// uniqueMemory 估算去重集合及发号顺序占用的内存（调用方需持有锁）
+func (d *Dispenser) uniqueMemory() int64 {
+	if d.used == nil {
+		return 0
+	}
+	return d.used.memoryBytes() + int64(cap(d.usedOrder))*8
+}
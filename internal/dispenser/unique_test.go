@@ -0,0 +1,100 @@
+package dispenser
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestType1_UsedLog(t *testing.T) {
+	d, err := NewDispenser(Config{Type: TypeNumericRandom, Length: 4})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	var logged []int64
+	d.SetUsedLog(func(values []int64) error {
+		logged = append(logged, values...)
+		return nil
+	})
+
+	num, _ := d.Next()
+	nums, _ := d.NextN(3)
+	issued := append([]string{num}, nums...)
+
+	if len(logged) != len(issued) {
+		t.Fatalf("Expected %d logged values, got %d", len(issued), len(logged))
+	}
+	for i, v := range logged {
+		if strconv.FormatInt(v, 10) != issued[i] {
+			t.Errorf("Logged %d, issued %s", v, issued[i])
+		}
+	}
+
+	// 写日志失败时不发号，也不占用号码
+	d.SetUsedLog(func(values []int64) error { return errors.New("disk full") })
+	if _, err := d.Next(); err == nil {
+		t.Error("Expected error when used log fails")
+	}
+	if _, err := d.NextN(5); err == nil {
+		t.Error("Expected error when used log fails")
+	}
+	if stats := d.GetStats(); stats.UniqueTracked != 4 || stats.TotalGenerated != 4 {
+		t.Errorf("Expected 4 tracked and generated, got %+v", stats)
+	}
+}
+
+func TestType1_RestoreUsed(t *testing.T) {
+	d, err := NewDispenser(Config{Type: TypeNumericRandom, Length: 1})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	// 1~9 中已发出 1~7，只能再发 8 或 9
+	d.RestoreUsed([]int64{1, 2, 3, 4, 5, 6, 7})
+	num, err := d.Next()
+	if err != nil {
+		t.Fatalf("Failed to generate number: %v", err)
+	}
+	if num != "8" && num != "9" {
+		t.Errorf("Expected 8 or 9, got %s", num)
+	}
+}
+
+func TestType1_UniqueCacheSize(t *testing.T) {
+	d, err := NewDispenser(Config{Type: TypeNumericRandom, Length: 6, UniqueCacheSize: 5})
+	if err != nil {
+		t.Fatalf("Failed to create dispenser: %v", err)
+	}
+
+	// 恢复时只保留最后 5 个
+	d.RestoreUsed([]int64{100001, 100002, 100003, 100004, 100005, 100006, 100007})
+	if d.used.contains(100002) || !d.used.contains(100003) || !d.used.contains(100007) {
+		t.Errorf("Unexpected window after restore: %d tracked", d.used.count())
+	}
+
+	// 窗口内不重复，窗口大小保持不变
+	window := make([]string, 0)
+	for i := 0; i < 50; i++ {
+		num, err := d.Next()
+		if err != nil {
+			t.Fatalf("Failed to generate number: %v", err)
+		}
+		for _, prev := range window {
+			if prev == num {
+				t.Fatalf("Duplicate %s within window", num)
+			}
+		}
+		window = append(window, num)
+		if len(window) > 5 {
+			window = window[1:]
+		}
+	}
+	if tracked := d.GetStats().UniqueTracked; tracked != 5 {
+		t.Errorf("Expected 5 tracked numbers, got %d", tracked)
+	}
+
+	if _, err := NewDispenser(Config{Type: TypeNumericRandom, Length: 6, UniqueCacheSize: -1}); err != ErrInvalidCacheSize {
+		t.Errorf("Expected ErrInvalidCacheSize, got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+// Package metrics writes metrics in the Prometheus text exposition format.
+//
+// 只实现本项目需要的部分：counter、gauge、histogram 三种类型和文本格式 0.0.4，不依赖第三方库。
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentType Prometheus 文本格式的 Content-Type
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// 指标类型（TYPE 行）
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// LatencyBuckets 延迟直方图的默认桶上限（秒），覆盖 50µs ~ 10s
+var LatencyBuckets = []float64{0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// ============================================
+// Histogram
+// ============================================
+
+// Histogram 并发安全的直方图
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64 // 各桶的上限，升序
+	counts  []uint64  // 落入各桶的样本数（不累计），最后一个对应 +Inf
+	sum     float64
+	count   uint64
+}
+
+// NewHistogram creates a histogram with the given bucket upper bounds
+func NewHistogram(buckets []float64) *Histogram {
+	bounds := append([]float64(nil), buckets...)
+	sort.Float64s(bounds)
+	return &Histogram{
+		buckets: bounds,
+		counts:  make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe 记录一个样本
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v) // 第一个 >= v 的桶
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// ObserveSince 记录从 start 到现在经过的秒数
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// Count 返回样本总数
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// Sum 返回样本之和
+func (h *Histogram) Sum() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sum
+}
+
+// ============================================
+// 文本格式输出
+// ============================================
+
+// Labels 按 name, value 成对排列的标签，如 Labels{"name", "order_id", "kind", "persist"}
+type Labels []string
+
+// Writer 按 Prometheus 文本格式写出指标
+// 写出失败后不再写入，错误由 Err 返回
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+// NewWriter creates a writer on w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Err 返回第一个写出错误
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// Family 写出指标族的 HELP 和 TYPE 行，该指标的所有样本需紧随其后写出
+func (w *Writer) Family(name, typ, help string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	w.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// Sample 写出一个样本
+func (w *Writer) Sample(name string, labels Labels, value float64) {
+	w.printf("%s%s %s\n", name, formatLabels(labels), formatValue(value))
+}
+
+// Histogram 写出直方图的 _bucket（累计）、_sum 和 _count 样本
+func (w *Writer) Histogram(name string, labels Labels, h *Histogram) {
+	h.mu.Lock()
+	counts := append([]uint64(nil), h.counts...)
+	sum, count := h.sum, h.count
+	h.mu.Unlock()
+
+	var cumulative uint64
+	for i, n := range counts {
+		cumulative += n
+		le := math.Inf(1)
+		if i < len(h.buckets) {
+			le = h.buckets[i]
+		}
+		w.Sample(name+"_bucket", append(labels[:len(labels):len(labels)], "le", formatValue(le)), float64(cumulative))
+	}
+	w.Sample(name+"_sum", labels, sum)
+	w.Sample(name+"_count", labels, float64(count))
+}
+
+func (w *Writer) printf(format string, args ...any) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = fmt.Fprintf(w.w, format, args...)
+}
+
+// formatLabels 返回 {name="value",...}，没有标签时为空
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(escape.Replace(labels[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// formatValue 按 Prometheus 的格式输出数值（+Inf、-Inf、NaN）
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
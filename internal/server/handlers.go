@@ -2,22 +2,35 @@ package server
 
 import (
 	"fmt"
+	"hash/fnv"
+	"net"
+	"os"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/nicexiaonie/number-dispenser/internal/dispenser"
 	"github.com/nicexiaonie/number-dispenser/internal/protocol"
+	"github.com/nicexiaonie/number-dispenser/internal/storage"
 )
 
 // handleHSet handles the HSET command for configuring a dispenser
 // Format: HSET key field1 value1 [field2 value2 ...]
 //
 // 新的类型系统：
-// Type 1: 纯数字随机 - length, unique_check, auto_disk
-// Type 2: 纯数字自增 - length (可选), starting, step, incr_mode, auto_disk
-// Type 3: 字符随机 - length, charset, auto_disk
-// Type 4: 雪花ID - machine_id, datacenter_id, auto_disk
-// Type 5: UUID - uuid_format, auto_disk
+// Type 1: 纯数字随机 - length, unique_check, check_digit, rng, auto_disk
+// Type 2: 纯数字自增 - length (可选), starting, step, min, max, on_exhausted, incr_mode, perm_key, reset_period, date_format, timezone, check_digit, auto_disk
+// Type 3: 字符随机 - length, charset, custom_alphabet, rng, auto_disk
+// Type 4: 雪花ID - machine_id, datacenter_id, epoch, timestamp_bits, datacenter_bits, worker_bits, sequence_bits, auto_disk
+// Type 5: UUID - uuid_format, uuid_version, machine_id/datacenter_id (v1/v6), auto_disk
+// Type 6: ULID - monotonic, auto_disk
+// Type 4、5 (v7)、6 还支持 clock_rollback, rollback_wait_ms
+// 所有类型都支持 format（格式模板）和 timezone
+// 号段策略（pre-base, pre-checkpoint, pre_close）还支持 segment_size, preload_threshold, checkpoint_interval,
+// adaptive_segment, segment_min_size, segment_max_size, segment_target
+// Type 1、2 还支持 recycle, recycle_cooldown（号码回收）和 warn_at（容量告警）
 func (s *Server) handleHSet(args []string) protocol.Value {
 	if len(args) < 3 || len(args)%2 == 0 {
 		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'hset' command"}
@@ -29,6 +42,8 @@ func (s *Server) handleHSet(args []string) protocol.Value {
 	// Parse configuration from fields
 	cfg := dispenser.Config{}
 	hasType := false
+	adaptiveSet := false
+	recycleSet := false
 
 	for i := 0; i < len(fields); i += 2 {
 		field := strings.ToLower(fields[i])
@@ -64,6 +79,29 @@ func (s *Server) handleHSet(args []string) protocol.Value {
 			}
 			cfg.Step = step
 
+		case "min":
+			minValue, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || minValue < 0 {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid min value"}
+			}
+			cfg.Min = minValue
+
+		case "max":
+			maxValue, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || maxValue <= 0 {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid max value"}
+			}
+			cfg.Max = maxValue
+
+		case "on_exhausted", "on-exhausted":
+			cfg.OnExhausted = dispenser.ExhaustedPolicy(strings.ToLower(value))
+			switch cfg.OnExhausted {
+			case dispenser.ExhaustedError, dispenser.ExhaustedWrap, dispenser.ExhaustedExtend:
+			default:
+				return protocol.Value{Type: protocol.Error,
+					Str: "ERR invalid on_exhausted value, valid values: error, wrap, extend_length"}
+			}
+
 		case "machine_id", "machine-id":
 			machineID, err := strconv.ParseInt(value, 10, 64)
 			if err != nil {
@@ -78,20 +116,117 @@ func (s *Server) handleHSet(args []string) protocol.Value {
 			}
 			cfg.DatacenterID = datacenterID
 
+		case "epoch":
+			epoch, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid epoch value"}
+			}
+			cfg.Epoch = epoch
+
+		case "timestamp_bits", "timestamp-bits":
+			bits, err := strconv.Atoi(value)
+			if err != nil {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid timestamp_bits value"}
+			}
+			cfg.TimestampBits = bits
+
+		case "datacenter_bits", "datacenter-bits":
+			bits, err := strconv.Atoi(value)
+			if err != nil {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid datacenter_bits value"}
+			}
+			cfg.DatacenterBits = bits
+
+		case "worker_bits", "worker-bits":
+			bits, err := strconv.Atoi(value)
+			if err != nil {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid worker_bits value"}
+			}
+			cfg.WorkerBits = bits
+
+		case "sequence_bits", "sequence-bits":
+			bits, err := strconv.Atoi(value)
+			if err != nil {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid sequence_bits value"}
+			}
+			cfg.SequenceBits = bits
+
+		case "clock_rollback", "clock-rollback":
+			cfg.ClockRollback = dispenser.ClockRollbackPolicy(strings.ToLower(value))
+			if cfg.ClockRollback != dispenser.RollbackWait &&
+				cfg.ClockRollback != dispenser.RollbackBorrow &&
+				cfg.ClockRollback != dispenser.RollbackError {
+				return protocol.Value{Type: protocol.Error,
+					Str: "ERR invalid clock_rollback value, valid values: wait, borrow, error"}
+			}
+
+		case "rollback_wait_ms", "rollback-wait-ms":
+			waitMs, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid rollback_wait_ms value"}
+			}
+			cfg.RollbackWaitMs = waitMs
+
 		case "incr_mode", "incr-mode":
 			cfg.IncrMode = dispenser.IncrementalMode(strings.ToLower(value))
-			if cfg.IncrMode != dispenser.IncrModeFixed && cfg.IncrMode != dispenser.IncrModeSequence {
+			switch cfg.IncrMode {
+			case dispenser.IncrModeFixed, dispenser.IncrModeSequence, dispenser.IncrModePermuted:
+			default:
+				return protocol.Value{Type: protocol.Error,
+					Str: "ERR invalid incr_mode value, valid values: fixed, sequence, permuted"}
+			}
+
+		case "perm_key", "perm-key":
+			cfg.PermKey = strings.ToLower(value)
+
+		case "reset_period", "reset-period":
+			cfg.ResetPeriod = dispenser.ResetPeriod(strings.ToLower(value))
+			switch cfg.ResetPeriod {
+			case dispenser.ResetDaily, dispenser.ResetWeekly, dispenser.ResetMonthly, dispenser.ResetYearly:
+			default:
 				return protocol.Value{Type: protocol.Error,
-					Str: "ERR invalid incr_mode value, valid values: fixed, sequence"}
+					Str: "ERR invalid reset_period value, valid values: daily, weekly, monthly, yearly"}
 			}
 
+		case "format":
+			cfg.Format = value
+
+		case "check_digit", "check-digit":
+			cfg.CheckDigit = dispenser.CheckDigit(strings.ToLower(value))
+			switch cfg.CheckDigit {
+			case dispenser.CheckDigitLuhn, dispenser.CheckDigitDamm, dispenser.CheckDigitVerhoeff, dispenser.CheckDigitMod97:
+			default:
+				return protocol.Value{Type: protocol.Error,
+					Str: "ERR invalid check_digit value, valid values: luhn, damm, verhoeff, mod97"}
+			}
+
+		case "date_format", "date-format":
+			cfg.DateFormat = value
+
+		case "timezone":
+			cfg.Timezone = value
+
 		case "charset":
 			cfg.Charset = dispenser.Charset(strings.ToLower(value))
-			if cfg.Charset != dispenser.CharsetHex && cfg.Charset != dispenser.CharsetBase62 {
+			switch cfg.Charset {
+			case dispenser.CharsetHex, dispenser.CharsetBase62, dispenser.CharsetBase58,
+				dispenser.CharsetCrockford, dispenser.CharsetCustom:
+			default:
+				return protocol.Value{Type: protocol.Error,
+					Str: "ERR invalid charset value, valid values: hex, base62, base58, crockford, custom"}
+			}
+
+		case "rng":
+			cfg.RNG = dispenser.RNG(strings.ToLower(value))
+			if cfg.RNG != dispenser.RNGFast && cfg.RNG != dispenser.RNGSecure {
 				return protocol.Value{Type: protocol.Error,
-					Str: "ERR invalid charset value, valid values: hex, base62"}
+					Str: "ERR invalid rng value, valid values: fast, secure"}
 			}
 
+		case "custom_alphabet", "custom-alphabet":
+			// 字符表区分大小写，原样保存
+			cfg.CustomAlphabet = value
+
 		case "uuid_format", "uuid-format":
 			cfg.UUIDFormat = dispenser.UUIDFormat(strings.ToLower(value))
 			if cfg.UUIDFormat != dispenser.UUIDFormatStandard && cfg.UUIDFormat != dispenser.UUIDFormatCompact {
@@ -99,6 +234,26 @@ func (s *Server) handleHSet(args []string) protocol.Value {
 					Str: "ERR invalid uuid_format value, valid values: standard, compact"}
 			}
 
+		case "uuid_version", "uuid-version":
+			version, err := strconv.Atoi(value)
+			if err != nil {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid uuid_version value"}
+			}
+			switch version {
+			case dispenser.UUIDVersion1, dispenser.UUIDVersion4, dispenser.UUIDVersion6, dispenser.UUIDVersion7:
+			default:
+				return protocol.Value{Type: protocol.Error,
+					Str: "ERR invalid uuid_version value, valid values: 1, 4, 6, 7"}
+			}
+			cfg.UUIDVersion = version
+
+		case "monotonic":
+			monotonic, err := strconv.ParseBool(value)
+			if err != nil {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid monotonic value"}
+			}
+			cfg.Monotonic = monotonic
+
 		case "unique_check", "unique-check":
 			unique, err := strconv.ParseBool(value)
 			if err != nil {
@@ -113,6 +268,28 @@ func (s *Server) handleHSet(args []string) protocol.Value {
 			}
 			cfg.UniqueCacheSize = size
 
+		case "recycle":
+			recycle, err := strconv.ParseBool(value)
+			if err != nil {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid recycle value"}
+			}
+			cfg.Recycle = recycle
+			recycleSet = true
+
+		case "recycle_cooldown", "recycle-cooldown":
+			cooldown, err := parseInterval(value)
+			if err != nil || cooldown < 0 {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid recycle_cooldown value"}
+			}
+			cfg.RecycleCooldown = cooldown
+
+		case "warn_at", "warn-at":
+			warnAt, err := strconv.ParseFloat(value, 64)
+			if err != nil || !(warnAt > 0 && warnAt <= 1) {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid warn_at value, must be between 0 and 1"}
+			}
+			cfg.WarnAt = warnAt
+
 		case "auto_disk", "auto-disk":
 			cfg.AutoDisk = dispenser.PersistenceStrategy(strings.ToLower(value))
 			// 验证策略是否有效
@@ -121,6 +298,56 @@ func (s *Server) handleHSet(args []string) protocol.Value {
 					Str: fmt.Sprintf("ERR invalid auto_disk value '%s', valid values: memory, pre-base, pre-checkpoint, elegant_close, pre_close", value)}
 			}
 
+		case "segment_size", "segment-size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size <= 0 {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid segment_size value"}
+			}
+			cfg.SegmentSize = size
+
+		case "preload_threshold", "preload-threshold":
+			threshold, err := strconv.ParseFloat(value, 64)
+			if err != nil || threshold <= 0 || threshold >= 1 {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid preload_threshold value, must be between 0 and 1"}
+			}
+			cfg.PreloadThreshold = threshold
+
+		case "checkpoint_interval", "checkpoint-interval":
+			interval, err := parseInterval(value)
+			if err != nil || interval <= 0 {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid checkpoint_interval value"}
+			}
+			cfg.CheckpointInterval = interval
+
+		case "adaptive_segment", "adaptive-segment":
+			adaptive, err := strconv.ParseBool(value)
+			if err != nil {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid adaptive_segment value"}
+			}
+			cfg.AdaptiveSegment = adaptive
+			adaptiveSet = true
+
+		case "segment_min_size", "segment-min-size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size <= 0 {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid segment_min_size value"}
+			}
+			cfg.SegmentMinSize = size
+
+		case "segment_max_size", "segment-max-size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size <= 0 {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid segment_max_size value"}
+			}
+			cfg.SegmentMaxSize = size
+
+		case "segment_target", "segment-target":
+			target, err := parseInterval(value)
+			if err != nil || target <= 0 {
+				return protocol.Value{Type: protocol.Error, Str: "ERR invalid segment_target value"}
+			}
+			cfg.SegmentTarget = target
+
 		default:
 			return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR unknown field '%s'", field)}
 		}
@@ -130,18 +357,14 @@ func (s *Server) handleHSet(args []string) protocol.Value {
 		return protocol.Value{Type: protocol.Error, Str: "ERR type field is required"}
 	}
 
-	// 如果没有指定auto_disk，使用默认值 elegant_close
-	if cfg.AutoDisk == "" {
-		cfg.AutoDisk = dispenser.StrategyElegantClose
-	}
-
 	// 检查发号器是否已存在
+	// 持有写锁直到替换完成：读取旧发号器状态之后不能再有号码从旧实例发出
 	s.mu.Lock()
+	defer s.mu.Unlock()
 	existingDispenser, exists := s.dispensers[name]
-	s.mu.Unlock()
 
 	if exists {
-		// 发号器已存在，只允许修改 auto_disk 策略
+		// 发号器已存在，只允许修改 auto_disk 策略、号段参数和回收参数
 		existingCfg := existingDispenser.GetConfig()
 
 		// 检查核心配置是否改变
@@ -166,18 +389,74 @@ func (s *Server) handleHSet(args []string) protocol.Value {
 			changedFields = append(changedFields, "step")
 			configChanged = true
 		}
+		if cfg.Min != 0 && cfg.Min != existingCfg.Min {
+			changedFields = append(changedFields, "min")
+			configChanged = true
+		}
+		if cfg.Max != 0 && cfg.Max != existingCfg.Max {
+			changedFields = append(changedFields, "max")
+			configChanged = true
+		}
+		if cfg.OnExhausted != "" && cfg.OnExhausted != existingCfg.OnExhausted {
+			changedFields = append(changedFields, "on_exhausted")
+			configChanged = true
+		}
 		if cfg.IncrMode != "" && cfg.IncrMode != existingCfg.IncrMode {
 			changedFields = append(changedFields, "incr_mode")
 			configChanged = true
 		}
+		if cfg.PermKey != "" && cfg.PermKey != existingCfg.PermKey {
+			changedFields = append(changedFields, "perm_key")
+			configChanged = true
+		}
+		if cfg.ResetPeriod != "" && cfg.ResetPeriod != existingCfg.ResetPeriod {
+			changedFields = append(changedFields, "reset_period")
+			configChanged = true
+		}
+		if cfg.DateFormat != "" && cfg.DateFormat != existingCfg.DateFormat {
+			changedFields = append(changedFields, "date_format")
+			configChanged = true
+		}
+		if cfg.Timezone != "" && cfg.Timezone != existingCfg.Timezone {
+			changedFields = append(changedFields, "timezone")
+			configChanged = true
+		}
+		if cfg.Format != "" && cfg.Format != existingCfg.Format {
+			changedFields = append(changedFields, "format")
+			configChanged = true
+		}
+		if cfg.CheckDigit != "" && cfg.CheckDigit != existingCfg.CheckDigit {
+			changedFields = append(changedFields, "check_digit")
+			configChanged = true
+		}
 		if cfg.Charset != "" && cfg.Charset != existingCfg.Charset {
 			changedFields = append(changedFields, "charset")
 			configChanged = true
 		}
+		if cfg.UniqueCacheSize != 0 && cfg.UniqueCacheSize != existingCfg.UniqueCacheSize {
+			changedFields = append(changedFields, "unique_cache_size")
+			configChanged = true
+		}
+		if cfg.RNG != "" && cfg.RNG != existingCfg.RNG {
+			changedFields = append(changedFields, "rng")
+			configChanged = true
+		}
+		if cfg.CustomAlphabet != "" && cfg.CustomAlphabet != existingCfg.CustomAlphabet {
+			changedFields = append(changedFields, "custom_alphabet")
+			configChanged = true
+		}
 		if cfg.UUIDFormat != "" && cfg.UUIDFormat != existingCfg.UUIDFormat {
 			changedFields = append(changedFields, "uuid_format")
 			configChanged = true
 		}
+		if cfg.UUIDVersion != 0 && cfg.UUIDVersion != existingCfg.UUIDVersion {
+			changedFields = append(changedFields, "uuid_version")
+			configChanged = true
+		}
+		if cfg.Monotonic && !existingCfg.Monotonic {
+			changedFields = append(changedFields, "monotonic")
+			configChanged = true
+		}
 		if cfg.MachineID != 0 && cfg.MachineID != existingCfg.MachineID {
 			changedFields = append(changedFields, "machine_id")
 			configChanged = true
@@ -187,45 +466,123 @@ func (s *Server) handleHSet(args []string) protocol.Value {
 			configChanged = true
 		}
 
+		if cfg.Epoch != 0 && cfg.Epoch != existingCfg.Epoch {
+			changedFields = append(changedFields, "epoch")
+			configChanged = true
+		}
+		if cfg.TimestampBits != 0 && cfg.TimestampBits != existingCfg.TimestampBits {
+			changedFields = append(changedFields, "timestamp_bits")
+			configChanged = true
+		}
+		if cfg.DatacenterBits != 0 && cfg.DatacenterBits != existingCfg.DatacenterBits {
+			changedFields = append(changedFields, "datacenter_bits")
+			configChanged = true
+		}
+		if cfg.WorkerBits != 0 && cfg.WorkerBits != existingCfg.WorkerBits {
+			changedFields = append(changedFields, "worker_bits")
+			configChanged = true
+		}
+		if cfg.SequenceBits != 0 && cfg.SequenceBits != existingCfg.SequenceBits {
+			changedFields = append(changedFields, "sequence_bits")
+			configChanged = true
+		}
+
+		if cfg.ClockRollback != "" && cfg.ClockRollback != existingCfg.ClockRollback {
+			changedFields = append(changedFields, "clock_rollback")
+			configChanged = true
+		}
+		if cfg.RollbackWaitMs != 0 && cfg.RollbackWaitMs != existingCfg.RollbackWaitMs {
+			changedFields = append(changedFields, "rollback_wait_ms")
+			configChanged = true
+		}
+
 		if configChanged {
 			return protocol.Value{Type: protocol.Error,
-				Str: fmt.Sprintf("ERR cannot change core parameters (%s) for existing dispenser. Only 'auto_disk' can be modified. Use DEL first if you want to recreate",
+				Str: fmt.Sprintf("ERR cannot change core parameters (%s) for existing dispenser. Only 'auto_disk', segment, recycle and warn_at settings can be modified. Use DEL first if you want to recreate",
 					strings.Join(changedFields, ", "))}
 		}
 
-		// 只允许修改 auto_disk
-		if cfg.AutoDisk != existingCfg.AutoDisk {
-			// 需要使用新的策略重新创建发号器
+		// 使用现有配置，只更新 auto_disk、号段、回收和告警参数（未指定的保持不变）
+		newCfg := existingCfg
+		if cfg.AutoDisk != "" {
+			newCfg.AutoDisk = cfg.AutoDisk
+		}
+		if cfg.SegmentSize != 0 {
+			newCfg.SegmentSize = cfg.SegmentSize
+		}
+		if cfg.PreloadThreshold != 0 {
+			newCfg.PreloadThreshold = cfg.PreloadThreshold
+		}
+		if cfg.CheckpointInterval != 0 {
+			newCfg.CheckpointInterval = cfg.CheckpointInterval
+		}
+		if adaptiveSet {
+			newCfg.AdaptiveSegment = cfg.AdaptiveSegment
+		}
+		if cfg.SegmentMinSize != 0 {
+			newCfg.SegmentMinSize = cfg.SegmentMinSize
+		}
+		if cfg.SegmentMaxSize != 0 {
+			newCfg.SegmentMaxSize = cfg.SegmentMaxSize
+		}
+		if cfg.SegmentTarget != 0 {
+			newCfg.SegmentTarget = cfg.SegmentTarget
+		}
+		if recycleSet {
+			newCfg.Recycle = cfg.Recycle
+		}
+		if cfg.RecycleCooldown != 0 {
+			newCfg.RecycleCooldown = cfg.RecycleCooldown
+		}
+		if cfg.WarnAt != 0 {
+			newCfg.WarnAt = cfg.WarnAt
+		}
+
+		if newCfg != existingCfg {
+			// 需要使用新的参数重新创建发号器
 			// 但保留 current 值和统计信息
 			currentValue := existingDispenser.GetCurrent()
 
-			// 使用现有配置，只更新auto_disk
-			newCfg := existingCfg
-			newCfg.AutoDisk = cfg.AutoDisk
-
 			// 创建新的发号器实例
 			d, err := s.factory.CreateDispenser(name, newCfg)
 			if err != nil {
 				return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR %v", err)}
 			}
+			if err := s.attachUsedLog(name, d); err != nil {
+				d.Shutdown()
+				return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR failed to restore issued numbers: %v", err)}
+			}
+			if err := s.carryUsed(name, existingDispenser, d); err != nil {
+				d.Shutdown()
+				return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR failed to carry issued numbers: %v", err)}
+			}
+			if err := s.attachPool(name, d); err != nil {
+				d.Shutdown()
+				return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR failed to restore recycle pool: %v", err)}
+			}
+			if err := s.attachReservations(name, d); err != nil {
+				d.Shutdown()
+				return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR failed to restore reservations: %v", err)}
+			}
 
-			// 恢复 current 值（只对自增类型有效）
-			if newCfg.Type == dispenser.TypeNumericIncremental {
+			// 恢复 current 值（自增类型为当前位置，时钟类型为上次发号时间戳）
+			if newCfg.Type == dispenser.TypeNumericIncremental || newCfg.ClockRollback != "" {
+				if pd, ok := dispenser.As[dispenser.PeriodicDispenser](d); ok {
+					if old, ok := dispenser.As[dispenser.PeriodicDispenser](existingDispenser); ok {
+						pd.SetPeriod(old.GetPeriod())
+					}
+				}
 				d.SetCurrent(currentValue)
 			}
 
-			// 替换发号器
-			s.mu.Lock()
-			// 关闭旧的发号器
+			// 关闭旧的发号器并替换
 			if err := existingDispenser.Shutdown(); err != nil {
-				s.mu.Unlock()
 				return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR failed to shutdown old dispenser: %v", err)}
 			}
 			s.dispensers[name] = d
-			s.mu.Unlock()
 
 			// 保存
-			if err := s.storage.Save(name, newCfg, d.GetCurrent()); err != nil {
+			if err := s.saveDispenser(name, newCfg, d); err != nil {
 				return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR failed to save: %v", err)}
 			}
 
@@ -236,36 +593,77 @@ func (s *Server) handleHSet(args []string) protocol.Value {
 		return protocol.Value{Type: protocol.Integer, Num: int64(len(fields) / 2)}
 	}
 
+	// 如果没有指定auto_disk，使用默认值 elegant_close
+	if cfg.AutoDisk == "" {
+		cfg.AutoDisk = dispenser.StrategyElegantClose
+	}
+
+	// permuted 模式未指定密钥时自动生成，随配置一起持久化
+	if cfg.IncrMode == dispenser.IncrModePermuted && cfg.PermKey == "" {
+		key, err := dispenser.GeneratePermKey()
+		if err != nil {
+			return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR failed to generate perm_key: %v", err)}
+		}
+		cfg.PermKey = key
+	}
+
 	// 发号器不存在，创建新的
 	d, err := s.factory.CreateDispenser(name, cfg)
 	if err != nil {
 		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR %v", err)}
 	}
+	if err := s.attachUsedLog(name, d); err != nil {
+		d.Shutdown()
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR failed to restore issued numbers: %v", err)}
+	}
+	if err := s.attachPool(name, d); err != nil {
+		d.Shutdown()
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR failed to restore recycle pool: %v", err)}
+	}
+	if err := s.attachReservations(name, d); err != nil {
+		d.Shutdown()
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR failed to restore reservations: %v", err)}
+	}
 
 	// Save to storage
-	s.mu.Lock()
 	s.dispensers[name] = d
-	s.mu.Unlock()
 
-	if err := s.storage.Save(name, cfg, d.GetCurrent()); err != nil {
+	if err := s.saveDispenser(name, cfg, d); err != nil {
 		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR failed to save: %v", err)}
 	}
 
 	return protocol.Value{Type: protocol.Integer, Num: int64(len(fields) / 2)}
 }
 
+// parseInterval 解析时间间隔，支持 Go duration 格式（如 "500ms"、"2s"），纯数字按毫秒处理
+func parseInterval(value string) (time.Duration, error) {
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// maxBatchCount 单次批量取号的最大数量
+const maxBatchCount = 10000
+
 // handleGet handles the GET command to generate a new number
-// Format: GET key
+// Format: GET key [COUNT n]
+// 单个取号时使用率达到 warn_at 后返回 [号码, 告警]
 func (s *Server) handleGet(args []string) protocol.Value {
+	if len(args) == 3 && strings.ToUpper(args[1]) == "COUNT" {
+		return s.handleGetN([]string{args[0], args[2]})
+	}
+
 	if len(args) != 1 {
 		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'get' command"}
 	}
 
 	name := args[0]
 
+	// 发号期间持有读锁，HSET 重建发号器时不会有号码从旧实例发出
 	s.mu.RLock()
+	defer s.mu.RUnlock()
 	d, exists := s.dispensers[name]
-	s.mu.RUnlock()
 
 	if !exists {
 		return protocol.Value{Type: protocol.Error, Str: "ERR dispenser not found"}
@@ -276,56 +674,135 @@ func (s *Server) handleGet(args []string) protocol.Value {
 		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR %v", err)}
 	}
 
-	// 根据持久化策略决定是否立即保存
+	s.persistIssued(name, d)
+
+	// 设置了 warn_at 时每次发号都检查容量，达到阈值后回复附带告警
+	if cfg := d.GetConfig(); cfg.WarnAt > 0 {
+		if f, ok := s.forecast(name, d); ok && warnReached(cfg, f) {
+			return protocol.Value{Type: protocol.Array, Array: []protocol.Value{
+				{Type: protocol.BulkString, Bulk: number},
+				{Type: protocol.BulkString, Bulk: capacityWarning(f)},
+			}}
+		}
+		return protocol.Value{Type: protocol.BulkString, Bulk: number}
+	}
+	s.observeIssued(name, d)
+
+	return protocol.Value{Type: protocol.BulkString, Bulk: number}
+}
+
+// handleGetN handles the GETN command to generate a batch of numbers
+// Format: GETN key count
+func (s *Server) handleGetN(args []string) protocol.Value {
+	if len(args) != 2 {
+		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'getn' command"}
+	}
+
+	name := args[0]
+	count, err := strconv.Atoi(args[1])
+	if err != nil || count <= 0 {
+		return protocol.Value{Type: protocol.Error, Str: "ERR count must be a positive integer"}
+	}
+	if count > maxBatchCount {
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR count exceeds maximum of %d", maxBatchCount)}
+	}
+
+	// 发号期间持有读锁，HSET 重建发号器时不会有号码从旧实例发出
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, exists := s.dispensers[name]
+
+	if !exists {
+		return protocol.Value{Type: protocol.Error, Str: "ERR dispenser not found"}
+	}
+
+	numbers, err := d.NextN(count)
+	if err != nil {
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR %v", err)}
+	}
+
+	// 整批只持久化一次
+	s.persistIssued(name, d)
+	s.observeIssued(name, d)
+
+	result := make([]protocol.Value, len(numbers))
+	for i, number := range numbers {
+		result[i] = protocol.Value{Type: protocol.BulkString, Bulk: number}
+	}
+
+	return protocol.Value{Type: protocol.Array, Array: result}
+}
+
+// persistIssued 根据持久化策略决定发号后是否立即保存
+func (s *Server) persistIssued(name string, d dispenser.NumberDispenser) {
 	cfg := d.GetConfig()
 
 	// 只有 elegant_close 策略需要立即保存
 	if cfg.AutoDisk == dispenser.StrategyElegantClose {
-		// 只对自增类型立即保存
-		if cfg.Type == dispenser.TypeNumericIncremental {
-			if err := s.storage.Save(name, cfg, d.GetCurrent()); err != nil {
+		// 自增类型保存当前值；毫秒时钟类型保存上次发号的时间戳，
+		// 否则崩溃后时钟回拨到未写盘的时间戳之前会重复发号
+		if cfg.Type == dispenser.TypeNumericIncremental || dispenser.UsesMillisClock(cfg) {
+			if err := s.saveDispenser(name, cfg, d); err != nil {
 				// 记录错误但继续返回
 			}
 		}
 	}
-	// 其他策略（pre-base, pre-checkpoint, pre_close）有自己的持久化机制
+	// 其他策略（pre-base, pre-checkpoint, pre_close）有自己的持久化机制，且只用于 Type 2，
+	// 毫秒时钟类型只能使用 elegant_close 或 memory（工厂拒绝号段策略）
 	// memory 策略不需要持久化
-
-	return protocol.Value{Type: protocol.BulkString, Bulk: number}
 }
 
-// handleDel handles the DEL command to delete a dispenser
-// Format: DEL key
-func (s *Server) handleDel(args []string) protocol.Value {
-	if len(args) != 1 {
-		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'del' command"}
+// handleAllocSeg handles the ALLOCSEG command to lease a number segment
+// Format: ALLOCSEG key size
+// 返回 [start, end, step]，客户端可在 [start, end) 内按 step 自行发号
+func (s *Server) handleAllocSeg(args []string) protocol.Value {
+	if len(args) != 2 {
+		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'allocseg' command"}
 	}
 
 	name := args[0]
-
-	s.mu.Lock()
-	_, exists := s.dispensers[name]
-	if exists {
-		delete(s.dispensers, name)
+	size, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || size <= 0 {
+		return protocol.Value{Type: protocol.Error, Str: "ERR size must be a positive integer"}
 	}
-	s.mu.Unlock()
+
+	// 发号期间持有读锁，HSET 重建发号器时不会有号码从旧实例发出
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, exists := s.dispensers[name]
 
 	if !exists {
-		return protocol.Value{Type: protocol.Integer, Num: 0}
+		return protocol.Value{Type: protocol.Error, Str: "ERR dispenser not found"}
 	}
 
-	if err := s.storage.Delete(name); err != nil {
-		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR failed to delete: %v", err)}
+	start, end, err := d.AllocateSegment(size)
+	if err != nil {
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR %v", err)}
 	}
 
-	return protocol.Value{Type: protocol.Integer, Num: 1}
+	// 租约必须在回复前落盘，保证重启后不会再次分配同一号段
+	cfg := d.GetConfig()
+	if err := s.saveDispenser(name, cfg, d); err != nil {
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR failed to save: %v", err)}
+	}
+	if err := s.flushStorage(); err != nil {
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR failed to flush: %v", err)}
+	}
+	s.observeIssued(name, d)
+
+	return protocol.Value{Type: protocol.Array, Array: []protocol.Value{
+		{Type: protocol.Integer, Num: start},
+		{Type: protocol.Integer, Num: end},
+		{Type: protocol.Integer, Num: cfg.Step},
+	}}
 }
 
-// handleInfo handles the INFO command to get dispenser information
-// Format: INFO key
-func (s *Server) handleInfo(args []string) protocol.Value {
-	if len(args) != 1 {
-		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'info' command"}
+// handleDecode handles the DECODE command to split an id into its parts
+// Format: DECODE key id
+// 返回 field/value 交替的数组（与 HGETALL 相同的形式）
+func (s *Server) handleDecode(args []string) protocol.Value {
+	if len(args) != 2 {
+		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'decode' command"}
 	}
 
 	name := args[0]
@@ -338,48 +815,1015 @@ func (s *Server) handleInfo(args []string) protocol.Value {
 		return protocol.Value{Type: protocol.Error, Str: "ERR dispenser not found"}
 	}
 
-	cfg := d.GetConfig()
-	current := d.GetCurrent()
-
-	// 获取统计信息
-	stats := d.GetStats()
+	// 配置了格式模板时先取出原始值
+	id := args[1]
+	if fd, ok := d.(*dispenser.FormattedDispenser); ok {
+		value, err := fd.ExtractValue(id)
+		if err != nil {
+			return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR %v", err)}
+		}
+		id = value
+	}
 
-	// 根据类型显示不同的信息
-	var info string
-	switch cfg.Type {
-	case dispenser.TypeNumericRandom:
-		// Type 1: 纯数字随机
-		info = fmt.Sprintf("name:%s\ntype:1 (Numeric Random)\nlength:%d\nunique_check:%v\nauto_disk:%s\ngenerated:%d",
-			name, cfg.Length, cfg.UniqueCheck, cfg.AutoDisk, stats.TotalGenerated)
+	decoded, err := dispenser.Decode(d.GetConfig(), id)
+	if err != nil {
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR %v", err)}
+	}
 
-	case dispenser.TypeNumericIncremental:
-		// Type 2: 纯数字自增
-		if cfg.IncrMode == dispenser.IncrModeFixed {
-			info = fmt.Sprintf("name:%s\ntype:2 (Numeric Incremental)\nmode:fixed\nlength:%d\nstarting:%d\nstep:%d\ncurrent:%d\nauto_disk:%s\ngenerated:%d\nwasted:%d\nwaste_rate:%.2f%%",
-				name, cfg.Length, cfg.Starting, cfg.Step, current, cfg.AutoDisk, stats.TotalGenerated, stats.TotalWasted, stats.WasteRate)
-		} else {
-			info = fmt.Sprintf("name:%s\ntype:2 (Numeric Incremental)\nmode:sequence\nstarting:%d\nstep:%d\ncurrent:%d\nauto_disk:%s\ngenerated:%d\nwasted:%d\nwaste_rate:%.2f%%",
-				name, cfg.Starting, cfg.Step, current, cfg.AutoDisk, stats.TotalGenerated, stats.TotalWasted, stats.WasteRate)
+	var fields []string
+	if decoded.Type == dispenser.TypeNumericIncremental {
+		// permuted 模式：还原置换前的计数器
+		fields = []string{"counter", strconv.FormatInt(decoded.Counter, 10)}
+	} else {
+		fields = []string{
+			"timestamp", strconv.FormatInt(decoded.Timestamp, 10),
+			"time", time.UnixMilli(decoded.Timestamp).UTC().Format("2006-01-02T15:04:05.000Z07:00"),
 		}
+	}
 
-	case dispenser.TypeAlphanumericRandom:
-		// Type 3: 字符随机
-		info = fmt.Sprintf("name:%s\ntype:3 (Alphanumeric Random)\nlength:%d\ncharset:%s\nauto_disk:%s\ngenerated:%d",
-			name, cfg.Length, cfg.Charset, cfg.AutoDisk, stats.TotalGenerated)
-
+	switch decoded.Type {
 	case dispenser.TypeSnowflake:
-		// Type 4: 雪花ID
-		info = fmt.Sprintf("name:%s\ntype:4 (Snowflake)\nmachine_id:%d\ndatacenter_id:%d\nauto_disk:%s\ngenerated:%d",
-			name, cfg.MachineID, cfg.DatacenterID, cfg.AutoDisk, stats.TotalGenerated)
+		fields = append(fields,
+			"datacenter_id", strconv.FormatInt(decoded.DatacenterID, 10),
+			"machine_id", strconv.FormatInt(decoded.MachineID, 10),
+			"sequence", strconv.FormatInt(decoded.Sequence, 10))
 
 	case dispenser.TypeUUID:
-		// Type 5: UUID
-		info = fmt.Sprintf("name:%s\ntype:5 (UUID)\nformat:%s\nauto_disk:%s\ngenerated:%d",
-			name, cfg.UUIDFormat, cfg.AutoDisk, stats.TotalGenerated)
+		fields = append(fields, "version", strconv.Itoa(decoded.Version))
+		if decoded.Version == dispenser.UUIDVersion7 {
+			fields = append(fields, "sequence", strconv.FormatInt(decoded.Sequence, 10))
+		} else {
+			fields = append(fields,
+				"clock_seq", strconv.FormatInt(decoded.ClockSeq, 10),
+				"datacenter_id", strconv.FormatInt(decoded.DatacenterID, 10),
+				"machine_id", strconv.FormatInt(decoded.MachineID, 10))
+		}
+	}
 
-	default:
-		info = fmt.Sprintf("name:%s\ntype:%d (Unknown)", name, cfg.Type)
+	result := make([]protocol.Value, len(fields))
+	for i, field := range fields {
+		result[i] = protocol.Value{Type: protocol.BulkString, Bulk: field}
+	}
+
+	return protocol.Value{Type: protocol.Array, Array: result}
+}
+
+// handleValidate handles the VALIDATE command to check a value against a dispenser
+// Format: VALIDATE key value
+// 校验格式、长度、取值范围和校验码，有效返回 1，否则返回 0
+func (s *Server) handleValidate(args []string) protocol.Value {
+	if len(args) != 2 {
+		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'validate' command"}
 	}
 
-	return protocol.Value{Type: protocol.BulkString, Bulk: info}
+	name := args[0]
+
+	s.mu.RLock()
+	d, exists := s.dispensers[name]
+	s.mu.RUnlock()
+
+	if !exists {
+		return protocol.Value{Type: protocol.Error, Str: "ERR dispenser not found"}
+	}
+
+	if err := dispenser.ValidateID(d, args[1]); err != nil {
+		return protocol.Value{Type: protocol.Integer, Num: 0}
+	}
+	return protocol.Value{Type: protocol.Integer, Num: 1}
+}
+
+// handleRelease handles the RELEASE command to return issued numbers to the recycle pool
+// Format: RELEASE key value [value ...]
+// 整批校验通过后才放入回收池，返回归还的号码数
+func (s *Server) handleRelease(args []string) protocol.Value {
+	if len(args) < 2 {
+		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'release' command"}
+	}
+
+	name := args[0]
+
+	// 发号期间持有读锁，HSET 重建发号器时不会有号码从旧实例发出
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, exists := s.dispensers[name]
+
+	if !exists {
+		return protocol.Value{Type: protocol.Error, Str: "ERR dispenser not found"}
+	}
+
+	rd, ok := dispenser.As[dispenser.RecyclableDispenser](d)
+	if !ok {
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR %v", dispenser.ErrRecycleDisabled)}
+	}
+	if err := rd.Release(args[1:]); err != nil {
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR %v", err)}
+	}
+
+	return protocol.Value{Type: protocol.Integer, Num: int64(len(args) - 1)}
+}
+
+// handleReserve handles the RESERVE command to keep values out of GET
+// Format: RESERVE key value|start-end [value|start-end ...]
+// 返回新增保留的号码数
+func (s *Server) handleReserve(args []string) protocol.Value {
+	if len(args) < 2 {
+		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'reserve' command"}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rd, errVal := s.reservableDispenser(args[0])
+	if rd == nil {
+		return errVal
+	}
+	ranges, err := parseReservedRanges(args[1:])
+	if err != nil {
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR %v", err)}
+	}
+
+	added, err := rd.Reserve(ranges)
+	if err != nil {
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR %v", err)}
+	}
+	return protocol.Value{Type: protocol.Integer, Num: added}
+}
+
+// handleUnreserve handles the UNRESERVE command to release reserved values
+// Format: UNRESERVE key value|start-end [value|start-end ...]
+// 返回实际解除保留的号码数
+func (s *Server) handleUnreserve(args []string) protocol.Value {
+	if len(args) < 2 {
+		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'unreserve' command"}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rd, errVal := s.reservableDispenser(args[0])
+	if rd == nil {
+		return errVal
+	}
+	ranges, err := parseReservedRanges(args[1:])
+	if err != nil {
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR %v", err)}
+	}
+
+	removed, err := rd.Unreserve(ranges)
+	if err != nil {
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR %v", err)}
+	}
+	return protocol.Value{Type: protocol.Integer, Num: removed}
+}
+
+// handleClaim handles the CLAIM command to issue a reserved value
+// Format: CLAIM key value
+func (s *Server) handleClaim(args []string) protocol.Value {
+	if len(args) != 2 {
+		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'claim' command"}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rd, errVal := s.reservableDispenser(args[0])
+	if rd == nil {
+		return errVal
+	}
+	v, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || v < 0 {
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR invalid value '%s'", args[1])}
+	}
+
+	number, err := rd.Claim(v)
+	if err != nil {
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR %v", err)}
+	}
+	return protocol.Value{Type: protocol.BulkString, Bulk: number}
+}
+
+// handleReservations handles the RESERVATIONS command to list reserved values
+// Format: RESERVATIONS key [CLAIMED]
+// 返回保留中的号码区间，指定 CLAIMED 时返回已认领的号码区间
+func (s *Server) handleReservations(args []string) protocol.Value {
+	if len(args) != 1 && len(args) != 2 {
+		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'reservations' command"}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rd, errVal := s.reservableDispenser(args[0])
+	if rd == nil {
+		return errVal
+	}
+
+	state := rd.Reservations()
+	ranges := state.Reserved
+	if len(args) == 2 {
+		if !strings.EqualFold(args[1], "CLAIMED") {
+			return protocol.Value{Type: protocol.Error, Str: "ERR syntax error"}
+		}
+		ranges = state.Claimed
+	}
+
+	result := make([]protocol.Value, len(ranges))
+	for i, rng := range ranges {
+		result[i] = protocol.Value{Type: protocol.BulkString, Bulk: rng.String()}
+	}
+	return protocol.Value{Type: protocol.Array, Array: result}
+}
+
+// reservableDispenser 查找发号器，不存在时返回错误响应（调用方需持有 s.mu 读锁）
+func (s *Server) reservableDispenser(name string) (dispenser.ReservableDispenser, protocol.Value) {
+	d, exists := s.dispensers[name]
+
+	if !exists {
+		return nil, protocol.Value{Type: protocol.Error, Str: "ERR dispenser not found"}
+	}
+	rd, ok := dispenser.As[dispenser.ReservableDispenser](d)
+	if !ok {
+		return nil, protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR %v", dispenser.ErrNotReservable)}
+	}
+	return rd, protocol.Value{}
+}
+
+// parseReservedRanges 解析号码或号码区间，如 8888、1000-1999
+func parseReservedRanges(args []string) ([]dispenser.ReservedRange, error) {
+	ranges := make([]dispenser.ReservedRange, 0, len(args))
+	for _, arg := range args {
+		startStr, endStr, isRange := strings.Cut(arg, "-")
+		start, err := strconv.ParseInt(startStr, 10, 64)
+		end := start
+		if err == nil && isRange {
+			end, err = strconv.ParseInt(endStr, 10, 64)
+		}
+		if err != nil || start < 0 || end < start {
+			return nil, fmt.Errorf("invalid value or range '%s'", arg)
+		}
+		ranges = append(ranges, dispenser.ReservedRange{Start: start, End: end})
+	}
+	return ranges, nil
+}
+
+// handleDel handles the DEL command to delete a dispenser
+// Format: DEL key
+func (s *Server) handleDel(args []string) protocol.Value {
+	if len(args) != 1 {
+		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'del' command"}
+	}
+
+	name := args[0]
+
+	s.mu.Lock()
+	_, exists := s.dispensers[name]
+	if exists {
+		delete(s.dispensers, name)
+	}
+	s.mu.Unlock()
+
+	if !exists {
+		return protocol.Value{Type: protocol.Integer, Num: 0}
+	}
+	s.forgetWatch(name)
+
+	if err := s.storage.Delete(name); err != nil {
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR failed to delete: %v", err)}
+	}
+
+	return protocol.Value{Type: protocol.Integer, Num: 1}
+}
+
+// handleInfo handles the INFO command
+// Format: INFO [name | section [section ...]]
+// INFO <name> 返回发号器信息；不带参数或参数为 section 名称时返回 Redis 风格的服务端信息
+// 参数既是发号器名称又是 section 名称时按发号器处理
+func (s *Server) handleInfo(args []string) protocol.Value {
+	if len(args) == 1 {
+		s.mu.RLock()
+		d, exists := s.dispensers[args[0]]
+		s.mu.RUnlock()
+
+		if exists {
+			return protocol.Value{Type: protocol.BulkString, Bulk: s.dispenserInfo(args[0], d).text("\n")}
+		}
+		if !isInfoSection(args[0]) {
+			return protocol.Value{Type: protocol.Error, Str: "ERR dispenser not found"}
+		}
+	}
+
+	return s.serverInfo(args)
+}
+
+// handleHGetAll handles the HGETALL command
+// Format: HGETALL name
+// 返回与 INFO <name> 相同的字段，格式为 [field1, value1, field2, value2, ...]
+func (s *Server) handleHGetAll(args []string) protocol.Value {
+	if len(args) != 1 {
+		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'hgetall' command"}
+	}
+
+	name := args[0]
+
+	s.mu.RLock()
+	d, exists := s.dispensers[name]
+	s.mu.RUnlock()
+
+	if !exists {
+		return protocol.Value{Type: protocol.Error, Str: "ERR dispenser not found"}
+	}
+
+	return s.dispenserInfo(name, d).array()
+}
+
+// infoField INFO 中的一个字段
+type infoField struct {
+	key   string
+	value string
+}
+
+// infoFields 按输出顺序排列的 INFO 字段
+type infoFields []infoField
+
+// add 追加一个字段
+func (f *infoFields) add(key, format string, args ...any) {
+	*f = append(*f, infoField{key: key, value: fmt.Sprintf(format, args...)})
+}
+
+// text 返回以 sep 分隔的 key:value 文本
+func (f infoFields) text(sep string) string {
+	lines := make([]string, len(f))
+	for i, field := range f {
+		lines[i] = field.key + ":" + field.value
+	}
+	return strings.Join(lines, sep)
+}
+
+// array 返回 [key1, value1, key2, value2, ...] 形式的数组
+func (f infoFields) array() protocol.Value {
+	result := make([]protocol.Value, 0, 2*len(f))
+	for _, field := range f {
+		result = append(result,
+			protocol.Value{Type: protocol.BulkString, Bulk: field.key},
+			protocol.Value{Type: protocol.BulkString, Bulk: field.value})
+	}
+	return protocol.Value{Type: protocol.Array, Array: result}
+}
+
+// dispenserInfo 返回发号器的 INFO 字段，不同类型的字段不同
+func (s *Server) dispenserInfo(name string, d dispenser.NumberDispenser) infoFields {
+	cfg := d.GetConfig()
+	current := d.GetCurrent()
+
+	// 获取统计信息
+	stats := d.GetStats()
+
+	var info infoFields
+	info.add("name", "%s", name)
+
+	// 根据类型显示不同的信息
+	switch cfg.Type {
+	case dispenser.TypeNumericRandom:
+		// Type 1: 纯数字随机
+		info.add("type", "1 (Numeric Random)")
+		info.add("length", "%d", cfg.Length)
+		info.add("unique_check", "%v", cfg.UniqueCheck)
+		info.add("unique_cache_size", "%d", cfg.UniqueCacheSize)
+		info.add("unique_tracked", "%d", stats.UniqueTracked)
+		info.add("unique_memory", "%d", stats.UniqueMemory)
+		info.add("rng", "%s", cfg.RNG)
+		info.add("auto_disk", "%s", cfg.AutoDisk)
+		info.add("generated", "%d", stats.TotalGenerated)
+
+	case dispenser.TypeNumericIncremental:
+		// Type 2: 纯数字自增
+		info.add("type", "2 (Numeric Incremental)")
+		if cfg.IncrMode == dispenser.IncrModeFixed || cfg.IncrMode == dispenser.IncrModePermuted {
+			info.add("mode", "%s", cfg.IncrMode)
+			info.add("length", "%d", cfg.Length)
+		} else {
+			info.add("mode", "sequence")
+		}
+		info.add("starting", "%d", cfg.Starting)
+		info.add("step", "%d", cfg.Step)
+		info.add("current", "%d", current)
+		info.add("auto_disk", "%s", cfg.AutoDisk)
+		info.add("generated", "%d", stats.TotalGenerated)
+		info.add("wasted", "%d", stats.TotalWasted)
+		info.add("waste_rate", "%.2f%%", stats.WasteRate)
+
+	case dispenser.TypeAlphanumericRandom:
+		// Type 3: 字符随机
+		info.add("type", "3 (Alphanumeric Random)")
+		info.add("length", "%d", cfg.Length)
+		info.add("charset", "%s", cfg.Charset)
+		info.add("rng", "%s", cfg.RNG)
+		info.add("auto_disk", "%s", cfg.AutoDisk)
+		info.add("generated", "%d", stats.TotalGenerated)
+		if cfg.Charset == dispenser.CharsetCustom {
+			info.add("custom_alphabet", "%s", cfg.CustomAlphabet)
+		}
+
+	case dispenser.TypeSnowflake:
+		// Type 4: 雪花ID
+		info.add("type", "4 (Snowflake)")
+		info.add("machine_id", "%d", cfg.MachineID)
+		info.add("datacenter_id", "%d", cfg.DatacenterID)
+		info.add("epoch", "%d", cfg.Epoch)
+		info.add("layout", "%d/%d/%d/%d (timestamp/datacenter/worker/sequence)",
+			cfg.TimestampBits, cfg.DatacenterBits, cfg.WorkerBits, cfg.SequenceBits)
+		info.add("auto_disk", "%s", cfg.AutoDisk)
+		info.add("generated", "%d", stats.TotalGenerated)
+
+	case dispenser.TypeUUID:
+		// Type 5: UUID
+		info.add("type", "5 (UUID)")
+		info.add("version", "%d", cfg.UUIDVersion)
+		info.add("format", "%s", cfg.UUIDFormat)
+		info.add("auto_disk", "%s", cfg.AutoDisk)
+		info.add("generated", "%d", stats.TotalGenerated)
+
+	case dispenser.TypeULID:
+		// Type 6: ULID
+		info.add("type", "6 (ULID)")
+		info.add("monotonic", "%v", cfg.Monotonic)
+		info.add("auto_disk", "%s", cfg.AutoDisk)
+		info.add("generated", "%d", stats.TotalGenerated)
+
+	default:
+		info.add("type", "%d (Unknown)", cfg.Type)
+	}
+
+	// 计数器范围（Type 2）
+	if cfg.Min != 0 || cfg.Max != 0 || cfg.OnExhausted != "" {
+		onExhausted := cfg.OnExhausted
+		if onExhausted == "" {
+			onExhausted = dispenser.ExhaustedError
+		}
+		info.add("min", "%d", cfg.Min)
+		info.add("max", "%d", cfg.Max)
+		info.add("on_exhausted", "%s", onExhausted)
+	}
+
+	// 格式模板
+	if cfg.Format != "" {
+		info.add("format", "%s", cfg.Format)
+	}
+
+	// 校验码（Type 1, 2）
+	if cfg.CheckDigit != "" {
+		info.add("check_digit", "%s", cfg.CheckDigit)
+	}
+
+	// 号码回收（Type 1, 2）
+	if cfg.Recycle {
+		info.add("recycle", "true")
+		info.add("recycle_cooldown", "%s", cfg.RecycleCooldown)
+		info.add("recycle_pool", "%d", stats.PoolSize)
+		info.add("recycle_ready", "%d", stats.PoolReady)
+	}
+
+	// 保留号码（Type 1, 2）
+	if stats.Reserved > 0 || stats.Claimed > 0 {
+		info.add("reserved", "%d", stats.Reserved)
+		info.add("claimed", "%d", stats.Claimed)
+	}
+
+	// 剩余容量和预计耗尽时间（号码空间有限的 Type 1, 2）
+	if f, ok := s.forecast(name, d); ok {
+		info.add("capacity", "%d", f.Total)
+		info.add("remaining", "%d", f.Remaining)
+		info.add("utilization", "%.2f%%", f.Utilization()*100)
+		info.add("issue_rate", "%.2f/s", f.Rate)
+		info.add("exhausts_in", "%s", exhaustsIn(f))
+		if cfg.WarnAt > 0 {
+			info.add("warn_at", "%g", cfg.WarnAt)
+			info.add("capacity_warning", "%v", warnReached(cfg, f))
+		}
+	}
+
+	// 周期重置（Type 2）附加周期信息
+	if cfg.ResetPeriod != "" {
+		timezone := cfg.Timezone
+		if timezone == "" {
+			timezone = "Local"
+		}
+		info.add("reset_period", "%s", cfg.ResetPeriod)
+		info.add("date_format", "%s", cfg.DateFormat)
+		info.add("timezone", "%s", timezone)
+		if pd, ok := dispenser.As[dispenser.PeriodicDispenser](d); ok {
+			info.add("period", "%s", pd.GetPeriod())
+		}
+	}
+
+	// 时钟类型（Type 4、5 v7、6）附加回拨策略和统计
+	if cfg.ClockRollback != "" {
+		info.add("clock_rollback", "%s", cfg.ClockRollback)
+		info.add("rollback_wait_ms", "%d", cfg.RollbackWaitMs)
+		info.add("clock_rollbacks", "%d", stats.ClockRollbacks)
+		info.add("clock_rollback_errors", "%d", stats.ClockRollbackErrors)
+		info.add("max_clock_rollback_ms", "%d", stats.MaxClockRollback)
+	}
+
+	// 号段策略附加实际生效的号段参数
+	if stats.SegmentSize > 0 {
+		info.add("segment_size", "%d", stats.SegmentSize)
+		info.add("preload_threshold", "%g", stats.PreloadThreshold)
+		if stats.CheckpointInterval > 0 {
+			info.add("checkpoint_interval", "%s", stats.CheckpointInterval)
+		}
+		if stats.AdaptiveSegment {
+			info.add("adaptive_segment", "true")
+			info.add("segment_min_size", "%d", stats.SegmentMinSize)
+			info.add("segment_max_size", "%d", stats.SegmentMaxSize)
+			info.add("segment_target", "%s", stats.SegmentTarget)
+		}
+		info.add("consumption_rate", "%.2f/s", stats.ConsumptionRate)
+	}
+
+	return info
+}
+
+// ============================================
+// INFO 服务端信息
+// ============================================
+
+// infoSection INFO 的一个 section，字段名与 Redis 相同的部分可以直接被 Redis 监控工具识别
+type infoSection struct {
+	name     string
+	title    string
+	defaults bool // 不带参数的 INFO 是否包含此 section
+	build    func(s *Server) infoFields
+}
+
+// infoSections 按输出顺序排列
+var infoSections = []infoSection{
+	{name: "server", title: "Server", defaults: true, build: (*Server).infoServer},
+	{name: "clients", title: "Clients", defaults: true, build: (*Server).infoClients},
+	{name: "persistence", title: "Persistence", defaults: true, build: (*Server).infoPersistence},
+	{name: "stats", title: "Stats", defaults: true, build: (*Server).infoStats},
+	{name: "commandstats", title: "Commandstats", build: (*Server).infoCommandstats},
+	{name: "dispensers", title: "Dispensers", defaults: true, build: (*Server).infoDispensers},
+	{name: "keyspace", title: "Keyspace", defaults: true, build: (*Server).infoKeyspace},
+}
+
+// isInfoSection 检查参数是否为 section 名称（包括 all、everything、default）
+func isInfoSection(arg string) bool {
+	arg = strings.ToLower(arg)
+	if arg == "all" || arg == "everything" || arg == "default" {
+		return true
+	}
+	for _, sec := range infoSections {
+		if sec.name == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// serverInfo 返回选中 section 的服务端信息，格式与 Redis INFO 相同
+// 不带参数时返回默认 section，all 和 everything 返回全部 section
+func (s *Server) serverInfo(args []string) protocol.Value {
+	selected := make(map[string]bool)
+	if len(args) == 0 {
+		args = []string{"default"}
+	}
+	for _, arg := range args {
+		arg = strings.ToLower(arg)
+		if !isInfoSection(arg) {
+			return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR unknown INFO section '%s'", arg)}
+		}
+		for _, sec := range infoSections {
+			switch arg {
+			case "all", "everything":
+				selected[sec.name] = true
+			case "default":
+				selected[sec.name] = selected[sec.name] || sec.defaults
+			default:
+				selected[sec.name] = selected[sec.name] || sec.name == arg
+			}
+		}
+	}
+
+	var b strings.Builder
+	for _, sec := range infoSections {
+		if !selected[sec.name] {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString("# " + sec.title + "\r\n")
+		if fields := sec.build(s); len(fields) > 0 {
+			b.WriteString(fields.text("\r\n") + "\r\n")
+		}
+	}
+
+	return protocol.Value{Type: protocol.BulkString, Bulk: b.String()}
+}
+
+// redisVersion INFO server 中报告的 Redis 版本
+// 部分监控工具根据版本号决定读取哪些字段，这里按 Redis 7 的 INFO 格式输出
+const redisVersion = "7.0.0"
+
+func (s *Server) infoServer() infoFields {
+	var uptime time.Duration
+	if !s.startTime.IsZero() {
+		uptime = time.Since(s.startTime)
+	}
+
+	addr := s.addr
+	if s.listener != nil {
+		addr = s.listener.Addr().String()
+	}
+	port := "0"
+	if _, p, err := net.SplitHostPort(addr); err == nil {
+		port = p
+	}
+
+	var info infoFields
+	info.add("redis_version", redisVersion)
+	info.add("redis_mode", "standalone")
+	info.add("go_version", "%s", runtime.Version())
+	info.add("os", "%s", runtime.GOOS)
+	info.add("arch_bits", "%d", strconv.IntSize)
+	info.add("process_id", "%d", os.Getpid())
+	info.add("tcp_port", "%s", port)
+	info.add("node_id", "%s", s.nodeID)
+	info.add("server_time_usec", "%d", time.Now().UnixMicro())
+	info.add("uptime_in_seconds", "%d", int64(uptime.Seconds()))
+	info.add("uptime_in_days", "%d", int64(uptime.Hours()/24))
+	return info
+}
+
+func (s *Server) infoClients() infoFields {
+	var info infoFields
+	info.add("connected_clients", "%d", s.stats.connected.Load())
+	return info
+}
+
+// infoPersistence 主数据文件的写盘状态（去重日志、回收池、保留号码的文件在变化时立即写入，不在此统计）
+func (s *Server) infoPersistence() infoFields {
+	autoSave := 0
+	if s.storageConfig.AutoSave {
+		autoSave = 1
+	}
+
+	var info infoFields
+	info.add("loading", "0")
+	info.add("data_dir", "%s", s.storageConfig.DataDir)
+	info.add("auto_save", "%d", autoSave)
+	info.add("auto_save_interval", "%s", s.storageConfig.AutoSaveInterval)
+	info.add("persist_interval", "%s", s.persistInterval)
+
+	if fs, ok := s.storage.(*storage.FileStorage); ok {
+		st := fs.SaveStatus()
+		unsaved, status := 0, "ok"
+		if st.Dirty {
+			unsaved = 1
+		}
+		if st.LastErr != nil {
+			status = "err"
+		}
+		info.add("unsaved_changes", "%d", unsaved)
+		info.add("rdb_last_save_time", "%d", st.LastSave.Unix())
+		info.add("rdb_last_bgsave_status", "%s", status)
+		if st.LastErr != nil {
+			info.add("rdb_last_save_error", "%s", st.LastErr)
+		}
+	}
+	return info
+}
+
+func (s *Server) infoStats() infoFields {
+	var info infoFields
+	info.add("total_connections_received", "%d", s.stats.connections.Load())
+	info.add("total_commands_processed", "%d", s.stats.commands.Load())
+	info.add("total_error_replies", "%d", s.stats.errors.Load())
+	return info
+}
+
+func (s *Server) infoCommandstats() infoFields {
+	latency := s.stats.commandLatency()
+
+	var info infoFields
+	for _, cmd := range sortedKeys(latency) {
+		calls := latency[cmd].Count()
+		usec := latency[cmd].Sum() * 1e6
+		info.add("cmdstat_"+cmd, "calls=%d,usec=%d,usec_per_call=%.2f", calls, int64(usec), usec/float64(max(calls, 1)))
+	}
+	return info
+}
+
+// infoDispensers 发号器数量（按类型）和容量状态汇总，状态与 DISPENSERS STATUS 相同
+func (s *Server) infoDispensers() infoFields {
+	s.mu.RLock()
+	dispensers := make(map[string]dispenser.NumberDispenser, len(s.dispensers))
+	for name, d := range s.dispensers {
+		dispensers[name] = d
+	}
+	s.mu.RUnlock()
+
+	byType := make(map[dispenser.Type]int)
+	byStatus := make(map[string]int)
+	for name, d := range dispensers {
+		byType[d.GetConfig().Type]++
+		f, ok := s.forecast(name, d)
+		byStatus[capacityStatus(d.GetConfig(), f, ok)]++
+	}
+
+	typeNames := make(map[dispenser.Type]string, len(scanTypes))
+	for name, t := range scanTypes {
+		typeNames[t] = name
+	}
+
+	var info infoFields
+	info.add("dispensers", "%d", len(dispensers))
+	for t := dispenser.TypeNumericRandom; t <= dispenser.TypeULID; t++ {
+		info.add("type_"+typeNames[t], "%d", byType[t])
+	}
+	info.add("bounded", "%d", len(dispensers)-byStatus["unbounded"])
+	info.add("capacity_warnings", "%d", byStatus["warning"])
+	info.add("exhausted", "%d", byStatus["exhausted"])
+	return info
+}
+
+// infoKeyspace 与 Redis 的 db0 格式相同，方便监控工具统计键数量
+func (s *Server) infoKeyspace() infoFields {
+	s.mu.RLock()
+	n := len(s.dispensers)
+	s.mu.RUnlock()
+
+	var info infoFields
+	if n > 0 {
+		info.add("db0", "keys=%d,expires=0,avg_ttl=0", n)
+	}
+	return info
+}
+
+// handleDispensers handles the DISPENSERS command
+// Format: DISPENSERS STATUS
+// STATUS 按名称排序，每个发号器返回一行容量摘要，如
+// "order_id type:2 status:warning utilization:92.00% remaining:8000 issue_rate:1.00/s exhausts_in:2h13m20s"
+// status 为 ok、warning（达到 warn_at）、exhausted（已耗尽）或 unbounded（号码空间无限）
+func (s *Server) handleDispensers(args []string) protocol.Value {
+	if len(args) != 1 {
+		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'dispensers' command"}
+	}
+	if strings.ToUpper(args[0]) != "STATUS" {
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR unknown subcommand '%s' for 'dispensers' command", args[0])}
+	}
+
+	s.mu.RLock()
+	names := make([]string, 0, len(s.dispensers))
+	dispensers := make(map[string]dispenser.NumberDispenser, len(s.dispensers))
+	for name, d := range s.dispensers {
+		names = append(names, name)
+		dispensers[name] = d
+	}
+	s.mu.RUnlock()
+	sort.Strings(names)
+
+	result := make([]protocol.Value, 0, len(names))
+	for _, name := range names {
+		d := dispensers[name]
+		cfg := d.GetConfig()
+		line := fmt.Sprintf("%s type:%d", name, cfg.Type)
+
+		f, ok := s.forecast(name, d)
+		line += " status:" + capacityStatus(cfg, f, ok)
+		if ok {
+			line += fmt.Sprintf(" utilization:%.2f%% remaining:%d issue_rate:%.2f/s exhausts_in:%s",
+				f.Utilization()*100, f.Remaining, f.Rate, exhaustsIn(f))
+		}
+		result = append(result, protocol.Value{Type: protocol.BulkString, Bulk: line})
+	}
+
+	return protocol.Value{Type: protocol.Array, Array: result}
+}
+
+// capacityStatus 返回发号器的容量状态：ok、warning（达到 warn_at）、exhausted（已耗尽）或 unbounded（号码空间无限）
+func capacityStatus(cfg dispenser.Config, f dispenser.Forecast, bounded bool) string {
+	switch {
+	case !bounded:
+		return "unbounded"
+	case f.Remaining == 0:
+		return "exhausted"
+	case warnReached(cfg, f):
+		return "warning"
+	default:
+		return "ok"
+	}
+}
+
+// defaultScanCount SCAN 未指定 COUNT 时每次检查的发号器数
+const defaultScanCount = 10
+
+// scanTypes SCAN TYPE 可以使用的类型名称（也可以直接写类型编号 1~6）
+var scanTypes = map[string]dispenser.Type{
+	"numeric_random":      dispenser.TypeNumericRandom,
+	"numeric_incremental": dispenser.TypeNumericIncremental,
+	"alphanumeric_random": dispenser.TypeAlphanumericRandom,
+	"snowflake":           dispenser.TypeSnowflake,
+	"uuid":                dispenser.TypeUUID,
+	"ulid":                dispenser.TypeULID,
+}
+
+// scanEntry 按游标顺序排列的发号器
+type scanEntry struct {
+	hash uint64
+	name string
+	d    dispenser.NumberDispenser
+}
+
+// handleScan handles the SCAN command to enumerate dispensers incrementally
+// Format: SCAN cursor [MATCH pattern] [COUNT n] [TYPE t]
+// 发号器按名称的 FNV-1a 哈希排序，游标为下一个要检查的哈希值，返回 0 表示遍历结束
+// 游标不依赖位置：遍历期间一直存在的发号器恰好返回一次，期间新建或删除的可能返回也可能不返回
+func (s *Server) handleScan(args []string) protocol.Value {
+	if len(args) == 0 || len(args)%2 == 0 {
+		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'scan' command"}
+	}
+
+	cursor, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return protocol.Value{Type: protocol.Error, Str: "ERR invalid cursor"}
+	}
+
+	pattern := "*"
+	count := defaultScanCount
+	var typ dispenser.Type
+	for i := 1; i < len(args); i += 2 {
+		value := args[i+1]
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = value
+		case "COUNT":
+			count, err = strconv.Atoi(value)
+			if err != nil || count <= 0 {
+				return protocol.Value{Type: protocol.Error, Str: "ERR count must be a positive integer"}
+			}
+		case "TYPE":
+			t, ok := parseScanType(value)
+			if !ok {
+				return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR unknown type '%s'", value)}
+			}
+			typ = t
+		default:
+			return protocol.Value{Type: protocol.Error, Str: "ERR syntax error"}
+		}
+	}
+
+	s.mu.RLock()
+	entries := make([]scanEntry, 0, len(s.dispensers))
+	for name, d := range s.dispensers {
+		entries = append(entries, scanEntry{hash: nameHash(name), name: name, d: d})
+	}
+	s.mu.RUnlock()
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].hash != entries[j].hash {
+			return entries[i].hash < entries[j].hash
+		}
+		return entries[i].name < entries[j].name
+	})
+
+	// 检查 count 个发号器后再过滤；哈希相同的发号器在同一批返回，游标才能准确续接
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].hash >= cursor })
+	start := i
+	keys := []protocol.Value{}
+	for ; i < len(entries) && (i-start < count || entries[i].hash == entries[i-1].hash); i++ {
+		e := entries[i]
+		if typ != 0 && e.d.GetConfig().Type != typ {
+			continue
+		}
+		if globMatch(pattern, e.name) {
+			keys = append(keys, protocol.Value{Type: protocol.BulkString, Bulk: e.name})
+		}
+	}
+
+	var next uint64
+	if i < len(entries) {
+		next = entries[i].hash
+	}
+
+	return protocol.Value{Type: protocol.Array, Array: []protocol.Value{
+		{Type: protocol.BulkString, Bulk: strconv.FormatUint(next, 10)},
+		{Type: protocol.Array, Array: keys},
+	}}
+}
+
+// handleKeys handles the KEYS command to list dispensers matching a pattern
+// Format: KEYS pattern
+// 一次返回全部匹配的名称（按名称排序），发号器很多时建议使用 SCAN
+func (s *Server) handleKeys(args []string) protocol.Value {
+	if len(args) != 1 {
+		return protocol.Value{Type: protocol.Error, Str: "ERR wrong number of arguments for 'keys' command"}
+	}
+
+	s.mu.RLock()
+	var names []string
+	for name := range s.dispensers {
+		if globMatch(args[0], name) {
+			names = append(names, name)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Strings(names)
+
+	result := make([]protocol.Value, len(names))
+	for i, name := range names {
+		result[i] = protocol.Value{Type: protocol.BulkString, Bulk: name}
+	}
+	return protocol.Value{Type: protocol.Array, Array: result}
+}
+
+// parseScanType 解析 SCAN TYPE 的值：类型编号或类型名称（不区分大小写）
+func parseScanType(value string) (dispenser.Type, bool) {
+	if n, err := strconv.Atoi(value); err == nil {
+		t := dispenser.Type(n)
+		return t, t >= dispenser.TypeNumericRandom && t <= dispenser.TypeULID
+	}
+	t, ok := scanTypes[strings.ToLower(value)]
+	return t, ok
+}
+
+// nameHash 返回发号器名称在 SCAN 中的排序位置
+func nameHash(name string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return h.Sum64()
+}
+
+// globMatch 按 Redis 的 glob 规则匹配名称（按字节比较）
+// * 匹配任意字符串，? 匹配单个字符，[abc]、[^abc]、[a-z] 匹配字符集合，\ 转义下一个字符
+func globMatch(pattern, name string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			// 连续的 * 等价于一个
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if globMatch(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(name) == 0 {
+				return false
+			}
+			pattern, name = pattern[1:], name[1:]
+
+		case '[':
+			if len(name) == 0 {
+				return false
+			}
+			matched, rest := matchClass(pattern[1:], name[0])
+			if !matched {
+				return false
+			}
+			pattern, name = rest, name[1:]
+
+		default:
+			if pattern[0] == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(name) == 0 || pattern[0] != name[0] {
+				return false
+			}
+			pattern, name = pattern[1:], name[1:]
+		}
+	}
+	return len(name) == 0
+}
+
+// matchClass 匹配 [...] 字符集合，class 为 [ 之后的部分，返回是否匹配以及 ] 之后的模式
+// 缺少 ] 时集合延续到模式末尾
+func matchClass(class string, c byte) (bool, string) {
+	negate := len(class) > 0 && class[0] == '^'
+	if negate {
+		class = class[1:]
+	}
+
+	matched := false
+	for len(class) > 0 && class[0] != ']' {
+		switch {
+		case class[0] == '\\' && len(class) > 1:
+			matched = matched || class[1] == c
+			class = class[2:]
+		case len(class) > 2 && class[1] == '-' && class[2] != ']':
+			lo, hi := min(class[0], class[2]), max(class[0], class[2])
+			matched = matched || (c >= lo && c <= hi)
+			class = class[3:]
+		default:
+			matched = matched || class[0] == c
+			class = class[1:]
+		}
+	}
+	if len(class) > 0 {
+		class = class[1:]
+	}
+	return matched != negate, class
 }
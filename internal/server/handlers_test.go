@@ -1,27 +1,45 @@
 package server
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/nicexiaonie/number-dispenser/internal/dispenser"
 	"github.com/nicexiaonie/number-dispenser/internal/protocol"
 	"github.com/nicexiaonie/number-dispenser/internal/storage"
 )
 
-// 测试HSET命令对已存在的发号器的处理
-func TestHandleHSet_ExistingDispenser(t *testing.T) {
-	// 创建服务器
+// newTestServer 创建使用 test_data 文件存储的测试服务器，测试结束时删除 names 对应的发号器数据
+func newTestServer(t *testing.T, names ...string) (*Server, *storage.FileStorage) {
+	t.Helper()
 	stor, err := storage.NewFileStorage("test_data", false)
 	if err != nil {
 		t.Fatalf("Failed to create storage: %v", err)
 	}
-	defer stor.Delete("test_id") // 清理
+	for _, name := range names {
+		t.Cleanup(func() { stor.Delete(name) })
+	}
+	return restartTestServer(stor), stor
+}
 
-	srv := &Server{
+// restartTestServer 在同一存储上创建新的服务器，用于模拟重启
+func restartTestServer(stor *storage.FileStorage) *Server {
+	return &Server{
 		storage:    stor,
 		dispensers: make(map[string]dispenser.NumberDispenser),
 		factory:    dispenser.NewDispenserFactory(stor.Save),
+		startTime:  time.Now(),
 	}
+}
+
+// 测试HSET命令对已存在的发号器的处理
+func TestHandleHSet_ExistingDispenser(t *testing.T) {
+	// 创建服务器
+	srv, _ := newTestServer(t, "test_id")
 
 	// 第一次创建发号器
 	result := srv.handleHSet([]string{
@@ -140,19 +158,76 @@ func TestHandleHSet_ExistingDispenser(t *testing.T) {
 	})
 }
 
-// 测试对随机类型发号器的处理
-func TestHandleHSet_RandomTypeDispenser(t *testing.T) {
-	stor, err := storage.NewFileStorage("test_data", false)
+// 测试修改已存在发号器的号段参数
+func TestHandleHSet_SegmentSettings(t *testing.T) {
+	srv, stor := newTestServer(t, "seg_id")
+
+	result := srv.handleHSet([]string{
+		"seg_id", "type", "2", "incr_mode", "sequence", "starting", "1", "auto_disk", "pre-checkpoint",
+	})
+	if result.Type == protocol.Error {
+		t.Fatalf("Failed to create dispenser: %s", result.Str)
+	}
+
+	for i := 0; i < 3; i++ {
+		srv.handleGet([]string{"seg_id"})
+	}
+
+	stats := srv.dispensers["seg_id"].GetStats()
+	if stats.SegmentSize != dispenser.DefaultSegmentSize || stats.PreloadThreshold != dispenser.DefaultPreloadThreshold {
+		t.Errorf("Expected default segment settings, got size=%d threshold=%v", stats.SegmentSize, stats.PreloadThreshold)
+	}
+
+	// 只修改号段参数，auto_disk 保持不变
+	result = srv.handleHSet([]string{
+		"seg_id", "type", "2", "segment_size", "50", "preload_threshold", "0.3", "checkpoint_interval", "500ms",
+	})
+	if result.Type == protocol.Error {
+		t.Fatalf("Failed to change segment settings: %s", result.Str)
+	}
+	defer srv.dispensers["seg_id"].Shutdown()
+
+	d := srv.dispensers["seg_id"]
+	cfg := d.GetConfig()
+	if cfg.AutoDisk != dispenser.StrategyPreCheckpoint {
+		t.Errorf("Expected auto_disk to stay pre-checkpoint, got %s", cfg.AutoDisk)
+	}
+
+	stats = d.GetStats()
+	if stats.SegmentSize != 50 || stats.PreloadThreshold != 0.3 || stats.CheckpointInterval != 500*time.Millisecond {
+		t.Errorf("Unexpected segment settings: %+v", stats)
+	}
+
+	// 号码不会回退
+	result = srv.handleGet([]string{"seg_id"})
+	if n, _ := strconv.ParseInt(result.Bulk, 10, 64); n <= 3 {
+		t.Errorf("Expected number after previous segment, got %s", result.Bulk)
+	}
+
+	// 参数已持久化
+	saved, _, err := stor.Load("seg_id")
 	if err != nil {
-		t.Fatalf("Failed to create storage: %v", err)
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if saved.SegmentSize != 50 || saved.PreloadThreshold != 0.3 || saved.CheckpointInterval != 500*time.Millisecond {
+		t.Errorf("Segment settings not persisted: %+v", saved)
 	}
-	defer stor.Delete("random_id")
 
-	srv := &Server{
-		storage:    stor,
-		dispensers: make(map[string]dispenser.NumberDispenser),
-		factory:    dispenser.NewDispenserFactory(stor.Save),
+	// 非法参数
+	for _, args := range [][]string{
+		{"seg_id", "type", "2", "segment_size", "0"},
+		{"seg_id", "type", "2", "preload_threshold", "1.5"},
+		{"seg_id", "type", "2", "checkpoint_interval", "abc"},
+	} {
+		if result := srv.handleHSet(args); result.Type != protocol.Error {
+			t.Errorf("Expected error for %v", args)
+		}
 	}
+}
+
+// 测试对随机类型发号器的处理
+func TestHandleHSet_RandomTypeDispenser(t *testing.T) {
+	srv, _ := newTestServer(t, "random_id")
 
 	// 创建Type 1发号器
 	result := srv.handleHSet([]string{
@@ -168,6 +243,18 @@ func TestHandleHSet_RandomTypeDispenser(t *testing.T) {
 		srv.handleGet([]string{"random_id"})
 	}
 
+	// 新建发号器默认使用安全随机源
+	if info := srv.handleInfo([]string{"random_id"}).Bulk; !strings.Contains(info, "rng:secure") {
+		t.Errorf("Expected rng:secure in info: %s", info)
+	}
+
+	t.Run("CannotChangeRNG", func(t *testing.T) {
+		result := srv.handleHSet([]string{"random_id", "type", "1", "rng", "fast"})
+		if result.Type != protocol.Error {
+			t.Error("Expected error when changing rng")
+		}
+	})
+
 	// 尝试修改length（应该失败）
 	t.Run("CannotChangeLength", func(t *testing.T) {
 		result := srv.handleHSet([]string{
@@ -199,17 +286,7 @@ func TestHandleHSet_RandomTypeDispenser(t *testing.T) {
 
 // 测试Type 3字符随机发号器
 func TestHandleHSet_AlphanumericDispenser(t *testing.T) {
-	stor, err := storage.NewFileStorage("test_data", false)
-	if err != nil {
-		t.Fatalf("Failed to create storage: %v", err)
-	}
-	defer stor.Delete("session_id")
-
-	srv := &Server{
-		storage:    stor,
-		dispensers: make(map[string]dispenser.NumberDispenser),
-		factory:    dispenser.NewDispenserFactory(stor.Save),
-	}
+	srv, stor := newTestServer(t, "session_id")
 
 	// 创建Type 3发号器
 	result := srv.handleHSet([]string{
@@ -231,4 +308,798 @@ func TestHandleHSet_AlphanumericDispenser(t *testing.T) {
 		}
 		t.Logf("Error message: %s", result.Str)
 	})
+
+	t.Run("CustomAlphabet", func(t *testing.T) {
+		defer stor.Delete("coupon")
+
+		result := srv.handleHSet([]string{
+			"coupon", "type", "3", "custom_alphabet", "ABCDEFGHJK", "length", "8", "auto_disk", "memory",
+		})
+		if result.Type == protocol.Error {
+			t.Fatalf("Failed to create dispenser: %s", result.Str)
+		}
+
+		code := srv.handleGet([]string{"coupon"}).Bulk
+		if len(code) != 8 || strings.Trim(code, "ABCDEFGHJK") != "" {
+			t.Errorf("Unexpected code %s", code)
+		}
+
+		info := srv.handleInfo([]string{"coupon"}).Bulk
+		if !strings.Contains(info, "charset:custom") || !strings.Contains(info, "custom_alphabet:ABCDEFGHJK") {
+			t.Errorf("Unexpected info: %s", info)
+		}
+
+		result = srv.handleHSet([]string{"coupon2", "type", "3", "custom_alphabet", "AABB", "length", "8"})
+		if result.Type != protocol.Error {
+			t.Error("Expected error for duplicate characters")
+		}
+	})
+}
+
+// 测试批量取号命令
+func TestHandleGetN(t *testing.T) {
+	srv, stor := newTestServer(t, "batch_id")
+
+	result := srv.handleHSet([]string{
+		"batch_id", "type", "2", "incr_mode", "sequence", "starting", "1",
+	})
+	if result.Type == protocol.Error {
+		t.Fatalf("Failed to create dispenser: %s", result.Str)
+	}
+
+	result = srv.handleGetN([]string{"batch_id", "5"})
+	if result.Type != protocol.Array || len(result.Array) != 5 {
+		t.Fatalf("Expected array of 5, got %+v", result)
+	}
+	if result.Array[0].Bulk != "1" || result.Array[4].Bulk != "5" {
+		t.Errorf("Unexpected batch: %+v", result.Array)
+	}
+
+	// elegant_close 策略下整批只保存一次，保存的位置应为批次之后
+	_, current, err := stor.Load("batch_id")
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if current != 6 {
+		t.Errorf("Expected persisted current=6, got %d", current)
+	}
+
+	// GET key COUNT n 形式
+	result = srv.handleGet([]string{"batch_id", "COUNT", "2"})
+	if result.Type != protocol.Array || len(result.Array) != 2 || result.Array[0].Bulk != "6" {
+		t.Errorf("Unexpected GET COUNT result: %+v", result)
+	}
+
+	// 非法数量
+	result = srv.handleGetN([]string{"batch_id", "0"})
+	if result.Type != protocol.Error {
+		t.Error("Expected error for count 0")
+	}
+}
+
+// 测试号段租约命令
+func TestHandleAllocSeg(t *testing.T) {
+	srv, stor := newTestServer(t, "lease_id")
+
+	result := srv.handleHSet([]string{
+		"lease_id", "type", "2", "incr_mode", "sequence", "starting", "1000", "step", "2",
+	})
+	if result.Type == protocol.Error {
+		t.Fatalf("Failed to create dispenser: %s", result.Str)
+	}
+
+	result = srv.handleAllocSeg([]string{"lease_id", "100"})
+	if result.Type != protocol.Array || len(result.Array) != 3 {
+		t.Fatalf("Expected [start, end, step], got %+v", result)
+	}
+	if result.Array[0].Num != 1000 || result.Array[1].Num != 1200 || result.Array[2].Num != 2 {
+		t.Errorf("Unexpected lease: %+v", result.Array)
+	}
+
+	// 租约已持久化
+	_, current, err := stor.Load("lease_id")
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if current != 1200 {
+		t.Errorf("Expected persisted current=1200, got %d", current)
+	}
+
+	// 后续 GET 从租约之后开始
+	result = srv.handleGet([]string{"lease_id"})
+	if result.Bulk != "1200" {
+		t.Errorf("Expected 1200 after lease, got %s", result.Bulk)
+	}
+
+	// 随机类型不支持号段
+	srv.handleHSet([]string{"lease_id_rand", "type", "1", "length", "6", "auto_disk", "memory"})
+	defer stor.Delete("lease_id_rand")
+	result = srv.handleAllocSeg([]string{"lease_id_rand", "10"})
+	if result.Type != protocol.Error {
+		t.Error("Expected error for random type")
+	}
+}
+
+// 测试ID解码命令
+func TestHandleDecode(t *testing.T) {
+	srv, _ := newTestServer(t, "decode_sf", "decode_seq")
+
+	srv.handleHSet([]string{"decode_sf", "type", "4", "machine_id", "7", "datacenter_id", "2", "auto_disk", "memory"})
+	id := srv.handleGet([]string{"decode_sf"}).Bulk
+
+	result := srv.handleDecode([]string{"decode_sf", id})
+	if result.Type != protocol.Array {
+		t.Fatalf("Expected array, got %+v", result)
+	}
+
+	fields := make(map[string]string)
+	for i := 0; i+1 < len(result.Array); i += 2 {
+		fields[result.Array[i].Bulk] = result.Array[i+1].Bulk
+	}
+	if fields["machine_id"] != "7" || fields["datacenter_id"] != "2" || fields["timestamp"] == "" {
+		t.Errorf("Unexpected decode result: %v", fields)
+	}
+
+	// 自增类型不可解码
+	srv.handleHSet([]string{"decode_seq", "type", "2", "auto_disk", "memory"})
+	result = srv.handleDecode([]string{"decode_seq", "1"})
+	if result.Type != protocol.Error {
+		t.Error("Expected not decodable error")
+	}
+}
+
+func TestHandleValidate(t *testing.T) {
+	srv, _ := newTestServer(t, "member_card")
+
+	result := srv.handleHSet([]string{
+		"member_card", "type", "2", "incr_mode", "fixed", "length", "10", "starting", "1000",
+		"check_digit", "luhn", "auto_disk", "memory",
+	})
+	if result.Type == protocol.Error {
+		t.Fatalf("Failed to create dispenser: %s", result.Str)
+	}
+
+	id := srv.handleGet([]string{"member_card"}).Bulk
+	if id != "00000010009" {
+		t.Fatalf("Expected 00000010009, got %s", id)
+	}
+
+	tests := []struct {
+		value    string
+		expected int64
+	}{
+		{id, 1},
+		{"00000010008", 0}, // 校验码错误
+		{"00000001008", 0}, // 小于起始值
+		{"0000010009", 0},  // 长度错误
+	}
+	for _, tt := range tests {
+		if result := srv.handleValidate([]string{"member_card", tt.value}); result.Num != tt.expected {
+			t.Errorf("VALIDATE %s = %+v, want %d", tt.value, result, tt.expected)
+		}
+	}
+
+	if result := srv.handleValidate([]string{"missing", id}); result.Type != protocol.Error {
+		t.Error("Expected dispenser not found error")
+	}
+
+	// check_digit 只支持 Type 1、2
+	result = srv.handleHSet([]string{"member_card_uuid", "type", "5", "check_digit", "luhn", "auto_disk", "memory"})
+	if result.Type != protocol.Error {
+		t.Error("Expected error for check_digit on Type 5")
+	}
+}
+
+func TestHandleHSet_Permuted(t *testing.T) {
+	srv, stor := newTestServer(t, "order_no")
+
+	result := srv.handleHSet([]string{
+		"order_no", "type", "2", "incr_mode", "permuted", "length", "10", "starting", "1",
+	})
+	if result.Type == protocol.Error {
+		t.Fatalf("Failed to create dispenser: %s", result.Str)
+	}
+
+	first := srv.handleGet([]string{"order_no"}).Bulk
+	second := srv.handleGet([]string{"order_no"}).Bulk
+	if len(first) != 10 || first == second {
+		t.Fatalf("Unexpected numbers %s, %s", first, second)
+	}
+
+	// DECODE 还原计数器
+	result = srv.handleDecode([]string{"order_no", second})
+	if len(result.Array) != 2 || result.Array[0].Bulk != "counter" || result.Array[1].Bulk != "2" {
+		t.Errorf("Unexpected decode result: %+v", result)
+	}
+
+	if info := srv.handleInfo([]string{"order_no"}).Bulk; !strings.Contains(info, "mode:permuted") {
+		t.Errorf("Expected mode:permuted in info: %s", info)
+	}
+
+	// 自动生成的密钥随配置持久化，重启后号码仍可解码
+	all, _ := stor.ListAll()
+	if all["order_no"].Config.PermKey == "" {
+		t.Fatal("perm_key not persisted")
+	}
+
+	restarted := restartTestServer(stor)
+	if err := restarted.loadDispensers(); err != nil {
+		t.Fatalf("Failed to load dispensers: %v", err)
+	}
+	result = restarted.handleDecode([]string{"order_no", first})
+	if len(result.Array) != 2 || result.Array[1].Bulk != "1" {
+		t.Errorf("Unexpected decode result after restart: %+v", result)
+	}
+}
+
+// 测试 Type 1 已发号码持久化，重启后不会重复发号
+func TestHandleHSet_RandomUsedRestore(t *testing.T) {
+	srv, stor := newTestServer(t, "coupon_no")
+
+	// 2位号码空间只有90个，重启后丢失去重记录必然重复
+	result := srv.handleHSet([]string{"coupon_no", "type", "1", "length", "2"})
+	if result.Type == protocol.Error {
+		t.Fatalf("Failed to create dispenser: %s", result.Str)
+	}
+
+	seen := make(map[string]bool)
+	for _, v := range srv.handleGetN([]string{"coupon_no", "35"}).Array {
+		seen[v.Bulk] = true
+	}
+
+	restarted := restartTestServer(stor)
+	if err := restarted.loadDispensers(); err != nil {
+		t.Fatalf("Failed to load dispensers: %v", err)
+	}
+
+	if info := restarted.handleInfo([]string{"coupon_no"}).Bulk; !strings.Contains(info, "unique_tracked:35") {
+		t.Errorf("Expected 35 tracked numbers after restart: %s", info)
+	}
+
+	for i := 0; i < 35; i++ {
+		num := restarted.handleGet([]string{"coupon_no"}).Bulk
+		if seen[num] {
+			t.Fatalf("Number %s reissued after restart", num)
+		}
+		seen[num] = true
+	}
+
+	// 删除发号器同时删除去重日志
+	restarted.handleDel([]string{"coupon_no"})
+	if values, _ := stor.LoadUsed("coupon_no"); len(values) != 0 {
+		t.Errorf("Expected used log removed, got %d values", len(values))
+	}
+
+	// memory 策略不写去重日志
+	restarted.handleHSet([]string{"coupon_no", "type", "1", "length", "2", "auto_disk", "memory"})
+	restarted.handleGetN([]string{"coupon_no", "10"})
+	if values, _ := stor.LoadUsed("coupon_no"); len(values) != 0 {
+		t.Errorf("Expected no used log for memory strategy, got %d values", len(values))
+	}
+}
+
+// 测试重建 Type 1 发号器（修改 auto_disk）时带上已发号码，memory 切换到持久化策略时补写去重日志
+func TestHandleHSet_RandomUsedRebuild(t *testing.T) {
+	srv, stor := newTestServer(t, "seat_no")
+
+	result := srv.handleHSet([]string{"seat_no", "type", "1", "length", "2", "auto_disk", "memory"})
+	if result.Type == protocol.Error {
+		t.Fatalf("Failed to create dispenser: %s", result.Str)
+	}
+	seen := make(map[string]bool)
+	for _, v := range srv.handleGetN([]string{"seat_no", "40"}).Array {
+		seen[v.Bulk] = true
+	}
+
+	if result := srv.handleHSet([]string{"seat_no", "type", "1", "auto_disk", "elegant_close"}); result.Type == protocol.Error {
+		t.Fatalf("Failed to change auto_disk: %s", result.Str)
+	}
+	if values, _ := stor.LoadUsed("seat_no"); len(values) != 40 {
+		t.Errorf("Expected 40 numbers written to the used log, got %d", len(values))
+	}
+	for _, v := range srv.handleGetN([]string{"seat_no", "30"}).Array {
+		if seen[v.Bulk] {
+			t.Fatalf("Number %s reissued after rebuild", v.Bulk)
+		}
+		seen[v.Bulk] = true
+	}
+
+	// 切回 memory 同样保留内存中的去重集合
+	if result := srv.handleHSet([]string{"seat_no", "type", "1", "auto_disk", "memory"}); result.Type == protocol.Error {
+		t.Fatalf("Failed to change auto_disk: %s", result.Str)
+	}
+	for _, v := range srv.handleGetN([]string{"seat_no", "20"}).Array {
+		if seen[v.Bulk] {
+			t.Fatalf("Number %s reissued after rebuild", v.Bulk)
+		}
+		seen[v.Bulk] = true
+	}
+	if result := srv.handleGet([]string{"seat_no"}); result.Type != protocol.Error {
+		t.Errorf("Expected exhausted after 90 numbers, got %s", result.Bulk)
+	}
+}
+
+// 测试重建发号器时并发取号不会重复：读取旧发号器状态到替换完成之间旧实例不能再发号
+func TestHandleHSet_RebuildConcurrentGet(t *testing.T) {
+	srv, _ := newTestServer(t, "order_no")
+
+	result := srv.handleHSet([]string{"order_no", "type", "2", "incr_mode", "sequence", "starting", "1", "auto_disk", "memory"})
+	if result.Type == protocol.Error {
+		t.Fatalf("Failed to create dispenser: %s", result.Str)
+	}
+
+	// 取号协程一直运行到重建结束
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	batches := make([][]protocol.Value, 4)
+	for i := range batches {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					batches[i] = append(batches[i], srv.handleGetN([]string{"order_no", "5"}).Array...)
+				}
+			}
+		}(i)
+	}
+	for i := 0; i < 500; i++ {
+		strategy := []string{"elegant_close", "memory"}[i%2]
+		if result := srv.handleHSet([]string{"order_no", "type", "2", "auto_disk", strategy}); result.Type == protocol.Error {
+			close(done)
+			wg.Wait()
+			t.Fatalf("Failed to change auto_disk: %s", result.Str)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, batch := range batches {
+		for _, v := range batch {
+			if seen[v.Bulk] {
+				t.Fatalf("Number %s issued twice during rebuild", v.Bulk)
+			}
+			seen[v.Bulk] = true
+		}
+	}
+}
+
+// 测试周期重置发号器的周期随 current 一起持久化并恢复
+func TestHandleHSet_ResetPeriodRestore(t *testing.T) {
+	srv, stor := newTestServer(t, "invoice_id")
+
+	result := srv.handleHSet([]string{
+		"invoice_id", "type", "2", "incr_mode", "fixed", "length", "6", "starting", "1",
+		"reset_period", "daily", "timezone", "UTC",
+	})
+	if result.Type == protocol.Error {
+		t.Fatalf("Failed to create dispenser: %s", result.Str)
+	}
+
+	today := time.Now().UTC().Format("20060102")
+	srv.handleGet([]string{"invoice_id"})
+	if result := srv.handleGet([]string{"invoice_id"}); result.Bulk != today+"000002" {
+		t.Errorf("Expected %s000002, got %s", today, result.Bulk)
+	}
+
+	all, _ := stor.ListAll()
+	if all["invoice_id"].Period != time.Now().UTC().Format("2006-01-02") {
+		t.Errorf("Period not persisted: %+v", all["invoice_id"])
+	}
+
+	// 模拟重启：从存储恢复后继续当天的序列
+	restarted := restartTestServer(stor)
+	if err := restarted.loadDispensers(); err != nil {
+		t.Fatalf("Failed to load dispensers: %v", err)
+	}
+	if result := restarted.handleGet([]string{"invoice_id"}); result.Bulk != today+"000003" {
+		t.Errorf("Expected %s000003 after restart, got %s", today, result.Bulk)
+	}
+
+	// 号段策略不支持周期重置
+	result = srv.handleHSet([]string{"invoice_id", "type", "2", "auto_disk", "pre_close"})
+	if result.Type != protocol.Error {
+		t.Error("Expected error when switching a periodic dispenser to a segment strategy")
+	}
+}
+
+// 测试毫秒时钟类型每次发号后立即保存时间戳，重启后时钟落后时拒绝发号
+func TestHandleGet_ClockRollbackRestore(t *testing.T) {
+	srv, stor := newTestServer(t, "trace_id")
+
+	result := srv.handleHSet([]string{"trace_id", "type", "6", "clock_rollback", "wait"})
+	if result.Type == protocol.Error {
+		t.Fatalf("Failed to create dispenser: %s", result.Str)
+	}
+	if result := srv.handleGet([]string{"trace_id"}); result.Type == protocol.Error {
+		t.Fatalf("Failed to generate: %s", result.Str)
+	}
+
+	// 不依赖周期保存：发号后存储中已是上次发号的时间戳
+	cfg, lastTimestamp, err := stor.Load("trace_id")
+	if err != nil {
+		t.Fatalf("Failed to load dispenser: %v", err)
+	}
+	if want := srv.dispensers["trace_id"].GetCurrent(); lastTimestamp != want || lastTimestamp == 0 {
+		t.Fatalf("Expected timestamp %d persisted after GET, got %d", want, lastTimestamp)
+	}
+
+	// 模拟崩溃后时钟回拨 1 分钟：重启时上次发号的时间戳领先于系统时钟
+	stor.Save("trace_id", cfg, lastTimestamp+60000)
+	restarted := restartTestServer(stor)
+	if err := restarted.loadDispensers(); err != nil {
+		t.Fatalf("Failed to load dispensers: %v", err)
+	}
+
+	// 回拨超过 rollback_wait_ms，等待策略也拒绝发号
+	result = restarted.handleGet([]string{"trace_id"})
+	if result.Type != protocol.Error || !strings.Contains(result.Str, dispenser.ErrClockMovedBackwards.Error()) {
+		t.Errorf("Expected clock moved backwards error after restart, got %+v", result)
+	}
+
+	// 号段策略不保存上次发号的时间戳，毫秒时钟类型不能使用
+	for _, strategy := range []string{"pre-base", "pre-checkpoint", "pre_close"} {
+		if result := restarted.handleHSet([]string{"trace_id", "type", "6", "auto_disk", strategy}); result.Type != protocol.Error {
+			t.Errorf("Expected error for auto_disk %s on a clock-based type, got %+v", strategy, result)
+		}
+	}
+	if result := restarted.handleHSet([]string{"span_id", "type", "4", "machine_id", "1", "auto_disk", "pre_close"}); result.Type != protocol.Error {
+		t.Errorf("Expected error for auto_disk pre_close on snowflake, got %+v", result)
+	}
+}
+
+// 测试 RELEASE 归还号码，回收池重启后恢复
+func TestHandleRelease(t *testing.T) {
+	srv, stor := newTestServer(t, "room_no")
+
+	result := srv.handleHSet([]string{"room_no", "type", "2", "incr_mode", "fixed", "length", "3", "starting", "101", "recycle", "true"})
+	if result.Type == protocol.Error {
+		t.Fatalf("Failed to create dispenser: %s", result.Str)
+	}
+	srv.handleGetN([]string{"room_no", "3"}) // 101~103
+
+	if result := srv.handleRelease([]string{"room_no", "102", "101"}); result.Type != protocol.Integer || result.Num != 2 {
+		t.Fatalf("Expected 2 released, got %+v", result)
+	}
+	if result := srv.handleRelease([]string{"room_no", "104"}); result.Type != protocol.Error {
+		t.Errorf("Expected error for unissued number, got %+v", result)
+	}
+	if info := srv.handleInfo([]string{"room_no"}).Bulk; !strings.Contains(info, "recycle_pool:2") {
+		t.Errorf("Expected 2 pooled numbers in INFO: %s", info)
+	}
+
+	restarted := restartTestServer(stor)
+	if err := restarted.loadDispensers(); err != nil {
+		t.Fatalf("Failed to load dispensers: %v", err)
+	}
+
+	var nums []string
+	for _, v := range restarted.handleGetN([]string{"room_no", "3"}).Array {
+		nums = append(nums, v.Bulk)
+	}
+	if strings.Join(nums, ",") != "102,101,104" {
+		t.Errorf("Expected recycled numbers first after restart, got %v", nums)
+	}
+
+	// 未启用回收的发号器
+	srv.handleHSet([]string{"plain_no", "type", "2", "auto_disk", "memory"})
+	defer stor.Delete("plain_no")
+	srv.handleGet([]string{"plain_no"})
+	if result := srv.handleRelease([]string{"plain_no", "0"}); result.Type != protocol.Error {
+		t.Errorf("Expected error when recycling is disabled, got %+v", result)
+	}
+}
+
+func TestHandleReserve(t *testing.T) {
+	srv, stor := newTestServer(t, "member_no")
+
+	result := srv.handleHSet([]string{"member_no", "type", "2", "incr_mode", "fixed", "length", "4", "starting", "8885"})
+	if result.Type == protocol.Error {
+		t.Fatalf("Failed to create dispenser: %s", result.Str)
+	}
+
+	if result := srv.handleReserve([]string{"member_no", "8888", "8890-8891"}); result.Type != protocol.Integer || result.Num != 3 {
+		t.Fatalf("Expected 3 reserved, got %+v", result)
+	}
+	if result := srv.handleReserve([]string{"member_no", "99-1"}); result.Type != protocol.Error {
+		t.Errorf("Expected error for invalid range, got %+v", result)
+	}
+	if result := srv.handleClaim([]string{"member_no", "8890"}); result.Bulk != "8890" {
+		t.Fatalf("Expected 8890 claimed, got %+v", result)
+	}
+	if result := srv.handleUnreserve([]string{"member_no", "8891"}); result.Num != 1 {
+		t.Errorf("Expected 1 unreserved, got %+v", result)
+	}
+
+	var ranges []string
+	for _, v := range srv.handleReservations([]string{"member_no"}).Array {
+		ranges = append(ranges, v.Bulk)
+	}
+	if strings.Join(ranges, ",") != "8888" {
+		t.Errorf("Expected reserved 8888, got %v", ranges)
+	}
+	if info := srv.handleInfo([]string{"member_no"}).Bulk; !strings.Contains(info, "reserved:1\nclaimed:1") {
+		t.Errorf("Expected reservation counts in INFO: %s", info)
+	}
+
+	// 重启后保留和认领状态仍然生效
+	restarted := restartTestServer(stor)
+	if err := restarted.loadDispensers(); err != nil {
+		t.Fatalf("Failed to load dispensers: %v", err)
+	}
+
+	var nums []string
+	for _, v := range restarted.handleGetN([]string{"member_no", "5"}).Array {
+		nums = append(nums, v.Bulk)
+	}
+	if strings.Join(nums, ",") != "8885,8886,8887,8889,8891" {
+		t.Errorf("Expected reserved and claimed numbers skipped after restart, got %v", nums)
+	}
+}
+
+func TestHandleHSet_Bounds(t *testing.T) {
+	srv, stor := newTestServer(t, "countdown")
+
+	result := srv.handleHSet([]string{"countdown", "type", "2", "incr_mode", "fixed", "length", "2", "step", "-1", "min", "1", "max", "3", "on_exhausted", "wrap"})
+	if result.Type == protocol.Error {
+		t.Fatalf("Failed to create dispenser: %s", result.Str)
+	}
+	if result := srv.handleHSet([]string{"bad", "type", "2", "on_exhausted", "stop"}); result.Type != protocol.Error {
+		t.Errorf("Expected error for invalid on_exhausted, got %+v", result)
+	}
+
+	var nums []string
+	for _, v := range srv.handleGetN([]string{"countdown", "4"}).Array {
+		nums = append(nums, v.Bulk)
+	}
+	if strings.Join(nums, ",") != "03,02,01,03" {
+		t.Errorf("Expected countdown to wrap back to max, got %v", nums)
+	}
+
+	if info := srv.handleInfo([]string{"countdown"}).Bulk; !strings.Contains(info, "min:1\nmax:3\non_exhausted:wrap") {
+		t.Errorf("Expected bounds in INFO: %s", info)
+	}
+
+	// 重启后从回绕后的位置继续
+	restarted := restartTestServer(stor)
+	if err := restarted.loadDispensers(); err != nil {
+		t.Fatalf("Failed to load dispensers: %v", err)
+	}
+	if result := restarted.handleGet([]string{"countdown"}); result.Bulk != "02" {
+		t.Errorf("Expected 02 after restart, got %+v", result)
+	}
+}
+
+func TestHandleCapacityWarning(t *testing.T) {
+	srv, _ := newTestServer(t, "ticket_no", "session")
+
+	result := srv.handleHSet([]string{"ticket_no", "type", "2", "incr_mode", "fixed", "length", "1", "starting", "0", "warn_at", "0.5"})
+	if result.Type == protocol.Error {
+		t.Fatalf("Failed to create dispenser: %s", result.Str)
+	}
+	if result := srv.handleHSet([]string{"session", "type", "3", "length", "8", "warn_at", "0.5"}); result.Type != protocol.Error {
+		t.Errorf("Expected error for warn_at on unbounded type, got %+v", result)
+	}
+	srv.handleHSet([]string{"session", "type", "3", "length", "8"})
+
+	// 发出4个（40%）时还没有告警，返回普通号码
+	srv.handleGetN([]string{"ticket_no", "3"})
+	result = srv.handleGet([]string{"ticket_no"})
+	if result.Type != protocol.BulkString || result.Bulk != "3" {
+		t.Fatalf("Expected plain number 3, got %+v", result)
+	}
+
+	// 发出5个（50%）时达到 warn_at，GET 回复附带告警
+	result = srv.handleGet([]string{"ticket_no"})
+	if len(result.Array) != 2 || result.Array[0].Bulk != "4" || !strings.HasPrefix(result.Array[1].Bulk, "50.00% used, 5 remaining") {
+		t.Errorf("Expected [4, capacity warning], got %+v", result)
+	}
+	if result := srv.handleGet([]string{"ticket_no", "WITHWARN"}); result.Type != protocol.Error {
+		t.Errorf("Expected error for unknown GET option, got %+v", result)
+	}
+
+	info := srv.handleInfo([]string{"ticket_no"}).Bulk
+	if !strings.Contains(info, "capacity:10\nremaining:5\nutilization:50.00%") || !strings.Contains(info, "warn_at:0.5\ncapacity_warning:true") {
+		t.Errorf("Expected capacity in INFO: %s", info)
+	}
+
+	var lines []string
+	for _, v := range srv.handleDispensers([]string{"STATUS"}).Array {
+		lines = append(lines, v.Bulk)
+	}
+	if len(lines) != 2 || lines[0] != "session type:3 status:unbounded" || !strings.HasPrefix(lines[1], "ticket_no type:2 status:warning utilization:50.00% remaining:5") {
+		t.Errorf("Unexpected DISPENSERS STATUS: %v", lines)
+	}
+
+	srv.handleGetN([]string{"ticket_no", "5"})
+	lines = lines[:0]
+	for _, v := range srv.handleDispensers([]string{"status"}).Array {
+		lines = append(lines, v.Bulk)
+	}
+	if len(lines) != 2 || !strings.Contains(lines[1], "status:exhausted") || !strings.HasSuffix(lines[1], "exhausts_in:0s") {
+		t.Errorf("Expected exhausted dispenser, got %v", lines)
+	}
+}
+
+func TestHandleScan(t *testing.T) {
+	srv, _ := newTestServer(t)
+	for i := 0; i < 25; i++ {
+		d, err := srv.factory.CreateDispenser(fmt.Sprintf("order_%02d", i), dispenser.Config{Type: dispenser.TypeNumericIncremental, AutoDisk: dispenser.StrategyMemory})
+		if err != nil {
+			t.Fatalf("Failed to create dispenser: %v", err)
+		}
+		srv.dispensers[fmt.Sprintf("order_%02d", i)] = d
+	}
+	for _, name := range []string{"session", "trace_id"} {
+		d, _ := srv.factory.CreateDispenser(name, dispenser.Config{Type: dispenser.TypeUUID, AutoDisk: dispenser.StrategyMemory})
+		srv.dispensers[name] = d
+	}
+
+	// scanAll 按游标遍历到结束，onRound 在每批之后调用
+	scanAll := func(onRound func(round int), args ...string) map[string]int {
+		seen := make(map[string]int)
+		cursor := "0"
+		for round := 0; ; round++ {
+			result := srv.handleScan(append([]string{cursor}, args...))
+			if result.Type != protocol.Array || len(result.Array) != 2 {
+				t.Fatalf("Unexpected SCAN reply: %+v", result)
+			}
+			for _, v := range result.Array[1].Array {
+				seen[v.Bulk]++
+			}
+			if onRound != nil {
+				onRound(round)
+			}
+			cursor = result.Array[0].Bulk
+			if cursor == "0" {
+				return seen
+			}
+			if round > 100 {
+				t.Fatal("SCAN did not terminate")
+			}
+		}
+	}
+
+	seen := scanAll(nil, "COUNT", "4")
+	if len(seen) != 27 {
+		t.Errorf("Expected all 27 dispensers, got %d", len(seen))
+	}
+
+	// 遍历期间新建和删除发号器，其余发号器仍然恰好返回一次
+	seen = scanAll(func(round int) {
+		if round == 1 {
+			delete(srv.dispensers, "order_00")
+			srv.dispensers["order_new"] = srv.dispensers["order_01"]
+		}
+	}, "COUNT", "4")
+	for name := range srv.dispensers {
+		if n := seen[name]; n != 1 && name != "order_new" {
+			t.Errorf("Expected %s once, got %d", name, n)
+		}
+	}
+
+	if seen := scanAll(nil, "MATCH", "order_1?", "COUNT", "3"); len(seen) != 10 {
+		t.Errorf("Expected 10 matches for order_1?, got %v", seen)
+	}
+	if seen := scanAll(nil, "TYPE", "uuid"); len(seen) != 2 || seen["session"] != 1 || seen["trace_id"] != 1 {
+		t.Errorf("Expected only UUID dispensers, got %v", seen)
+	}
+	if result := srv.handleScan([]string{"0", "TYPE", "string"}); result.Type != protocol.Error {
+		t.Errorf("Expected error for unknown type, got %+v", result)
+	}
+	if result := srv.handleScan([]string{"abc"}); result.Type != protocol.Error {
+		t.Errorf("Expected error for invalid cursor, got %+v", result)
+	}
+
+	var keys []string
+	for _, v := range srv.handleKeys([]string{"[st]*"}).Array {
+		keys = append(keys, v.Bulk)
+	}
+	if strings.Join(keys, ",") != "session,trace_id" {
+		t.Errorf("Expected session,trace_id, got %v", keys)
+	}
+}
+
+func TestHandleInfo_Sections(t *testing.T) {
+	srv, _ := newTestServer(t)
+	for name, cfg := range map[string]dispenser.Config{
+		"order_id": {Type: dispenser.TypeNumericIncremental, IncrMode: dispenser.IncrModeFixed, Length: 2, Starting: 10, AutoDisk: dispenser.StrategyMemory},
+		"stats":    {Type: dispenser.TypeUUID, AutoDisk: dispenser.StrategyMemory},
+	} {
+		d, err := srv.factory.CreateDispenser(name, cfg)
+		if err != nil {
+			t.Fatalf("Failed to create dispenser: %v", err)
+		}
+		srv.dispensers[name] = d
+	}
+
+	command := func(args ...string) protocol.Value {
+		val := protocol.Value{Type: protocol.Array}
+		for _, arg := range args {
+			val.Array = append(val.Array, protocol.Value{Type: protocol.BulkString, Bulk: arg})
+		}
+		return srv.processCommand(val)
+	}
+	command("GET", "order_id")
+	command("get", "order_id")
+	command("GET", "missing")
+	command("NOSUCH")
+
+	// HGETALL 与 INFO <name> 的字段相同（消耗速度随时间变化，只比较字段名）
+	fields := command("HGETALL", "order_id").Array
+	var keys []string
+	for i := 0; i+1 < len(fields); i += 2 {
+		keys = append(keys, fields[i].Bulk)
+		if fields[i].Bulk == "current" && fields[i+1].Bulk != "12" {
+			t.Errorf("Expected current 12, got %s", fields[i+1].Bulk)
+		}
+	}
+	var infoKeys []string
+	for _, line := range strings.Split(command("INFO", "order_id").Bulk, "\n") {
+		infoKeys = append(infoKeys, strings.SplitN(line, ":", 2)[0])
+	}
+	if len(keys) == 0 || strings.Join(keys, ",") != strings.Join(infoKeys, ",") {
+		t.Errorf("HGETALL does not match INFO: %v, %v", keys, infoKeys)
+	}
+	if result := command("HGETALL", "missing"); result.Type != protocol.Error {
+		t.Errorf("Expected error for missing dispenser, got %+v", result)
+	}
+
+	// 不带参数返回默认 section
+	info := command("INFO").Bulk
+	for _, want := range []string{"# Server\r\n", "# Clients\r\nconnected_clients:0\r\n", "# Persistence\r\n",
+		"total_commands_processed:7\r\n", "total_error_replies:3\r\n", "# Dispensers\r\ndispensers:2\r\n",
+		"type_numeric_incremental:1\r\n", "bounded:1\r\n", "db0:keys=2,expires=0,avg_ttl=0\r\n"} {
+		if !strings.Contains(info, want) {
+			t.Errorf("Expected %q in INFO:\n%s", want, info)
+		}
+	}
+	if strings.Contains(info, "# Commandstats") {
+		t.Errorf("Commandstats should only be included with INFO all:\n%s", info)
+	}
+
+	// 与发号器同名时按发号器处理
+	if info := command("INFO", "stats").Bulk; !strings.HasPrefix(info, "name:stats\n") {
+		t.Errorf("Expected dispenser info for 'stats', got %s", info)
+	}
+	info = command("INFO", "Commandstats", "server").Bulk
+	if !strings.HasPrefix(info, "# Server\r\n") || !strings.Contains(info, "cmdstat_get:calls=3,") || !strings.Contains(info, "cmdstat_info:calls=3,") {
+		t.Errorf("Unexpected INFO commandstats:\n%s", info)
+	}
+	if result := command("INFO", "missing"); result.Type != protocol.Error || result.Str != "ERR dispenser not found" {
+		t.Errorf("Expected dispenser not found, got %+v", result)
+	}
+	if result := command("INFO", "server", "missing"); result.Type != protocol.Error {
+		t.Errorf("Expected error for unknown section, got %+v", result)
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*", "order_id", true},
+		{"order_*", "order_id", true},
+		{"order_*", "user_id", false},
+		{"*_id", "order_id", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"a**b", "axxb", true},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
 }
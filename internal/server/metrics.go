@@ -0,0 +1,193 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/nicexiaonie/number-dispenser/internal/dispenser"
+	"github.com/nicexiaonie/number-dispenser/internal/metrics"
+)
+
+// metricsPrefix 所有指标名的前缀
+const metricsPrefix = "number_dispenser_"
+
+// errorKinds 每个发号器都输出的失败类型（没有失败时为0）
+var errorKinds = []string{
+	dispenser.ErrorKindExhausted,
+	dispenser.ErrorKindClockRollback,
+	dispenser.ErrorKindPersist,
+	dispenser.ErrorKindOther,
+}
+
+// startMetrics 启动 Prometheus /metrics 的 HTTP 服务
+// 监听失败时直接返回错误，避免配置错误被忽略
+func (s *Server) startMetrics() error {
+	listener, err := net.Listen("tcp", s.metricsAddr)
+	if err != nil {
+		return fmt.Errorf("failed to start metrics listener: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.serveMetrics)
+	s.metricsServer = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := s.metricsServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("Metrics server error: %v", err)
+		}
+	}()
+
+	log.Printf("Metrics available at http://%s/metrics", listener.Addr())
+	return nil
+}
+
+// serveMetrics 按 Prometheus 文本格式输出指标
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := s.writeMetrics(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.Write(buf.Bytes())
+}
+
+// writeMetrics 写出服务端和各发号器的指标
+func (s *Server) writeMetrics(buf *bytes.Buffer) error {
+	mw := metrics.NewWriter(buf)
+	s.writeServerMetrics(mw)
+	s.writeDispenserMetrics(mw)
+	return mw.Err()
+}
+
+// writeServerMetrics 连接、命令和持久化的指标
+func (s *Server) writeServerMetrics(mw *metrics.Writer) {
+	var uptime float64
+	if !s.startTime.IsZero() {
+		uptime = time.Since(s.startTime).Seconds()
+	}
+	gauge(mw, "uptime_seconds", "Seconds since the server started.", uptime)
+	gauge(mw, "connected_clients", "Number of client connections currently open.", float64(s.stats.connected.Load()))
+	counter(mw, "connections_received_total", "Total number of client connections accepted.", float64(s.stats.connections.Load()))
+	counter(mw, "commands_processed_total", "Total number of commands processed, including unknown commands.", float64(s.stats.commands.Load()))
+	counter(mw, "error_replies_total", "Total number of error replies sent to clients.", float64(s.stats.errors.Load()))
+
+	latency := s.stats.commandLatency()
+	mw.Family(metricsPrefix+"command_duration_seconds", metrics.TypeHistogram, "Time spent processing commands, by command.")
+	for _, cmd := range sortedKeys(latency) {
+		mw.Histogram(metricsPrefix+"command_duration_seconds", metrics.Labels{"command", cmd}, latency[cmd])
+	}
+
+	persists := s.stats.persistLatency()
+	mw.Family(metricsPrefix+"persist_duration_seconds", metrics.TypeHistogram, "Time spent in storage Save (op=save) and Flush (op=flush).")
+	for _, op := range sortedKeys(persists) {
+		mw.Histogram(metricsPrefix+"persist_duration_seconds", metrics.Labels{"op", op}, persists[op])
+	}
+}
+
+// dispenserSample 一个发号器在采集时的状态
+type dispenserSample struct {
+	name     string
+	cfg      dispenser.Config
+	stats    dispenser.DispenserStats
+	capacity dispenser.Capacity
+	bounded  bool
+}
+
+// writeDispenserMetrics 各发号器的指标，数据来自 GetStats
+// 同一指标的样本需连续输出，因此先采集所有发号器再按指标输出
+func (s *Server) writeDispenserMetrics(mw *metrics.Writer) {
+	s.mu.RLock()
+	samples := make([]dispenserSample, 0, len(s.dispensers))
+	for name, d := range s.dispensers {
+		sample := dispenserSample{name: name, cfg: d.GetConfig(), stats: d.GetStats()}
+		if bd, ok := dispenser.As[dispenser.BoundedDispenser](d); ok {
+			sample.capacity, sample.bounded = bd.Capacity()
+		}
+		samples = append(samples, sample)
+	}
+	s.mu.RUnlock()
+	sort.Slice(samples, func(i, j int) bool { return samples[i].name < samples[j].name })
+
+	gauge(mw, "dispensers", "Number of dispensers.", float64(len(samples)))
+
+	mw.Family(metricsPrefix+"dispenser_info", metrics.TypeGauge, "Dispenser type and persistence strategy, always 1.")
+	for _, d := range samples {
+		mw.Sample(metricsPrefix+"dispenser_info", metrics.Labels{"name", d.name, "type", strconv.Itoa(int(d.cfg.Type)), "auto_disk", string(d.cfg.AutoDisk)}, 1)
+	}
+
+	mw.Family(metricsPrefix+"generated_total", metrics.TypeCounter, "Total numbers generated by the dispenser.")
+	for _, d := range samples {
+		mw.Sample(metricsPrefix+"generated_total", metrics.Labels{"name", d.name}, float64(d.stats.TotalGenerated))
+	}
+
+	// pre-base 的浪费数是当前号段中尚未使用、重启后会丢弃的号码数，会随发号减少，因此用 gauge
+	mw.Family(metricsPrefix+"wasted", metrics.TypeGauge, "Numbers wasted by segment pre-allocation (pre-base: numbers that would be lost on restart).")
+	for _, d := range samples {
+		mw.Sample(metricsPrefix+"wasted", metrics.Labels{"name", d.name}, float64(d.stats.TotalWasted))
+	}
+
+	mw.Family(metricsPrefix+"errors_total", metrics.TypeCounter, "Total failed number generations, by kind.")
+	for _, d := range samples {
+		for _, kind := range errorKinds {
+			mw.Sample(metricsPrefix+"errors_total", metrics.Labels{"name", d.name, "kind", kind}, float64(d.stats.Errors[kind]))
+		}
+	}
+
+	// 号段策略（pre-base、pre-checkpoint、pre_close）
+	mw.Family(metricsPrefix+"segment_switches_total", metrics.TypeCounter, "Total switches to a preloaded segment.")
+	for _, d := range samples {
+		if d.stats.SegmentSize > 0 {
+			mw.Sample(metricsPrefix+"segment_switches_total", metrics.Labels{"name", d.name}, float64(d.stats.SegmentSwitches))
+		}
+	}
+	mw.Family(metricsPrefix+"segment_sync_allocations_total", metrics.TypeCounter, "Total segments allocated synchronously because the next segment was not ready.")
+	for _, d := range samples {
+		if d.stats.SegmentSize > 0 {
+			mw.Sample(metricsPrefix+"segment_sync_allocations_total", metrics.Labels{"name", d.name}, float64(d.stats.SyncAllocations))
+		}
+	}
+
+	// 号码空间有限的 Type 1、2
+	mw.Family(metricsPrefix+"capacity_remaining", metrics.TypeGauge, "Numbers left before the dispenser is exhausted.")
+	for _, d := range samples {
+		if d.bounded {
+			mw.Sample(metricsPrefix+"capacity_remaining", metrics.Labels{"name", d.name}, float64(d.capacity.Remaining))
+		}
+	}
+	mw.Family(metricsPrefix+"capacity_total", metrics.TypeGauge, "Size of the dispenser's number space.")
+	for _, d := range samples {
+		if d.bounded {
+			mw.Sample(metricsPrefix+"capacity_total", metrics.Labels{"name", d.name}, float64(d.capacity.Total))
+		}
+	}
+}
+
+// gauge 写出一个没有标签的 gauge
+func gauge(mw *metrics.Writer, name, help string, value float64) {
+	mw.Family(metricsPrefix+name, metrics.TypeGauge, help)
+	mw.Sample(metricsPrefix+name, nil, value)
+}
+
+// counter 写出一个没有标签的 counter
+func counter(mw *metrics.Writer, name, help string, value float64) {
+	mw.Family(metricsPrefix+name, metrics.TypeCounter, help)
+	mw.Sample(metricsPrefix+name, nil, value)
+}
+
+// sortedKeys 返回按字典序排列的键
+func sortedKeys(m map[string]*metrics.Histogram) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nicexiaonie/number-dispenser/internal/dispenser"
+	"github.com/nicexiaonie/number-dispenser/internal/metrics"
+	"github.com/nicexiaonie/number-dispenser/internal/protocol"
+)
+
+func TestServeMetrics(t *testing.T) {
+	srv, _ := newTestServer(t, "ticket_no")
+	for name, cfg := range map[string]dispenser.Config{
+		"ticket_no": {Type: dispenser.TypeNumericIncremental, IncrMode: dispenser.IncrModeFixed, Length: 1, Starting: 0, AutoDisk: dispenser.StrategyPreBase, SegmentSize: 4},
+		"session":   {Type: dispenser.TypeUUID, AutoDisk: dispenser.StrategyMemory},
+	} {
+		d, err := srv.factory.CreateDispenser(name, cfg)
+		if err != nil {
+			t.Fatalf("Failed to create dispenser: %v", err)
+		}
+		srv.dispensers[name] = d
+	}
+
+	command := func(args ...string) protocol.Value {
+		val := protocol.Value{Type: protocol.Array}
+		for _, arg := range args {
+			val.Array = append(val.Array, protocol.Value{Type: protocol.BulkString, Bulk: arg})
+		}
+		return srv.processCommand(val)
+	}
+	command("GETN", "ticket_no", "10")
+	command("GET", "ticket_no") // 号码耗尽
+	command("GET", "session")
+
+	rec := httptest.NewRecorder()
+	srv.serveMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != metrics.ContentType {
+		t.Errorf("Unexpected Content-Type %q", ct)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE number_dispenser_generated_total counter\n",
+		`number_dispenser_generated_total{name="ticket_no"} 10` + "\n",
+		`number_dispenser_errors_total{name="ticket_no",kind="exhausted"} 1` + "\n",
+		`number_dispenser_errors_total{name="session",kind="exhausted"} 0` + "\n",
+		`number_dispenser_dispenser_info{name="session",type="5",auto_disk="memory"} 1` + "\n",
+		`number_dispenser_capacity_remaining{name="ticket_no"} 0` + "\n",
+		`number_dispenser_command_duration_seconds_count{command="get"} 2` + "\n",
+		`number_dispenser_command_duration_seconds_bucket{command="getn",le="+Inf"} 1` + "\n",
+		"number_dispenser_commands_processed_total 3\n",
+		"number_dispenser_error_replies_total 1\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in metrics:\n%s", want, body)
+		}
+	}
+
+	// 号段计数只在号段策略的发号器上输出
+	if strings.Contains(body, `segment_switches_total{name="session"}`) || !strings.Contains(body, `segment_sync_allocations_total{name="ticket_no"}`) {
+		t.Errorf("Unexpected segment metrics:\n%s", body)
+	}
+}
@@ -3,14 +3,20 @@ package server
 import (
 	"fmt"
 	"log"
+	"maps"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/nicexiaonie/number-dispenser/internal/config"
 	"github.com/nicexiaonie/number-dispenser/internal/dispenser"
+	"github.com/nicexiaonie/number-dispenser/internal/metrics"
 	"github.com/nicexiaonie/number-dispenser/internal/protocol"
 	"github.com/nicexiaonie/number-dispenser/internal/storage"
 )
@@ -25,29 +31,116 @@ type Server struct {
 	mu         sync.RWMutex
 	wg         sync.WaitGroup
 	shutdown   chan struct{}
+
+	watchMu sync.Mutex
+	watches map[string]*capacityWatch // 容量监控（按名称记录，修改参数重建发号器时保留）
+
+	nodeID          string               // 节点ID
+	readTimeout     time.Duration        // 客户端读超时
+	persistInterval time.Duration        // 定期持久化间隔
+	storageConfig   config.StorageConfig // 存储配置（INFO persistence 显示）
+
+	startTime time.Time   // 启动时间
+	stats     serverStats // 连接和命令统计
+
+	metricsAddr   string       // Prometheus /metrics 的 HTTP 监听地址，为空时不启动
+	metricsServer *http.Server // /metrics 的 HTTP 服务
+}
+
+// serverStats 连接和命令统计（INFO 使用）
+type serverStats struct {
+	connected   atomic.Int64 // 当前连接数
+	connections atomic.Int64 // 累计接受的连接数
+	commands    atomic.Int64 // 累计处理的命令数
+	errors      atomic.Int64 // 累计返回的错误数
+
+	mu       sync.Mutex
+	latency  map[string]*metrics.Histogram // 各命令的处理耗时（小写命令名），样本数即调用次数
+	persists map[string]*metrics.Histogram // 存储操作的耗时（save、flush）
+}
+
+// record 记录一次命令调用，known 为 false 时（未知命令）不计入各命令的统计
+func (st *serverStats) record(cmd string, known bool, resp protocol.Value, elapsed time.Duration) {
+	st.commands.Add(1)
+	if resp.Type == protocol.Error {
+		st.errors.Add(1)
+	}
+	if !known {
+		return
+	}
+
+	st.mu.Lock()
+	h := histogramFor(&st.latency, strings.ToLower(cmd))
+	st.mu.Unlock()
+	h.Observe(elapsed.Seconds())
+}
+
+// observePersist 记录一次存储操作从 start 开始的耗时
+func (st *serverStats) observePersist(op string, start time.Time) {
+	st.mu.Lock()
+	h := histogramFor(&st.persists, op)
+	st.mu.Unlock()
+	h.ObserveSince(start)
+}
+
+// commandLatency 返回各命令耗时直方图的快照
+func (st *serverStats) commandLatency() map[string]*metrics.Histogram {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return maps.Clone(st.latency)
+}
+
+// persistLatency 返回存储操作耗时直方图的快照
+func (st *serverStats) persistLatency() map[string]*metrics.Histogram {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return maps.Clone(st.persists)
+}
+
+// histogramFor 返回 key 对应的直方图，不存在时创建（调用方需持有 mu）
+func histogramFor(m *map[string]*metrics.Histogram, key string) *metrics.Histogram {
+	if *m == nil {
+		*m = make(map[string]*metrics.Histogram)
+	}
+	h, ok := (*m)[key]
+	if !ok {
+		h = metrics.NewHistogram(metrics.LatencyBuckets)
+		(*m)[key] = h
+	}
+	return h
 }
 
 // NewServer creates a new server
-func NewServer(addr string, dataDir string) (*Server, error) {
-	st, err := storage.NewFileStorage(dataDir, true)
+func NewServer(cfg config.Config) (*Server, error) {
+	st, err := storage.NewFileStorageWithInterval(cfg.Storage.DataDir, cfg.Storage.AutoSave, cfg.Storage.AutoSaveInterval)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create storage: %w", err)
 	}
 
-	// 创建持久化函数
+	// 创建持久化函数（s 在加载发号器之前创建，持久化函数只会在此之后调用）
+	var s *Server
 	persistFunc := func(name string, cfg dispenser.Config, current int64) error {
+		defer s.stats.observePersist("save", time.Now())
 		return st.Save(name, cfg, current)
 	}
 
 	// 创建发号器工厂
 	factory := dispenser.NewDispenserFactory(persistFunc)
-
-	s := &Server{
-		addr:       addr,
-		storage:    st,
-		dispensers: make(map[string]dispenser.NumberDispenser),
-		factory:    factory,
-		shutdown:   make(chan struct{}),
+	factory.SetSegmentDefaults(cfg.Cluster.SegmentSize, cfg.Cluster.CheckpointInterval)
+	factory.SetNodeID(cfg.Cluster.NodeID)
+
+	s = &Server{
+		addr:            cfg.Server.Addr,
+		storage:         st,
+		dispensers:      make(map[string]dispenser.NumberDispenser),
+		factory:         factory,
+		shutdown:        make(chan struct{}),
+		nodeID:          cfg.Cluster.NodeID,
+		readTimeout:     cfg.Server.ReadTimeout,
+		persistInterval: cfg.Server.PersistInterval,
+		storageConfig:   cfg.Storage,
+		startTime:       time.Now(),
+		metricsAddr:     cfg.Server.MetricsAddr,
 	}
 
 	// Load existing dispensers from storage
@@ -68,6 +161,13 @@ func (s *Server) Start() error {
 	s.listener = listener
 	log.Printf("Number dispenser server listening on %s", s.addr)
 
+	if s.metricsAddr != "" {
+		if err := s.startMetrics(); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+
 	// Handle graceful shutdown
 	go s.handleShutdown()
 
@@ -99,6 +199,9 @@ func (s *Server) Stop() error {
 	if s.listener != nil {
 		s.listener.Close()
 	}
+	if s.metricsServer != nil {
+		s.metricsServer.Close()
+	}
 
 	s.wg.Wait()
 
@@ -120,6 +223,10 @@ func (s *Server) handleConnection(conn net.Conn) {
 	defer s.wg.Done()
 	defer conn.Close()
 
+	s.stats.connections.Add(1)
+	s.stats.connected.Add(1)
+	defer s.stats.connected.Add(-1)
+
 	reader := protocol.NewReader(conn)
 	writer := protocol.NewWriter(conn)
 
@@ -133,7 +240,7 @@ func (s *Server) handleConnection(conn net.Conn) {
 		}
 
 		// Set read deadline to detect client disconnect
-		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		conn.SetReadDeadline(time.Now().Add(s.readTimeout))
 
 		val, err := reader.ReadValue()
 		if err != nil {
@@ -159,8 +266,11 @@ func (s *Server) handleConnection(conn net.Conn) {
 
 // processCommand processes a Redis command
 func (s *Server) processCommand(val protocol.Value) protocol.Value {
+	start := time.Now()
 	if val.Type != protocol.Array || len(val.Array) == 0 {
-		return protocol.Value{Type: protocol.Error, Str: "ERR invalid command format"}
+		resp := protocol.Value{Type: protocol.Error, Str: "ERR invalid command format"}
+		s.stats.record("", false, resp, time.Since(start))
+		return resp
 	}
 
 	// Extract command and arguments
@@ -169,27 +279,60 @@ func (s *Server) processCommand(val protocol.Value) protocol.Value {
 		if v.Type == protocol.BulkString {
 			args[i] = v.Bulk
 		} else {
-			return protocol.Value{Type: protocol.Error, Str: "ERR invalid argument type"}
+			resp := protocol.Value{Type: protocol.Error, Str: "ERR invalid argument type"}
+			s.stats.record("", false, resp, time.Since(start))
+			return resp
 		}
 	}
 
-	cmd := args[0]
+	resp, known := s.dispatch(args[0], args[1:])
+	s.stats.record(args[0], known, resp, time.Since(start))
+	return resp
+}
 
+// dispatch 执行命令，未知命令时 known 为 false
+func (s *Server) dispatch(cmd string, args []string) (resp protocol.Value, known bool) {
 	switch cmd {
 	case "HSET", "hset":
-		return s.handleHSet(args[1:])
+		return s.handleHSet(args), true
+	case "HGETALL", "hgetall":
+		return s.handleHGetAll(args), true
 	case "GET", "get":
-		return s.handleGet(args[1:])
+		return s.handleGet(args), true
+	case "GETN", "getn":
+		return s.handleGetN(args), true
+	case "ALLOCSEG", "allocseg":
+		return s.handleAllocSeg(args), true
+	case "DECODE", "decode":
+		return s.handleDecode(args), true
+	case "VALIDATE", "validate":
+		return s.handleValidate(args), true
+	case "RELEASE", "release":
+		return s.handleRelease(args), true
+	case "RESERVE", "reserve":
+		return s.handleReserve(args), true
+	case "UNRESERVE", "unreserve":
+		return s.handleUnreserve(args), true
+	case "CLAIM", "claim":
+		return s.handleClaim(args), true
+	case "RESERVATIONS", "reservations":
+		return s.handleReservations(args), true
 	case "DEL", "del":
-		return s.handleDel(args[1:])
+		return s.handleDel(args), true
+	case "SCAN", "scan":
+		return s.handleScan(args), true
+	case "KEYS", "keys":
+		return s.handleKeys(args), true
+	case "DISPENSERS", "dispensers":
+		return s.handleDispensers(args), true
 	case "INFO", "info":
-		return s.handleInfo(args[1:])
+		return s.handleInfo(args), true
 	case "PING", "ping":
-		return protocol.Value{Type: protocol.SimpleString, Str: "PONG"}
+		return protocol.Value{Type: protocol.SimpleString, Str: "PONG"}, true
 	case "QUIT", "quit":
-		return protocol.Value{Type: protocol.SimpleString, Str: "OK"}
+		return protocol.Value{Type: protocol.SimpleString, Str: "OK"}, true
 	default:
-		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR unknown command '%s'", cmd)}
+		return protocol.Value{Type: protocol.Error, Str: fmt.Sprintf("ERR unknown command '%s'", cmd)}, false
 	}
 }
 
@@ -207,7 +350,25 @@ func (s *Server) loadDispensers() error {
 			log.Printf("Failed to restore dispenser %s: %v", name, err)
 			continue
 		}
+		if pd, ok := dispenser.As[dispenser.PeriodicDispenser](d); ok {
+			pd.SetPeriod(data.Period)
+		}
 		d.SetCurrent(data.Current)
+		if err := s.attachUsedLog(name, d); err != nil {
+			log.Printf("Failed to restore issued numbers of dispenser %s: %v", name, err)
+			d.Shutdown()
+			continue
+		}
+		if err := s.attachPool(name, d); err != nil {
+			log.Printf("Failed to restore recycle pool of dispenser %s: %v", name, err)
+			d.Shutdown()
+			continue
+		}
+		if err := s.attachReservations(name, d); err != nil {
+			log.Printf("Failed to restore reservations of dispenser %s: %v", name, err)
+			d.Shutdown()
+			continue
+		}
 		s.dispensers[name] = d
 		log.Printf("Restored dispenser: %s (type=%d, strategy=%s, current=%d)",
 			name, data.Config.Type, data.Config.AutoDisk, data.Current)
@@ -222,13 +383,214 @@ func (s *Server) persistAll() error {
 	defer s.mu.RUnlock()
 
 	for name, d := range s.dispensers {
-		if err := s.storage.Save(name, d.GetConfig(), d.GetCurrent()); err != nil {
+		if err := s.saveDispenser(name, d.GetConfig(), d); err != nil {
 			log.Printf("Failed to persist dispenser %s: %v", name, err)
 		}
 	}
 
 	// Flush to disk
+	return s.flushStorage()
+}
+
+// saveDispenser saves the config and current position of a dispenser
+// 周期重置的发号器同时保存当前周期
+func (s *Server) saveDispenser(name string, cfg dispenser.Config, d dispenser.NumberDispenser) error {
+	defer s.stats.observePersist("save", time.Now())
+	if pd, ok := dispenser.As[dispenser.PeriodicDispenser](d); ok && cfg.ResetPeriod != "" {
+		return s.storage.SaveWithPeriod(name, cfg, d.GetCurrent(), pd.GetPeriod())
+	}
+	return s.storage.Save(name, cfg, d.GetCurrent())
+}
+
+// attachUsedLog 为 Type 1 发号器恢复已发号码，并将之后发出的号码增量写入去重日志
+// memory 策略不持久化，不使用去重日志
+func (s *Server) attachUsedLog(name string, d dispenser.NumberDispenser) error {
+	cfg := d.GetConfig()
+	ud, ok := dispenser.As[dispenser.UniqueDispenser](d)
+	if !ok || cfg.Type != dispenser.TypeNumericRandom || cfg.AutoDisk == dispenser.StrategyMemory {
+		return nil
+	}
+
+	values, err := s.storage.LoadUsed(name)
+	if err != nil {
+		return err
+	}
+	ud.RestoreUsed(values)
+
+	ud.SetUsedLog(func(values []int64) error {
+		return s.storage.AppendUsed(name, values, cfg.UniqueCacheSize)
+	})
+	return nil
+}
+
+// carryUsed 将重建前发号器的已发号码带到新发号器，避免重建后重复发号
+// 旧发号器为 memory 策略时这些号码不在去重日志中，新发号器需要持久化时补写日志
+func (s *Server) carryUsed(name string, old, d dispenser.NumberDispenser) error {
+	oldUsed, ok := dispenser.As[dispenser.UniqueDispenser](old)
+	if !ok {
+		return nil
+	}
+	ud, ok := dispenser.As[dispenser.UniqueDispenser](d)
+	if !ok {
+		return nil
+	}
+	values := oldUsed.UsedValues()
+	if len(values) == 0 {
+		return nil
+	}
+
+	cfg := d.GetConfig()
+	if old.GetConfig().AutoDisk == dispenser.StrategyMemory && cfg.AutoDisk != dispenser.StrategyMemory {
+		if err := s.storage.AppendUsed(name, values, cfg.UniqueCacheSize); err != nil {
+			return err
+		}
+	}
+	ud.RestoreUsed(values)
+	return nil
+}
+
+// attachPool 为启用回收的发号器恢复回收池，并在回收池变化后立即保存
+func (s *Server) attachPool(name string, d dispenser.NumberDispenser) error {
+	rd, ok := dispenser.As[dispenser.RecyclableDispenser](d)
+	if !ok || !d.GetConfig().Recycle {
+		return nil
+	}
+
+	entries, err := s.storage.LoadPool(name)
+	if err != nil {
+		return err
+	}
+	rd.RestorePool(entries)
+
+	rd.SetPoolLog(func(entries []dispenser.PoolEntry) error {
+		return s.storage.SavePool(name, entries)
+	})
+	return nil
+}
+
+// attachReservations 为 Type 1、2 发号器恢复保留号码，并在保留状态变化后立即保存
+func (s *Server) attachReservations(name string, d dispenser.NumberDispenser) error {
+	cfg := d.GetConfig()
+	rd, ok := dispenser.As[dispenser.ReservableDispenser](d)
+	if !ok || (cfg.Type != dispenser.TypeNumericRandom && cfg.Type != dispenser.TypeNumericIncremental) || cfg.ResetPeriod != "" {
+		return nil
+	}
+
+	state, err := s.storage.LoadReservations(name)
+	if err != nil {
+		return err
+	}
+	rd.RestoreReservations(state)
+
+	rd.SetReservationLog(func(state dispenser.ReservationState) error {
+		return s.storage.SaveReservations(name, state)
+	})
+	return nil
+}
+
+// ============================================
+// 容量监控
+// ============================================
+
+// capacityWatch 发号器的容量监控状态
+type capacityWatch struct {
+	meter  *dispenser.UsageMeter
+	warned bool // 使用率已达到 warn_at（越过和回落时各记录一次日志）
+}
+
+// watch 返回发号器的容量监控状态，不存在时创建
+func (s *Server) watch(name string) *capacityWatch {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	if s.watches == nil {
+		s.watches = make(map[string]*capacityWatch)
+	}
+	w, ok := s.watches[name]
+	if !ok {
+		w = &capacityWatch{meter: dispenser.NewUsageMeter()}
+		s.watches[name] = w
+	}
+	return w
+}
+
+// forgetWatch 删除发号器的容量监控状态
+func (s *Server) forgetWatch(name string) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	delete(s.watches, name)
+}
+
+// forecast 计算发号器的剩余容量和预计耗尽时间，号码空间无限的发号器返回 false
+// 每次计算都会记录一次采样，并检查是否越过 warn_at
+func (s *Server) forecast(name string, d dispenser.NumberDispenser) (dispenser.Forecast, bool) {
+	bd, ok := dispenser.As[dispenser.BoundedDispenser](d)
+	if !ok {
+		return dispenser.Forecast{}, false
+	}
+	c, ok := bd.Capacity()
+	if !ok {
+		return dispenser.Forecast{}, false
+	}
+
+	w := s.watch(name)
+	f := w.meter.Observe(c)
+
+	cfg := d.GetConfig()
+	warn := warnReached(cfg, f)
+	s.watchMu.Lock()
+	changed := w.warned != warn
+	w.warned = warn
+	s.watchMu.Unlock()
+
+	if changed && warn {
+		log.Printf("Dispenser %s reached warn_at %g: %s", name, cfg.WarnAt, capacityWarning(f))
+	} else if changed {
+		log.Printf("Dispenser %s is back below warn_at %g: %.2f%% used", name, cfg.WarnAt, f.Utilization()*100)
+	}
+	return f, true
+}
+
+// observeIssued 发号后记录容量变化，每个采样间隔最多计算一次
+func (s *Server) observeIssued(name string, d dispenser.NumberDispenser) {
+	cfg := d.GetConfig()
+	if cfg.Type != dispenser.TypeNumericRandom && cfg.Type != dispenser.TypeNumericIncremental {
+		return
+	}
+	if s.watch(name).meter.Due() {
+		s.forecast(name, d)
+	}
+}
+
+// warnReached 检查使用率是否已达到 warn_at
+func warnReached(cfg dispenser.Config, f dispenser.Forecast) bool {
+	return cfg.WarnAt > 0 && f.Utilization() >= cfg.WarnAt
+}
+
+// capacityWarning 返回容量告警的文本，如 "92.00% used, 8000 remaining, exhausts in 2h13m20s"
+func capacityWarning(f dispenser.Forecast) string {
+	warning := fmt.Sprintf("%.2f%% used, %d remaining", f.Utilization()*100, f.Remaining)
+	if f.ExhaustsIn > 0 {
+		warning += ", exhausts in " + exhaustsIn(f)
+	}
+	return warning
+}
+
+// exhaustsIn 返回预计耗尽时间的文本：已耗尽为 0s，没有消耗时为 unknown
+func exhaustsIn(f dispenser.Forecast) string {
+	if f.Remaining == 0 {
+		return "0s"
+	}
+	if f.ExhaustsIn == 0 {
+		return "unknown"
+	}
+	return max(f.ExhaustsIn.Round(time.Second), time.Second).String()
+}
+
+// flushStorage forces buffered storage writes to disk
+func (s *Server) flushStorage() error {
 	if fs, ok := s.storage.(*storage.FileStorage); ok {
+		defer s.stats.observePersist("flush", time.Now())
 		return fs.Flush()
 	}
 
@@ -237,7 +599,7 @@ func (s *Server) persistAll() error {
 
 // periodicPersist periodically persists dispenser state
 func (s *Server) periodicPersist() {
-	ticker := time.NewTicker(10 * time.Second)
+	ticker := time.NewTicker(s.persistInterval)
 	defer ticker.Stop()
 
 	for {
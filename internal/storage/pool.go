@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/nicexiaonie/number-dispenser/internal/dispenser"
+)
+
+// 回收池：每个发号器一个 JSON 文件，每次变化后整体重写（临时文件 + 重命名）
+// 回收池通常不大（房间号、座位号等），整体重写保证取号和归还都立即落盘
+const poolDir = "pool"
+
+// poolPath 返回发号器的回收池文件路径（名称转义后作为文件名）
+func (fs *FileStorage) poolPath(name string) string {
+	return filepath.Join(fs.dataDir, poolDir, url.PathEscape(name)+".json")
+}
+
+// SavePool saves the recycle pool of a dispenser
+func (fs *FileStorage) SavePool(name string, entries []dispenser.PoolEntry) error {
+	fs.poolMu.Lock()
+	defer fs.poolMu.Unlock()
+
+	path := fs.poolPath(name)
+	if len(entries) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, path)
+}
+
+// LoadPool loads the recycle pool of a dispenser in release order
+func (fs *FileStorage) LoadPool(name string) ([]dispenser.PoolEntry, error) {
+	fs.poolMu.Lock()
+	defer fs.poolMu.Unlock()
+
+	data, err := os.ReadFile(fs.poolPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []dispenser.PoolEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// deletePool 删除回收池文件
+func (fs *FileStorage) deletePool(name string) error {
+	fs.poolMu.Lock()
+	defer fs.poolMu.Unlock()
+
+	if err := os.Remove(fs.poolPath(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
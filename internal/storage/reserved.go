@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/nicexiaonie/number-dispenser/internal/dispenser"
+)
+
+// 保留号码：每个发号器一个 JSON 文件，每次变化后整体重写（临时文件 + 重命名）
+// 保留状态以合并后的区间保存，大段保留也只占很少空间
+const reservedDir = "reserved"
+
+// reservedPath 返回发号器的保留号码文件路径（名称转义后作为文件名）
+func (fs *FileStorage) reservedPath(name string) string {
+	return filepath.Join(fs.dataDir, reservedDir, url.PathEscape(name)+".json")
+}
+
+// SaveReservations saves the reserved and claimed values of a dispenser
+func (fs *FileStorage) SaveReservations(name string, state dispenser.ReservationState) error {
+	fs.reservedMu.Lock()
+	defer fs.reservedMu.Unlock()
+
+	path := fs.reservedPath(name)
+	if len(state.Reserved) == 0 && len(state.Claimed) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, path)
+}
+
+// LoadReservations loads the reserved and claimed values of a dispenser
+func (fs *FileStorage) LoadReservations(name string) (dispenser.ReservationState, error) {
+	fs.reservedMu.Lock()
+	defer fs.reservedMu.Unlock()
+
+	var state dispenser.ReservationState
+	data, err := os.ReadFile(fs.reservedPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return state, err
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, err
+	}
+	return state, nil
+}
+
+// deleteReservations 删除保留号码文件
+func (fs *FileStorage) deleteReservations(name string) error {
+	fs.reservedMu.Lock()
+	defer fs.reservedMu.Unlock()
+
+	if err := os.Remove(fs.reservedPath(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -13,37 +13,80 @@ import (
 // Storage provides persistence for dispensers
 type Storage interface {
 	Save(name string, cfg dispenser.Config, current int64) error
+	SaveWithPeriod(name string, cfg dispenser.Config, current int64, period string) error
 	Load(name string) (dispenser.Config, int64, error)
 	Delete(name string) error
 	ListAll() (map[string]DispenserData, error)
+
+	// AppendUsed 追加 Type 1 已发号码，keep > 0 时日志只需保留最后 keep 个
+	AppendUsed(name string, values []int64, keep int) error
+	// LoadUsed 按发号顺序读取 Type 1 已发号码
+	LoadUsed(name string) ([]int64, error)
+
+	// SavePool 保存回收池的完整内容
+	SavePool(name string, entries []dispenser.PoolEntry) error
+	// LoadPool 按释放顺序读取回收池
+	LoadPool(name string) ([]dispenser.PoolEntry, error)
+
+	// SaveReservations 保存保留号码的完整状态
+	SaveReservations(name string, state dispenser.ReservationState) error
+	// LoadReservations 读取保留号码
+	LoadReservations(name string) (dispenser.ReservationState, error)
 }
 
 // DispenserData represents the persisted data of a dispenser
 type DispenserData struct {
 	Config  dispenser.Config `json:"config"`
 	Current int64            `json:"current"`
+	Period  string           `json:"period,omitempty"` // 周期重置发号器的当前周期
 	Updated time.Time        `json:"updated"`
 }
 
+// DefaultAutoSaveInterval is the default interval of the auto-save loop
+const DefaultAutoSaveInterval = 5 * time.Second
+
 // FileStorage implements Storage using local file system
 type FileStorage struct {
-	mu       sync.RWMutex
-	dataDir  string
-	data     map[string]DispenserData
-	autoSave bool
-	dirty    bool
+	mu               sync.RWMutex
+	dataDir          string
+	data             map[string]DispenserData
+	autoSave         bool
+	autoSaveInterval time.Duration
+	dirty            bool
+	lastSave         time.Time // 上次成功写盘的时间（启动时为加载时间）
+	lastSaveErr      error     // 最近一次写盘的错误
+
+	usedMu    sync.Mutex
+	usedCount map[string]int // 各去重日志中的号码数（用于判断是否需要压缩）
+
+	poolMu     sync.Mutex
+	reservedMu sync.Mutex
 }
 
 // NewFileStorage creates a new file storage
 func NewFileStorage(dataDir string, autoSave bool) (*FileStorage, error) {
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		return nil, err
+	return NewFileStorageWithInterval(dataDir, autoSave, DefaultAutoSaveInterval)
+}
+
+// NewFileStorageWithInterval creates a new file storage with a custom auto-save interval
+func NewFileStorageWithInterval(dataDir string, autoSave bool, autoSaveInterval time.Duration) (*FileStorage, error) {
+	for _, dir := range []string{usedDir, poolDir, reservedDir} {
+		if err := os.MkdirAll(filepath.Join(dataDir, dir), 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	if autoSaveInterval <= 0 {
+		autoSaveInterval = DefaultAutoSaveInterval
 	}
 
 	fs := &FileStorage{
-		dataDir:  dataDir,
-		data:     make(map[string]DispenserData),
-		autoSave: autoSave,
+		dataDir:          dataDir,
+		data:             make(map[string]DispenserData),
+		autoSave:         autoSave,
+		autoSaveInterval: autoSaveInterval,
+		lastSave:         time.Now(),
+		usedCount:        make(map[string]int),
 	}
 
 	// Load existing data
@@ -61,12 +104,18 @@ func NewFileStorage(dataDir string, autoSave bool) (*FileStorage, error) {
 
 // Save saves dispenser data
 func (fs *FileStorage) Save(name string, cfg dispenser.Config, current int64) error {
+	return fs.SaveWithPeriod(name, cfg, current, "")
+}
+
+// SaveWithPeriod saves dispenser data together with the period of a periodic dispenser
+func (fs *FileStorage) SaveWithPeriod(name string, cfg dispenser.Config, current int64, period string) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
 	fs.data[name] = DispenserData{
 		Config:  cfg,
 		Current: current,
+		Period:  period,
 		Updated: time.Now(),
 	}
 	fs.dirty = true
@@ -99,6 +148,16 @@ func (fs *FileStorage) Delete(name string) error {
 	delete(fs.data, name)
 	fs.dirty = true
 
+	if err := fs.deleteUsed(name); err != nil {
+		return err
+	}
+	if err := fs.deletePool(name); err != nil {
+		return err
+	}
+	if err := fs.deleteReservations(name); err != nil {
+		return err
+	}
+
 	if !fs.autoSave {
 		return fs.saveToDisk()
 	}
@@ -131,8 +190,34 @@ func (fs *FileStorage) Flush() error {
 	return fs.saveToDisk()
 }
 
-// saveToDisk saves data to disk (must be called with lock held)
+// SaveStatus 主数据文件的写盘状态
+type SaveStatus struct {
+	LastSave time.Time // 上次成功写盘的时间（从未写盘时为启动时间）
+	LastErr  error     // 最近一次写盘的错误，写盘成功后清空
+	Dirty    bool      // 是否有尚未写盘的修改
+}
+
+// SaveStatus returns the state of the last write to disk
+func (fs *FileStorage) SaveStatus() SaveStatus {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return SaveStatus{LastSave: fs.lastSave, LastErr: fs.lastSaveErr, Dirty: fs.dirty}
+}
+
+// saveToDisk saves data to disk and records the result (must be called with lock held)
 func (fs *FileStorage) saveToDisk() error {
+	err := fs.writeToDisk()
+	fs.lastSaveErr = err
+	if err == nil {
+		fs.lastSave = time.Now()
+		fs.dirty = false
+	}
+	return err
+}
+
+// writeToDisk writes data to disk atomically
+func (fs *FileStorage) writeToDisk() error {
 	tmpFile := filepath.Join(fs.dataDir, "dispensers.json.tmp")
 	finalFile := filepath.Join(fs.dataDir, "dispensers.json")
 
@@ -152,7 +237,6 @@ func (fs *FileStorage) saveToDisk() error {
 		return err
 	}
 
-	fs.dirty = false
 	return nil
 }
 
@@ -173,7 +257,7 @@ func (fs *FileStorage) loadFromDisk() error {
 
 // autoSaveLoop periodically saves dirty data to disk
 func (fs *FileStorage) autoSaveLoop() {
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(fs.autoSaveInterval)
 	defer ticker.Stop()
 
 	for range ticker.C {
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"encoding/binary"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Type 1 已发号码日志：每个发号器一个文件，按发号顺序追加 8 字节小端整数
+// 日志只追加，压缩时整体重写；进程崩溃后最多丢失未写完的最后一批
+const usedDir = "used"
+
+// usedPath 返回发号器的去重日志路径（名称转义后作为文件名）
+func (fs *FileStorage) usedPath(name string) string {
+	return filepath.Join(fs.dataDir, usedDir, url.PathEscape(name)+".log")
+}
+
+// AppendUsed appends issued values to the used log of a Type 1 dispenser
+// keep > 0 时日志超过 2*keep 条后压缩为最后 keep 条
+func (fs *FileStorage) AppendUsed(name string, values []int64, keep int) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	fs.usedMu.Lock()
+	defer fs.usedMu.Unlock()
+
+	buf := make([]byte, 8*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(buf[8*i:], uint64(v))
+	}
+
+	path := fs.usedPath(name)
+	count, ok := fs.usedCount[name]
+	if !ok {
+		// 首次追加：统计已有条数，并截掉崩溃时写了一半的记录，避免后续记录错位
+		if info, err := os.Stat(path); err == nil {
+			size := info.Size()
+			if size%8 != 0 {
+				if err := os.Truncate(path, size-size%8); err != nil {
+					return err
+				}
+			}
+			count = int(size / 8)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	count += len(values)
+	fs.usedCount[name] = count
+
+	if keep > 0 && count > 2*keep {
+		return fs.compactUsed(name, keep)
+	}
+	return nil
+}
+
+// LoadUsed loads the issued values of a Type 1 dispenser in issue order
+func (fs *FileStorage) LoadUsed(name string) ([]int64, error) {
+	fs.usedMu.Lock()
+	defer fs.usedMu.Unlock()
+
+	return fs.readUsed(name)
+}
+
+// readUsed 读取去重日志，忽略末尾不完整的记录（调用方需持有 usedMu）
+func (fs *FileStorage) readUsed(name string) ([]int64, error) {
+	data, err := os.ReadFile(fs.usedPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	values := make([]int64, len(data)/8)
+	for i := range values {
+		values[i] = int64(binary.LittleEndian.Uint64(data[8*i:]))
+	}
+	return values, nil
+}
+
+// compactUsed 将去重日志重写为最后 keep 条（调用方需持有 usedMu）
+func (fs *FileStorage) compactUsed(name string, keep int) error {
+	values, err := fs.readUsed(name)
+	if err != nil {
+		return err
+	}
+	if len(values) > keep {
+		values = values[len(values)-keep:]
+	}
+
+	buf := make([]byte, 8*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(buf[8*i:], uint64(v))
+	}
+
+	path := fs.usedPath(name)
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, buf, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile, path); err != nil {
+		return err
+	}
+
+	fs.usedCount[name] = len(values)
+	return nil
+}
+
+// deleteUsed 删除去重日志
+func (fs *FileStorage) deleteUsed(name string) error {
+	fs.usedMu.Lock()
+	defer fs.usedMu.Unlock()
+
+	delete(fs.usedCount, name)
+	if err := os.Remove(fs.usedPath(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+// 测试去重日志重新打开后继续追加，按发号顺序读出
+func TestAppendUsed_Reopen(t *testing.T) {
+	dir := t.TempDir()
+
+	fs, err := NewFileStorage(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := fs.AppendUsed("coupon_no", []int64{42, 7}, 0); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	if err := fs.AppendUsed("coupon_no", []int64{19}, 0); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+
+	reopened, err := NewFileStorage(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	if err := reopened.AppendUsed("coupon_no", []int64{88, 3}, 0); err != nil {
+		t.Fatalf("Failed to append after reopen: %v", err)
+	}
+
+	values, err := reopened.LoadUsed("coupon_no")
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if want := []int64{42, 7, 19, 88, 3}; !slices.Equal(values, want) {
+		t.Errorf("Expected %v, got %v", want, values)
+	}
+
+	// 没有日志的发号器
+	if values, err := reopened.LoadUsed("missing"); err != nil || len(values) != 0 {
+		t.Errorf("Expected no values for missing log, got %v (err=%v)", values, err)
+	}
+}
+
+// 测试崩溃时写了一半的记录：读取时忽略，重启后首次追加前截掉，后续记录不错位
+func TestAppendUsed_PartialRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	fs, err := NewFileStorage(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := fs.AppendUsed("coupon_no", []int64{11, 22}, 0); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+
+	// 模拟崩溃：末尾只写入了 3 个字节
+	f, err := os.OpenFile(fs.usedPath("coupon_no"), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	f.Write([]byte{0xff, 0xff, 0xff})
+	f.Close()
+
+	reopened, err := NewFileStorage(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	values, err := reopened.LoadUsed("coupon_no")
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if want := []int64{11, 22}; !slices.Equal(values, want) {
+		t.Errorf("Expected partial record ignored %v, got %v", want, values)
+	}
+
+	if err := reopened.AppendUsed("coupon_no", []int64{33}, 0); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	info, err := os.Stat(reopened.usedPath("coupon_no"))
+	if err != nil {
+		t.Fatalf("Failed to stat log: %v", err)
+	}
+	if info.Size() != 3*8 {
+		t.Errorf("Expected log size %d after truncation, got %d", 3*8, info.Size())
+	}
+	values, _ = reopened.LoadUsed("coupon_no")
+	if want := []int64{11, 22, 33}; !slices.Equal(values, want) {
+		t.Errorf("Expected %v, got %v", want, values)
+	}
+}
+
+// 测试日志超过 2*keep 条后压缩为最后 keep 条，并保持发号顺序
+func TestAppendUsed_Compact(t *testing.T) {
+	dir := t.TempDir()
+	const keep = 3
+
+	fs, err := NewFileStorage(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	// 6 条未超过 2*keep，不压缩
+	if err := fs.AppendUsed("coupon_no", []int64{60, 50, 40, 30, 20, 10}, keep); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	values, _ := fs.LoadUsed("coupon_no")
+	if len(values) != 6 {
+		t.Fatalf("Expected 6 values before compaction, got %v", values)
+	}
+
+	// 第 7 条触发压缩
+	if err := fs.AppendUsed("coupon_no", []int64{5}, keep); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	values, err = fs.LoadUsed("coupon_no")
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if want := []int64{20, 10, 5}; !slices.Equal(values, want) {
+		t.Errorf("Expected last %d values %v after compaction, got %v", keep, want, values)
+	}
+	if _, err := os.Stat(fs.usedPath("coupon_no") + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected temporary file removed, got err=%v", err)
+	}
+
+	// 压缩后继续追加，重新打开后仍按顺序读出
+	if err := fs.AppendUsed("coupon_no", []int64{1, 2}, keep); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	reopened, err := NewFileStorage(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	values, _ = reopened.LoadUsed("coupon_no")
+	if want := []int64{20, 10, 5, 1, 2}; !slices.Equal(values, want) {
+		t.Errorf("Expected %v after reopen, got %v", want, values)
+	}
+
+	// 重新打开后按文件中的条数判断压缩：5 + 2 = 7 条再次触发
+	if err := reopened.AppendUsed("coupon_no", []int64{7, 8}, keep); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	values, _ = reopened.LoadUsed("coupon_no")
+	if want := []int64{2, 7, 8}; !slices.Equal(values, want) {
+		t.Errorf("Expected %v after second compaction, got %v", want, values)
+	}
+}